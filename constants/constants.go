@@ -11,7 +11,13 @@ const (
 	MONSTER_TYPE_NORMAL = 0
 	MONSTER_TYPE_NPC    = 1
 
+	// 大BOSS及以上的怪物级别
+	MONSTER_GRADE_BOSS = 3
+
 	SCENE_AOI_GRID_SIZE = 60
+
+	// 新建角色默认拥有的技能
+	DEFAULT_HERO_SPELLS = "1,2"
 )
 
 const (
@@ -24,6 +30,72 @@ const (
 	ENTITY_TYPE_HERO int = iota
 	ENTITY_TYPE_MONSTER
 	ENTITY_TYPE_SPELL
+	ENTITY_TYPE_ITEM
+	ENTITY_TYPE_GHOST //相邻cell同步过来的实体, 推送给客户端时使用原来的类型
+)
+
+// 技能类型
+const (
+	SPELL_TYPE_ENEMY = iota //对敌人
+	SPELL_TYPE_SELF         //对自己
+	SPELL_TYPE_ALLY         //对友军
+)
+
+// 物品类型
+const (
+	ITEM_TYPE_MATERIAL   = iota //材料
+	ITEM_TYPE_CONSUMABLE        //消耗品
+	ITEM_TYPE_EQUIPMENT         //装备
+)
+
+// 装备位置, 0表示在背包内
+const (
+	EQUIP_POS_NONE = iota
+	EQUIP_POS_WEAPON
+	EQUIP_POS_ARMOR
+	EQUIP_POS_HELMET
+	EQUIP_POS_BOOTS
+	EQUIP_POS_RING
+	EQUIP_POS_MAX = EQUIP_POS_RING
+)
+
+// 背包格子数量
+const BAG_SIZE = 40
+
+// 场景类型, 副本场景只作为模板, 按需创建实例
+const (
+	SCENE_TYPE_NORMAL   = "0"
+	SCENE_TYPE_INSTANCE = "1"
+)
+
+// 队伍的掉落分配方式
+const (
+	PARTY_LOOT_FREE  = iota //队员都可以拾取伤害最高的队员的掉落
+	PARTY_LOOT_ROUND        //附近的队员轮流获得掉落
+)
+
+// 公会职位
+const (
+	GUILD_RANK_MEMBER  = iota + 1 //成员
+	GUILD_RANK_OFFICER            //官员, 可以审批申请和踢出成员
+	GUILD_RANK_LEADER             //会长
+)
+
+// 聊天频道
+const (
+	CHAT_CHANNEL_NEARBY  = iota + 1 //附近, 视野内的hero
+	CHAT_CHANNEL_SCENE              //当前场景
+	CHAT_CHANNEL_WORLD              //所有在线玩家
+	CHAT_CHANNEL_PARTY              //队伍
+	CHAT_CHANNEL_GUILD              //公会
+	CHAT_CHANNEL_PRIVATE            //私聊
+)
+
+// 副本结果
+const (
+	INSTANCE_RESULT_SUCCESS = iota + 1 //怪物全部被消灭
+	INSTANCE_RESULT_TIMEOUT            //超过时间限制
+	INSTANCE_RESULT_ABANDON            //所有人都离开了
 )
 
 type ActionState int
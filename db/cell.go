@@ -0,0 +1,64 @@
+package db
+
+import (
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+)
+
+func SceneCellList() ([]model.SceneCell, error) {
+	result := make([]model.SceneCell, 0)
+	if err := database.Asc("id").Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+func SceneCellListByScene(sceneId int) ([]model.SceneCell, error) {
+	result := make([]model.SceneCell, 0)
+	if err := database.Where("scene_id=?", sceneId).Asc("id").Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+// SaveSceneCellHeartbeat game节点上报心跳, 第一次上报时插入一条未分配的记录
+func SaveSceneCellHeartbeat(cell *model.SceneCell) error {
+	old := &model.SceneCell{}
+	has, err := database.Where("scene_id=? and service_addr=?", cell.SceneId, cell.ServiceAddr).Get(old)
+	if err != nil {
+		return errutil.ErrDBOperation
+	}
+	if !has {
+		cell.CellIndex = -1
+		_, err = database.Insert(cell)
+		return err
+	}
+	cell.Id = old.Id
+	_, err = database.ID(cell.Id).Cols("node_addr", "width", "heartbeat").Update(cell)
+	return err
+}
+
+// UpdateSceneCellLayout master重新划分后在一个事务内保存
+func UpdateSceneCellLayout(cells []model.SceneCell) error {
+	if len(cells) == 0 {
+		return nil
+	}
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	cols := []string{"cell_index", "min_x", "max_x", "version"}
+	for i := range cells {
+		if _, err := session.ID(cells[i].Id).Cols(cols...).MustCols(cols...).Update(&cells[i]); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
+
+func DeleteSceneCell(id int) error {
+	_, err := database.ID(id).Delete(&model.SceneCell{})
+	return err
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+)
+
+func InsertChatMessage(m *model.ChatMessage) error {
+	_, err := database.Insert(m)
+	return err
+}
+
+// RecentChatMessages 频道内最近的消息, 按时间从早到晚
+func RecentChatMessages(channel int, targetId int64, limit int) ([]model.ChatMessage, error) {
+	result := make([]model.ChatMessage, 0)
+	if err := database.Where("channel=? and target_id=?", channel, targetId).Desc("id").Limit(limit).Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	reverseChatMessages(result)
+	return result, nil
+}
+
+// RecentPrivateMessages 玩家发送和收到的最近的私聊, 按时间从早到晚
+func RecentPrivateMessages(uid int64, limit int) ([]model.ChatMessage, error) {
+	result := make([]model.ChatMessage, 0)
+	err := database.Where("channel=? and (target_id=? or from_uid=?)", constants.CHAT_CHANNEL_PRIVATE, uid, uid).
+		Desc("id").Limit(limit).Find(&result)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	reverseChatMessages(result)
+	return result, nil
+}
+
+func reverseChatMessages(list []model.ChatMessage) {
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+}
+
+// SaveChatMute 设置禁言, until为0时解除
+func SaveChatMute(uid int64, until int64, reason string) error {
+	mute := &model.ChatMute{}
+	has, err := database.Where("uid=?", uid).Get(mute)
+	if err != nil {
+		return errutil.ErrDBOperation
+	}
+	mute.Uid, mute.Until, mute.Reason = uid, until, reason
+	if !has {
+		_, err = database.Insert(mute)
+		return err
+	}
+	_, err = database.ID(mute.Id).Cols("until", "reason").Update(mute)
+	return err
+}
+
+// ActiveChatMutes 还在禁言中的玩家, uid -> 截止时间(毫秒)
+func ActiveChatMutes(now int64) (map[int64]int64, error) {
+	list := make([]model.ChatMute, 0)
+	if err := database.Where("until>?", now).Find(&list); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	result := make(map[int64]int64, len(list))
+	for _, m := range list {
+		result[m.Uid] = m.Until
+	}
+	return result, nil
+}
@@ -0,0 +1,43 @@
+// Package dbtest 测试使用的sqlite数据库, 每个测试进程只启动一次
+package dbtest
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-xorm/xorm"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/internal/engine"
+)
+
+var (
+	once     sync.Once
+	database *xorm.Engine
+)
+
+// Start 启动数据库, 创建beans对应的表并清空表内的数据
+func Start(t testing.TB, beans ...interface{}) {
+	once.Do(func() {
+		dir, err := os.MkdirTemp("", "dbtest")
+		if err != nil {
+			panic(err)
+		}
+		dsn := filepath.Join(dir, "test.db")
+		engine.Driver = "sqlite3"
+		db.MustStartup(dsn, db.ShowSQL(false))
+		if database, err = xorm.NewEngine(engine.Driver, dsn); err != nil {
+			panic(err)
+		}
+	})
+	if err := database.Sync2(beans...); err != nil {
+		t.Fatal(err)
+	}
+	for _, bean := range beans {
+		if _, err := database.Where("1=1").Delete(bean); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+)
+
+// FriendUids 玩家的所有好友
+func FriendUids(uid int64) ([]int64, error) {
+	result := make([]model.Friend, 0)
+	if err := database.Where("uid=?", uid).Asc("id").Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	uids := make([]int64, 0, len(result))
+	for _, f := range result {
+		uids = append(uids, f.FriendUid)
+	}
+	return uids, nil
+}
+
+func FriendCount(uid int64) (int64, error) {
+	return database.Where("uid=?", uid).Count(&model.Friend{})
+}
+
+// InsertFriend 双向添加好友, 同时删除双方之间的申请
+func InsertFriend(uid, friendUid int64) error {
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	if _, err := session.Insert(&model.Friend{Uid: uid, FriendUid: friendUid}, &model.Friend{Uid: friendUid, FriendUid: uid}); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("(uid=? and target_uid=?) or (uid=? and target_uid=?)", uid, friendUid, friendUid, uid).Delete(&model.FriendRequest{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+// DeleteFriend 双向删除好友
+func DeleteFriend(uid, friendUid int64) error {
+	_, err := database.Where("(uid=? and friend_uid=?) or (uid=? and friend_uid=?)", uid, friendUid, friendUid, uid).Delete(&model.Friend{})
+	return err
+}
+
+// InsertFriendRequest 重复申请时更新申请时间
+func InsertFriendRequest(r *model.FriendRequest) error {
+	has, err := database.Where("uid=? and target_uid=?", r.Uid, r.TargetUid).Exist(&model.FriendRequest{})
+	if err != nil {
+		return errutil.ErrDBOperation
+	}
+	if has {
+		_, err = database.Where("uid=? and target_uid=?", r.Uid, r.TargetUid).Cols("name", "request_at").Update(r)
+		return err
+	}
+	_, err = database.Insert(r)
+	return err
+}
+
+func QueryFriendRequest(uid, targetUid int64) (*model.FriendRequest, error) {
+	r := &model.FriendRequest{}
+	has, err := database.Where("uid=? and target_uid=?", uid, targetUid).Get(r)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	if !has {
+		return nil, errutil.ErrNotFound
+	}
+	return r, nil
+}
+
+// FriendRequests 发给玩家的申请
+func FriendRequests(targetUid int64) ([]model.FriendRequest, error) {
+	result := make([]model.FriendRequest, 0)
+	if err := database.Where("target_uid=?", targetUid).Asc("id").Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+func DeleteFriendRequest(uid, targetUid int64) error {
+	_, err := database.Where("uid=? and target_uid=?", uid, targetUid).Delete(&model.FriendRequest{})
+	return err
+}
+
+func BlockedUids(uid int64) ([]int64, error) {
+	result := make([]model.FriendBlock, 0)
+	if err := database.Where("uid=?", uid).Asc("id").Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	uids := make([]int64, 0, len(result))
+	for _, b := range result {
+		uids = append(uids, b.BlockUid)
+	}
+	return uids, nil
+}
+
+func IsBlocked(uid, blockUid int64) (bool, error) {
+	return database.Where("uid=? and block_uid=?", uid, blockUid).Exist(&model.FriendBlock{})
+}
+
+// InsertFriendBlock 屏蔽玩家, 同时删除好友关系和他的申请
+func InsertFriendBlock(uid, blockUid int64) error {
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	if _, err := session.Insert(&model.FriendBlock{Uid: uid, BlockUid: blockUid}); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("(uid=? and friend_uid=?) or (uid=? and friend_uid=?)", uid, blockUid, blockUid, uid).Delete(&model.Friend{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("uid=? and target_uid=?", blockUid, uid).Delete(&model.FriendRequest{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+func DeleteFriendBlock(uid, blockUid int64) error {
+	_, err := database.Where("uid=? and block_uid=?", uid, blockUid).Delete(&model.FriendBlock{})
+	return err
+}
+
+// LastHeroes 玩家最近使用的hero, 用来显示不在线的好友
+func LastHeroes(uids []int64) (map[int64]model.Hero, error) {
+	result := make(map[int64]model.Hero, len(uids))
+	if len(uids) == 0 {
+		return result, nil
+	}
+	heroes := make([]model.Hero, 0)
+	if err := database.In("uid", uids).Cols("id", "uid", "name", "level", "scene_id", "update_at").Asc("update_at").Find(&heroes); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	for _, h := range heroes {
+		result[h.Uid] = h
+	}
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+)
+
+func QueryGuild(id int64) (*model.Guild, error) {
+	g := &model.Guild{}
+	has, err := database.ID(id).Get(g)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	if !has {
+		return nil, errutil.ErrNotFound
+	}
+	return g, nil
+}
+
+func GuildNameExists(name string) (bool, error) {
+	return database.Where("name=?", name).Exist(&model.Guild{})
+}
+
+// QueryGuildMember hero所在的公会, 没有加入时返回ErrNotFound
+func QueryGuildMember(heroId int64) (*model.GuildMember, error) {
+	m := &model.GuildMember{}
+	has, err := database.Where("hero_id=?", heroId).Get(m)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	if !has {
+		return nil, errutil.ErrNotFound
+	}
+	return m, nil
+}
+
+func GuildMembers(guildId int64) ([]model.GuildMember, error) {
+	result := make([]model.GuildMember, 0)
+	if err := database.Where("guild_id=?", guildId).Desc("rank").Asc("id").Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+func GuildMemberCount(guildId int64) (int64, error) {
+	return database.Where("guild_id=?", guildId).Count(&model.GuildMember{})
+}
+
+// InsertGuild 创建公会, 创建者成为会长并删除他的其他申请, 会回填id
+func InsertGuild(g *model.Guild, leader *model.GuildMember) error {
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	if _, err := session.Insert(g); err != nil {
+		session.Rollback()
+		return err
+	}
+	leader.GuildId = g.Id
+	leader.Rank = constants.GUILD_RANK_LEADER
+	if _, err := session.Insert(leader); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("hero_id=?", leader.HeroId).Delete(&model.GuildApply{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+// DeleteGuild 解散公会, 同时删除成员和申请
+func DeleteGuild(id int64) error {
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	if _, err := session.ID(id).Delete(&model.Guild{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("guild_id=?", id).Delete(&model.GuildMember{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("guild_id=?", id).Delete(&model.GuildApply{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+func UpdateGuildNotice(id int64, notice string) error {
+	_, err := database.ID(id).Cols("notice").Update(&model.Guild{Notice: notice})
+	return err
+}
+
+// InsertGuildApply 重复申请时只保留一条
+func InsertGuildApply(apply *model.GuildApply) error {
+	has, err := database.Where("guild_id=? and hero_id=?", apply.GuildId, apply.HeroId).Exist(&model.GuildApply{})
+	if err != nil {
+		return errutil.ErrDBOperation
+	}
+	if has {
+		return nil
+	}
+	_, err = database.Insert(apply)
+	return err
+}
+
+func QueryGuildApply(guildId, heroId int64) (*model.GuildApply, error) {
+	apply := &model.GuildApply{}
+	has, err := database.Where("guild_id=? and hero_id=?", guildId, heroId).Get(apply)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	if !has {
+		return nil, errutil.ErrNotFound
+	}
+	return apply, nil
+}
+
+func GuildApplies(guildId int64) ([]model.GuildApply, error) {
+	result := make([]model.GuildApply, 0)
+	if err := database.Where("guild_id=?", guildId).Asc("id").Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+func DeleteGuildApply(guildId, heroId int64) error {
+	_, err := database.Where("guild_id=? and hero_id=?", guildId, heroId).Delete(&model.GuildApply{})
+	return err
+}
+
+// InsertGuildMember 审批通过后加入公会, 同时删除这个hero的所有申请
+func InsertGuildMember(m *model.GuildMember) error {
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	if _, err := session.Insert(m); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("hero_id=?", m.HeroId).Delete(&model.GuildApply{}); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+func DeleteGuildMember(heroId int64) error {
+	_, err := database.Where("hero_id=?", heroId).Delete(&model.GuildMember{})
+	return err
+}
+
+func UpdateGuildMemberRank(heroId int64, rank int) error {
+	_, err := database.Where("hero_id=?", heroId).Cols("rank").Update(&model.GuildMember{Rank: rank})
+	return err
+}
+
+// TransferGuildLeader 转让会长, 原来的会长降为官员
+func TransferGuildLeader(guildId, fromHeroId, toHeroId int64) error {
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	if _, err := session.ID(guildId).Cols("leader_hero_id").Update(&model.Guild{LeaderHeroId: toHeroId}); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("hero_id=?", fromHeroId).Cols("rank").Update(&model.GuildMember{Rank: constants.GUILD_RANK_OFFICER}); err != nil {
+		session.Rollback()
+		return err
+	}
+	if _, err := session.Where("hero_id=?", toHeroId).Cols("rank").Update(&model.GuildMember{Rank: constants.GUILD_RANK_LEADER}); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
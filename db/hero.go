@@ -11,11 +11,11 @@ func InsertHero(h *model.Hero) (int64, error) {
 	}
 	row, err := database.Exec(`insert into hero(name,avatar,attr_type,uid,experience,level,max_life,max_mana,
                  defense,attack,base_life,base_mana,base_defense,base_attack,strength,agility,
-                 intelligence,step_time,scene_id,attack_range) 
-					values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+                 intelligence,step_time,scene_id,attack_range,spells) 
+					values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
 		h.Name, h.Avatar, h.AttrType, h.Uid, h.Experience, h.Level, h.MaxLife, h.MaxMana,
 		h.Defense, h.Attack, h.BaseLife, h.BaseMana, h.BaseDefense, h.BaseAttack, h.Strength, h.Agility,
-		h.Intelligence, h.StepTime, h.SceneId, h.AttackRange)
+		h.Intelligence, h.StepTime, h.SceneId, h.AttackRange, h.Spells)
 	if err != nil {
 		return 0, err
 	}
@@ -59,3 +59,12 @@ func HeroList(uid int64) ([]model.Hero, error) {
 	}
 	return result, nil
 }
+
+func HeroLevelList() ([]model.HeroLevel, error) {
+	result := make([]model.HeroLevel, 0)
+	err := database.Asc("attr_type", "level").Find(&result)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nano/gameserver/db/model"
+)
+
+// hero数据异步批量写入, 同一个hero多次保存会合并成一次update, 失败的会在下次批量写入时重试
+const (
+	heroWriterBatchSize = 64
+	heroWriterInterval  = time.Second
+	// 进程退出时每条数据最多重试次数
+	heroWriterMaxRetry = 5
+)
+
+var ErrHeroWriterTimeout = errors.New("hero writer flush timeout")
+
+type heroUpdate struct {
+	hero  model.Hero
+	cols  map[string]struct{}
+	retry int
+}
+
+// 保存时直接合并到pending, 不会阻塞场景携程
+type heroWriter struct {
+	mu       sync.Mutex
+	pending  map[int64]*heroUpdate
+	chNotify chan struct{}
+	chFlush  chan chan struct{}
+	chStop   chan struct{}
+	chDone   chan struct{}
+}
+
+var defaultHeroWriter atomic.Pointer[heroWriter]
+
+func newHeroWriter() *heroWriter {
+	return &heroWriter{
+		pending:  make(map[int64]*heroUpdate),
+		chNotify: make(chan struct{}, 1),
+		chFlush:  make(chan chan struct{}),
+		chStop:   make(chan struct{}),
+		chDone:   make(chan struct{}),
+	}
+}
+
+func startHeroWriter() {
+	w := newHeroWriter()
+	defaultHeroWriter.Store(w)
+	go w.run()
+}
+
+// 停止前会尽量把剩余的数据写完
+func stopHeroWriter() {
+	w := defaultHeroWriter.Swap(nil)
+	if w == nil {
+		return
+	}
+	close(w.chStop)
+	<-w.chDone
+}
+
+// SaveHero 保存hero变化的字段, immediate为true时不等待定时器立即写入
+func SaveHero(h *model.Hero, cols []string, immediate bool) {
+	if h == nil || h.Id <= 0 || len(cols) == 0 {
+		return
+	}
+	if w := defaultHeroWriter.Load(); w != nil {
+		w.save(h, cols, immediate)
+	}
+}
+
+// FlushHeroes 等待已提交的hero数据全部写入数据库
+func FlushHeroes(timeout time.Duration) error {
+	w := defaultHeroWriter.Load()
+	if w == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	select {
+	case w.chFlush <- done:
+	case <-time.After(timeout):
+		return ErrHeroWriterTimeout
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return ErrHeroWriterTimeout
+	}
+}
+
+// UpdateHeroCols 只更新指定的字段, 零值也会写入
+func UpdateHeroCols(h *model.Hero, cols ...string) error {
+	if h == nil || len(cols) == 0 {
+		return nil
+	}
+	_, err := database.ID(h.Id).Cols(cols...).MustCols(cols...).Update(h)
+	return err
+}
+
+func (w *heroWriter) run() {
+	defer close(w.chDone)
+	ticker := time.NewTicker(heroWriterInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.chNotify:
+			w.flush()
+		case <-ticker.C:
+			w.flush()
+		case done := <-w.chFlush:
+			w.flush()
+			close(done)
+		case <-w.chStop:
+			for i := 0; i < heroWriterMaxRetry && w.pendingCount() > 0; i++ {
+				w.flush()
+			}
+			for id, u := range w.take() {
+				// 多次重试失败，打印出来方便人工恢复
+				logger.Errorf("hero:%d 数据写入失败已丢弃, cols:%v, data:%+v", id, u.columns(), u.hero)
+			}
+			return
+		}
+	}
+}
+
+// 同一个hero只保留最新的数据, 需要写入的字段取并集
+func (w *heroWriter) save(h *model.Hero, cols []string, immediate bool) {
+	w.mu.Lock()
+	u, ok := w.pending[h.Id]
+	if !ok {
+		u = &heroUpdate{cols: make(map[string]struct{})}
+		w.pending[h.Id] = u
+	}
+	u.hero = *h
+	for _, col := range cols {
+		u.cols[col] = struct{}{}
+	}
+	notify := immediate || len(w.pending) >= heroWriterBatchSize
+	w.mu.Unlock()
+	if notify {
+		select {
+		case w.chNotify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *heroWriter) pendingCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+func (w *heroWriter) take() map[int64]*heroUpdate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := w.pending
+	w.pending = make(map[int64]*heroUpdate)
+	return pending
+}
+
+// 写入失败的放回pending, 期间有新数据时保留新数据, 字段取并集
+func (w *heroWriter) retry(id int64, failed *heroUpdate) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	u, ok := w.pending[id]
+	if !ok {
+		w.pending[id] = failed
+		return
+	}
+	for col := range failed.cols {
+		u.cols[col] = struct{}{}
+	}
+	u.retry = failed.retry
+}
+
+func (w *heroWriter) flush() {
+	for id, u := range w.take() {
+		if err := UpdateHeroCols(&u.hero, u.columns()...); err != nil {
+			u.retry++
+			logger.Warningf("hero:%d 数据写入失败, 第%d次重试: %v", id, u.retry, err)
+			w.retry(id, u)
+		}
+	}
+}
+
+func (u *heroUpdate) columns() []string {
+	cols := make([]string, 0, len(u.cols))
+	for col := range u.cols {
+		cols = append(cols, col)
+	}
+	return cols
+}
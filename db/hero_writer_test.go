@@ -0,0 +1,33 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeroWriterSave(t *testing.T) {
+	w := newHeroWriter()
+	// 没有写入携程时也不会阻塞
+	for i := 0; i < heroWriterBatchSize*4; i++ {
+		w.save(&model.Hero{Id: int64(i%10 + 1), Level: i}, []string{"level"}, true)
+	}
+	w.save(&model.Hero{Id: 1, Life: 50}, []string{"life"}, false)
+	assert.Equal(t, 10, w.pendingCount())
+	assert.Len(t, w.chNotify, 1)
+
+	// 同一个hero保留最新数据, 字段取并集
+	pending := w.take()
+	assert.Equal(t, int64(50), pending[1].hero.Life)
+	assert.ElementsMatch(t, []string{"level", "life"}, pending[1].columns())
+
+	// 重试时有新数据的保留新数据
+	w.save(&model.Hero{Id: 1, Life: 40}, []string{"life"}, false)
+	w.retry(1, pending[1])
+	w.retry(2, pending[2])
+	pending = w.take()
+	assert.Equal(t, int64(40), pending[1].hero.Life)
+	assert.ElementsMatch(t, []string{"level", "life"}, pending[1].columns())
+	assert.Contains(t, pending, int64(2))
+}
@@ -0,0 +1,19 @@
+package db
+
+import (
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+)
+
+// InsertInstanceRecord 副本结束后记录结果, 同一个实例只记录一次
+func InsertInstanceRecord(record *model.InstanceRecord) error {
+	has, err := database.Where("instance_id=?", record.InstanceId).Exist(&model.InstanceRecord{})
+	if err != nil {
+		return errutil.ErrDBOperation
+	}
+	if has {
+		return nil
+	}
+	_, err = database.Insert(record)
+	return err
+}
@@ -0,0 +1,5 @@
+// Package engine 创建数据库连接的参数, 只有db和db/dbtest可以修改
+package engine
+
+// Driver 数据库驱动, 测试时由dbtest换成sqlite
+var Driver = "mysql"
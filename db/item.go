@@ -0,0 +1,64 @@
+package db
+
+import (
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+)
+
+func ItemList() ([]model.Item, error) {
+	result := make([]model.Item, 0)
+	err := database.Find(&result)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+func MonsterDropList(monsterId int64) ([]model.MonsterDrop, error) {
+	result := make([]model.MonsterDrop, 0)
+	err := database.Where("monster_id=?", monsterId).Find(&result)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+func HeroItemList(heroId int64) ([]model.HeroItem, error) {
+	result := make([]model.HeroItem, 0)
+	err := database.Where("hero_id=?", heroId).Find(&result)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+// SaveHeroItems 在一个事务内保存背包的修改, 新增的物品会回填id
+func SaveHeroItems(changed []*model.HeroItem, removed []int64) error {
+	if len(changed) == 0 && len(removed) == 0 {
+		return nil
+	}
+	session := database.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return errutil.ErrDBOperation
+	}
+	var err error
+	for _, item := range changed {
+		if item.Id > 0 {
+			_, err = session.ID(item.Id).Cols("count", "slot", "equip_pos").Update(item)
+		} else {
+			_, err = session.Insert(item)
+		}
+		if err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+	if len(removed) > 0 {
+		if _, err = session.In("id", removed).Delete(&model.HeroItem{}); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+	return session.Commit()
+}
@@ -5,6 +5,7 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/go-xorm/xorm"
+	"github.com/nano/gameserver/db/internal/engine"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -102,7 +103,7 @@ func MustStartup(dsn string, opts ...ModelOption) func() {
 	logger.Infof("DSN=%s ShowSQL=%t MaxIdleConn=%v MaxOpenConn=%v", dsn, settings.showSQL, settings.maxIdleConns, settings.maxOpenConns)
 
 	// create database instance
-	if db, err := xorm.NewEngine("mysql", dsn); err != nil {
+	if db, err := xorm.NewEngine(engine.Driver, dsn); err != nil {
 		panic(err)
 	} else {
 		database = db
@@ -121,8 +122,11 @@ func MustStartup(dsn string, opts ...ModelOption) func() {
 
 	syncSchema()
 	envInit()
+	startHeroWriter()
 
 	closer := func() {
+		// 先把未写入的hero数据写完再关闭数据库
+		stopHeroWriter()
 		close(chWrite)
 		close(chUpdate)
 		database.Close()
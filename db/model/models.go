@@ -30,6 +30,71 @@ type BufferState struct {
 	CreateAt            time.Time `json:"-" db:"create_at" `                                 //
 	UpdateAt            time.Time `json:"-" db:"update_at" `                                 //
 }
+type ChatMessage struct {
+	Id         int64     `json:"id" db:"id" `                     //
+	Channel    int       `json:"channel" db:"channel" `           //1 附近 2 场景 3 世界 4 队伍 5 公会 6 私聊
+	TargetId   int64     `json:"target_id" db:"target_id" `       //场景id、队伍id、公会id、私聊的接收人uid
+	FromUid    int64     `json:"from_uid" db:"from_uid" `         //
+	FromHeroId int64     `json:"from_hero_id" db:"from_hero_id" ` //
+	FromName   string    `json:"from_name" db:"from_name" `       //
+	Msg        string    `json:"msg" db:"msg" `                   //过滤后的内容
+	SendAt     int64     `json:"send_at" db:"send_at" `           //发送时间(毫秒)
+	CreateAt   time.Time `json:"-" db:"create_at" `               //
+}
+type ChatMute struct {
+	Id       int64     `json:"id" db:"id" `         //
+	Uid      int64     `json:"uid" db:"uid" `       //
+	Until    int64     `json:"until" db:"until" `   //禁言截止时间(毫秒), 0为解除
+	Reason   string    `json:"reason" db:"reason" ` //
+	CreateAt time.Time `json:"-" db:"create_at" `   //
+	UpdateAt time.Time `json:"-" db:"update_at" `   //
+}
+type Friend struct {
+	Id        int64     `json:"id" db:"id" `                 //
+	Uid       int64     `json:"uid" db:"uid" `               //
+	FriendUid int64     `json:"friend_uid" db:"friend_uid" ` //好友关系双向各保存一条
+	CreateAt  time.Time `json:"-" db:"create_at" `           //
+}
+type FriendBlock struct {
+	Id       int64     `json:"id" db:"id" `               //
+	Uid      int64     `json:"uid" db:"uid" `             //
+	BlockUid int64     `json:"block_uid" db:"block_uid" ` //被屏蔽的玩家
+	CreateAt time.Time `json:"-" db:"create_at" `         //
+}
+type FriendRequest struct {
+	Id        int64     `json:"id" db:"id" `                 //
+	Uid       int64     `json:"uid" db:"uid" `               //申请人
+	TargetUid int64     `json:"target_uid" db:"target_uid" ` //
+	Name      string    `json:"name" db:"name" `             //申请时的hero名字
+	RequestAt int64     `json:"request_at" db:"request_at" ` //申请时间(毫秒)
+	CreateAt  time.Time `json:"-" db:"create_at" `           //
+}
+type Guild struct {
+	Id           int64     `json:"id" db:"id" `                         //
+	Name         string    `json:"name" db:"name" `                     //
+	LeaderHeroId int64     `json:"leader_hero_id" db:"leader_hero_id" ` //会长
+	Notice       string    `json:"notice" db:"notice" `                 //公告
+	CreateAt     time.Time `json:"-" db:"create_at" `                   //
+	UpdateAt     time.Time `json:"-" db:"update_at" `                   //
+}
+type GuildApply struct {
+	Id       int64     `json:"id" db:"id" `             //
+	GuildId  int64     `json:"guild_id" db:"guild_id" ` //
+	HeroId   int64     `json:"hero_id" db:"hero_id" `   //
+	Uid      int64     `json:"uid" db:"uid" `           //
+	Name     string    `json:"name" db:"name" `         //申请的hero名字
+	CreateAt time.Time `json:"-" db:"create_at" `       //
+}
+type GuildMember struct {
+	Id       int64     `json:"id" db:"id" `             //
+	GuildId  int64     `json:"guild_id" db:"guild_id" ` //
+	HeroId   int64     `json:"hero_id" db:"hero_id" `   //
+	Uid      int64     `json:"uid" db:"uid" `           //
+	Name     string    `json:"name" db:"name" `         //hero名字
+	Rank     int       `json:"rank" db:"rank" `         //1 成员 2 官员 3 会长
+	CreateAt time.Time `json:"-" db:"create_at" `       //
+	UpdateAt time.Time `json:"-" db:"update_at" `       //
+}
 type Hero struct {
 	Id           int64     `json:"id" db:"id" `                     //
 	Name         string    `json:"name" db:"name" `                 //
@@ -40,6 +105,8 @@ type Hero struct {
 	Level        int       `json:"level" db:"level" `               //
 	MaxLife      int64     `json:"max_life" db:"max_life" `         //
 	MaxMana      int64     `json:"max_mana" db:"max_mana" `         //
+	Life         int64     `json:"life" db:"life" `                 //当前生命, 0时上线满血
+	Mana         int64     `json:"mana" db:"mana" `                 //当前魔法
 	Defense      int64     `json:"defense" db:"defense" `           //
 	Attack       int64     `json:"attack" db:"attack" `             //
 	BaseLife     int64     `json:"base_life" db:"base_life" `       //
@@ -55,9 +122,55 @@ type Hero struct {
 	InitPosy     int       `json:"init_posy" db:"init_posy" `       //
 	InitPosz     int       `json:"init_posz" db:"init_posz" `       //
 	AttackRange  int       `json:"attack_range" db:"attack_range" ` //
+	Spells       string    `json:"spells" db:"spells" `             //拥有的技能id, 逗号分隔
+	CreateAt     time.Time `json:"-" db:"create_at" `               //
+	UpdateAt     time.Time `json:"-" db:"update_at" `               //
+}
+type HeroItem struct {
+	Id       int64     `json:"id" db:"id" `               //
+	HeroId   int64     `json:"hero_id" db:"hero_id" `     //
+	ItemId   int       `json:"item_id" db:"item_id" `     //
+	Count    int       `json:"count" db:"count" `         //数量
+	Slot     int       `json:"slot" db:"slot" `           //背包格子
+	EquipPos int       `json:"equip_pos" db:"equip_pos" ` //装备位置: 0 在背包内, 1 武器, 2 衣服, 3 头盔, 4 鞋子, 5 戒指
+	CreateAt time.Time `json:"-" db:"create_at" `         //
+	UpdateAt time.Time `json:"-" db:"update_at" `         //
+}
+type HeroLevel struct {
+	Id           int       `json:"id" db:"id" `                     //
+	AttrType     int       `json:"attr_type" db:"attr_type" `       //属性类型:0 力量，1敏捷, 2智慧
+	Level        int       `json:"level" db:"level" `               //
+	Experience   int64     `json:"experience" db:"experience" `     //升到下一级需要的经验, 0为满级
+	Strength     int64     `json:"strength" db:"strength" `         //升到该等级增加的力量
+	Agility      int64     `json:"agility" db:"agility" `           //升到该等级增加的敏捷
+	Intelligence int64     `json:"intelligence" db:"intelligence" ` //升到该等级增加的智慧
 	CreateAt     time.Time `json:"-" db:"create_at" `               //
 	UpdateAt     time.Time `json:"-" db:"update_at" `               //
 }
+type InstanceRecord struct {
+	Id         int       `json:"id" db:"id" `                   //
+	InstanceId int64     `json:"instance_id" db:"instance_id" ` //副本实例id
+	SceneId    int       `json:"scene_id" db:"scene_id" `       //副本模板场景
+	Uids       string    `json:"uids" db:"uids" `               //参与的玩家, 逗号分隔
+	Result     int       `json:"result" db:"result" `           //1 成功 2 超时 3 放弃
+	UsedTime   int       `json:"used_time" db:"used_time" `     //用时(秒)
+	CreateAt   time.Time `json:"-" db:"create_at" `             //
+}
+type Item struct {
+	Id          int       `json:"id" db:"id" `                   //
+	Name        string    `json:"name" db:"name" `               //
+	Icon        string    `json:"icon" db:"icon" `               //图标
+	ItemType    int       `json:"item_type" db:"item_type" `     //物品类型: 0 材料，1 消耗品, 2 装备
+	MaxStack    int       `json:"max_stack" db:"max_stack" `     //最大堆叠数量
+	EquipPos    int       `json:"equip_pos" db:"equip_pos" `     //装备位置: 0 不能装备, 1 武器, 2 衣服, 3 头盔, 4 鞋子, 5 戒指
+	Attack      int64     `json:"attack" db:"attack" `           //装备增加的攻击
+	Defense     int64     `json:"defense" db:"defense" `         //装备增加的防御
+	Life        int64     `json:"life" db:"life" `               //装备增加的生命上限, 消耗品恢复的生命
+	Mana        int64     `json:"mana" db:"mana" `               //装备增加的魔法上限, 消耗品恢复的魔法
+	Description string    `json:"description" db:"description" ` //
+	CreateAt    time.Time `json:"-" db:"create_at" `             //
+	UpdateAt    time.Time `json:"-" db:"update_at" `             //
+}
 type Login struct {
 	Id        int64     `json:"id" db:"id" `                 //
 	Uid       int64     `json:"uid" db:"uid" `               //
@@ -95,9 +208,20 @@ type Monster struct {
 	AttackRange        int       `json:"attack_range" db:"attack_range" `                 //攻击范围
 	AttackDuration     int       `json:"attack_duration" db:"attack_duration" `           //攻击间隔
 	Description        string    `json:"description" db:"description" `                   //简介
+	InstanceSceneId    int       `json:"instance_scene_id" db:"instance_scene_id" `       //npc可以进入的副本
 	CreateAt           time.Time `json:"-" db:"create_at" `                               //
 	UpdateAt           time.Time `json:"-" db:"update_at" `                               //
 }
+type MonsterDrop struct {
+	Id          int       `json:"id" db:"id" `                   //
+	MonsterId   int64     `json:"monster_id" db:"monster_id" `   //
+	ItemId      int       `json:"item_id" db:"item_id" `         //掉落的物品, 0为金币
+	MinCount    int       `json:"min_count" db:"min_count" `     //
+	MaxCount    int       `json:"max_count" db:"max_count" `     //
+	Probability int       `json:"probability" db:"probability" ` //掉落概率(万分比)
+	CreateAt    time.Time `json:"-" db:"create_at" `             //
+	UpdateAt    time.Time `json:"-" db:"update_at" `             //
+}
 type Online struct {
 	Id        int       `json:"id" db:"id" `                 //
 	UserCount int       `json:"user_count" db:"user_count" ` //
@@ -128,9 +252,24 @@ type Scene struct {
 	Enterx    int       `json:"enterx" db:"enterx" `         //
 	Entery    int       `json:"entery" db:"entery" `         //
 	Enterz    int       `json:"enterz" db:"enterz" `         //
+	TimeLimit int       `json:"time_limit" db:"time_limit" ` //副本的时间限制(秒)
 	CreateAt  time.Time `json:"-" db:"create_at" `           //
 	UpdateAt  time.Time `json:"-" db:"update_at" `           //
 }
+type SceneCell struct {
+	Id          int       `json:"id" db:"id" `                     //
+	SceneId     int       `json:"scene_id" db:"scene_id" `         //
+	ServiceAddr string    `json:"service_addr" db:"service_addr" ` //game节点在集群内的地址, master按这个地址路由
+	NodeAddr    string    `json:"node_addr" db:"node_addr" `       //game节点之间同步ghost的地址
+	CellIndex   int       `json:"cell_index" db:"cell_index" `     //从左到右的序号, -1 未分配
+	MinX        int       `json:"min_x" db:"min_x" `               //负责的范围[min_x, max_x)
+	MaxX        int       `json:"max_x" db:"max_x" `               //
+	Width       int       `json:"width" db:"width" `               //场景宽度
+	Version     int       `json:"version" db:"version" `           //划分的版本, 每次重新划分加1
+	Heartbeat   int64     `json:"heartbeat" db:"heartbeat" `       //最后一次心跳的时间(毫秒)
+	CreateAt    time.Time `json:"-" db:"create_at" `               //
+	UpdateAt    time.Time `json:"-" db:"update_at" `               //
+}
 type SceneDoor struct {
 	Id            int       `json:"id" db:"id" `                           //
 	Name          string    `json:"name" db:"name" `                       //
@@ -213,3 +352,12 @@ type User struct {
 	CreateAt    time.Time `json:"-" db:"create_at" `               //
 	UpdateAt    time.Time `json:"-" db:"update_at" `               //
 }
+type VoiceClip struct {
+	Id       int64     `json:"id" db:"id" `               //
+	FileId   string    `json:"file_id" db:"file_id" `     //
+	Uid      int64     `json:"uid" db:"uid" `             //上传的玩家
+	Size     int       `json:"size" db:"size" `           //字节数
+	Duration int       `json:"duration" db:"duration" `   //时长(毫秒)
+	UploadAt int64     `json:"upload_at" db:"upload_at" ` //上传时间(毫秒)
+	CreateAt time.Time `json:"-" db:"create_at" `         //
+}
@@ -35,6 +35,18 @@ func SceneList(sceneIds []int) ([]model.Scene, error) {
 	return list, nil
 }
 
+func QuerySceneDoor(id int) (*model.SceneDoor, error) {
+	h := &model.SceneDoor{Id: id}
+	has, err := database.Get(h)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errutil.ErrNotFound
+	}
+	return h, nil
+}
+
 func SceneDoorList(sceneId int) ([]model.SceneDoor, error) {
 	result := make([]model.SceneDoor, 0)
 	if err := database.Where("scene_id=?", sceneId).Find(&result); err != nil {
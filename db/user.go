@@ -79,7 +79,7 @@ func UserAddCoin(uid int64, coin int64) error {
 		return errutil.ErrNotFound
 	}
 	u.Coin += coin
-	_, err = session.Where("uid=?", uid).Update(u)
+	_, err = session.Cols("coin").Where("id=?", uid).Update(u)
 	if err != nil {
 		session.Rollback()
 		return err
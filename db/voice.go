@@ -0,0 +1,37 @@
+package db
+
+import (
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+)
+
+func InsertVoiceClip(v *model.VoiceClip) error {
+	_, err := database.Insert(v)
+	return err
+}
+
+func QueryVoiceClip(fileId string) (*model.VoiceClip, error) {
+	v := &model.VoiceClip{}
+	has, err := database.Where("file_id=?", fileId).Get(v)
+	if err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	if !has {
+		return nil, errutil.ErrNotFound
+	}
+	return v, nil
+}
+
+// ExpiredVoiceClips 上传时间早于before的语音, 每次最多limit条
+func ExpiredVoiceClips(before int64, limit int) ([]model.VoiceClip, error) {
+	result := make([]model.VoiceClip, 0)
+	if err := database.Where("upload_at<?", before).Asc("id").Limit(limit).Find(&result); err != nil {
+		return nil, errutil.ErrDBOperation
+	}
+	return result, nil
+}
+
+func DeleteVoiceClip(id int64) error {
+	_, err := database.ID(id).Delete(&model.VoiceClip{})
+	return err
+}
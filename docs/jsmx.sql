@@ -67,6 +67,131 @@ CREATE TABLE `buffer_state`  (
 INSERT INTO `buffer_state` VALUES (1, '', 'buf1', 0, 60, 1000, 1000, 5, 10000, 0, '2024-11-13 15:29:48', '2024-11-13 15:47:15');
 INSERT INTO `buffer_state` VALUES (2, '', 'buf2', 1, -10, 1000, 1000, 10, 10000, 0, '2024-11-13 15:34:24', '2024-11-13 15:34:24');
 
+-- ----------------------------
+-- Table structure for chat_message
+-- ----------------------------
+DROP TABLE IF EXISTS `chat_message`;
+CREATE TABLE `chat_message`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `channel` tinyint(4) NOT NULL COMMENT '1 附近 2 场景 3 世界 4 队伍 5 公会 6 私聊',
+  `target_id` bigint(20) NOT NULL DEFAULT 0 COMMENT '场景id、队伍id、公会id、私聊的接收人uid',
+  `from_uid` bigint(20) NOT NULL,
+  `from_hero_id` bigint(20) NOT NULL,
+  `from_name` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `msg` varchar(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '过滤后的内容',
+  `send_at` bigint(20) NOT NULL COMMENT '发送时间(毫秒)',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `channel_target_idx`(`channel`, `target_id`) USING BTREE,
+  INDEX `from_uid_idx`(`from_uid`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for chat_mute
+-- ----------------------------
+DROP TABLE IF EXISTS `chat_mute`;
+CREATE TABLE `chat_mute`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `uid` bigint(20) NOT NULL,
+  `until` bigint(20) NOT NULL DEFAULT 0 COMMENT '禁言截止时间(毫秒), 0为解除',
+  `reason` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `uid_uk`(`uid`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for friend
+-- ----------------------------
+DROP TABLE IF EXISTS `friend`;
+CREATE TABLE `friend`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `uid` bigint(20) NOT NULL,
+  `friend_uid` bigint(20) NOT NULL COMMENT '好友关系双向各保存一条',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `uid_friend_uk`(`uid`, `friend_uid`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for friend_block
+-- ----------------------------
+DROP TABLE IF EXISTS `friend_block`;
+CREATE TABLE `friend_block`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `uid` bigint(20) NOT NULL,
+  `block_uid` bigint(20) NOT NULL COMMENT '被屏蔽的玩家',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `uid_block_uk`(`uid`, `block_uid`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for friend_request
+-- ----------------------------
+DROP TABLE IF EXISTS `friend_request`;
+CREATE TABLE `friend_request`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `uid` bigint(20) NOT NULL COMMENT '申请人',
+  `target_uid` bigint(20) NOT NULL,
+  `name` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '申请时的hero名字',
+  `request_at` bigint(20) NOT NULL DEFAULT 0 COMMENT '申请时间(毫秒)',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `uid_target_uk`(`uid`, `target_uid`) USING BTREE,
+  INDEX `target_idx`(`target_uid`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for guild
+-- ----------------------------
+DROP TABLE IF EXISTS `guild`;
+CREATE TABLE `guild`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `leader_hero_id` bigint(20) NOT NULL DEFAULT 0 COMMENT '会长',
+  `notice` varchar(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '公告',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `name_uk`(`name`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for guild_apply
+-- ----------------------------
+DROP TABLE IF EXISTS `guild_apply`;
+CREATE TABLE `guild_apply`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `guild_id` bigint(20) NOT NULL,
+  `hero_id` bigint(20) NOT NULL,
+  `uid` bigint(20) NOT NULL,
+  `name` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '申请的hero名字',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `guild_hero_uk`(`guild_id`, `hero_id`) USING BTREE,
+  INDEX `hero_idx`(`hero_id`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for guild_member
+-- ----------------------------
+DROP TABLE IF EXISTS `guild_member`;
+CREATE TABLE `guild_member`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `guild_id` bigint(20) NOT NULL,
+  `hero_id` bigint(20) NOT NULL,
+  `uid` bigint(20) NOT NULL,
+  `name` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT 'hero名字',
+  `rank` tinyint(4) NOT NULL DEFAULT 1 COMMENT '1 成员 2 官员 3 会长',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `hero_uk`(`hero_id`) USING BTREE,
+  INDEX `guild_idx`(`guild_id`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for hero
 -- ----------------------------
@@ -81,6 +206,8 @@ CREATE TABLE `hero`  (
   `level` int(255) NOT NULL DEFAULT 1,
   `max_life` bigint(255) NOT NULL DEFAULT 0,
   `max_mana` bigint(255) NOT NULL DEFAULT 0,
+  `life` bigint(255) NOT NULL DEFAULT 0 COMMENT '当前生命, 0时上线满血',
+  `mana` bigint(255) NOT NULL DEFAULT 0 COMMENT '当前魔法',
   `defense` bigint(255) NOT NULL DEFAULT 0,
   `attack` bigint(255) NOT NULL DEFAULT 0,
   `base_life` bigint(255) NOT NULL,
@@ -96,6 +223,7 @@ CREATE TABLE `hero`  (
   `init_posy` int(255) NOT NULL DEFAULT 0,
   `init_posz` int(255) NOT NULL DEFAULT 0,
   `attack_range` int(255) NOT NULL DEFAULT 0,
+  `spells` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '拥有的技能id, 逗号分隔',
   `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
   `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
   PRIMARY KEY (`id`) USING BTREE,
@@ -105,41 +233,155 @@ CREATE TABLE `hero`  (
 -- ----------------------------
 -- Records of hero
 -- ----------------------------
-INSERT INTO `hero` VALUES (1, '陶醉的永恩', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 1, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 12:13:04', '2024-11-13 12:13:04');
-INSERT INTO `hero` VALUES (2, '肖申克在巴黎徒步', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 2, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 12:25:50', '2024-11-13 12:25:50');
-INSERT INTO `hero` VALUES (3, '呆萌的乔布斯', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 3, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 14:42:35', '2024-11-13 14:42:35');
-INSERT INTO `hero` VALUES (4, '科比打豆豆', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 4, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 14:58:35', '2024-11-13 14:58:35');
-INSERT INTO `hero` VALUES (5, '细腻的普拉蒂尼', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 5, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 14:59:41', '2024-11-13 14:59:41');
-INSERT INTO `hero` VALUES (6, '风中的哈维', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 6, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:00:19', '2024-11-13 15:00:19');
-INSERT INTO `hero` VALUES (7, '野性的雅典娜', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 7, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:00:39', '2024-11-13 15:00:39');
-INSERT INTO `hero` VALUES (8, '柔弱的齐达內', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 8, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:01:22', '2024-11-13 15:01:22');
-INSERT INTO `hero` VALUES (9, '粗犷的姆巴佩', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 9, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:01:56', '2024-11-13 15:01:56');
-INSERT INTO `hero` VALUES (10, '一休走向人生巅峰', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 10, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:02:14', '2024-11-13 15:02:14');
-INSERT INTO `hero` VALUES (11, '欧文完成了帽子戏法', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 11, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:32:55', '2024-11-13 15:32:55');
-INSERT INTO `hero` VALUES (12, '听话的鲁尼', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 12, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:36:49', '2024-11-13 15:36:49');
-INSERT INTO `hero` VALUES (13, '尤西比奥一眼定情', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 13, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:37:03', '2024-11-13 15:37:03');
-INSERT INTO `hero` VALUES (14, '约翰·查尔斯在武汉看电影', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 14, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:45:45', '2024-11-13 15:45:45');
-INSERT INTO `hero` VALUES (15, '罗马里奥有亿点点忧伤', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 15, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:47:33', '2024-11-13 15:47:33');
-INSERT INTO `hero` VALUES (16, '巴乔横扫六合', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 16, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:48:42', '2024-11-13 15:48:42');
-INSERT INTO `hero` VALUES (17, '加林查吃爆米花', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 17, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:49:37', '2024-11-13 15:49:37');
-INSERT INTO `hero` VALUES (18, '懵懂的伊布', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 18, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:49:53', '2024-11-13 15:49:53');
-INSERT INTO `hero` VALUES (19, '普拉蒂尼掐指一算', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 19, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:51:38', '2024-11-13 15:51:38');
-INSERT INTO `hero` VALUES (20, '知性的贝利', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 20, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:51:49', '2024-11-13 15:51:49');
-INSERT INTO `hero` VALUES (21, '美好的永恩', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 21, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:59:13', '2024-11-13 15:59:13');
-INSERT INTO `hero` VALUES (22, '永恩求而不得', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 22, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-13 15:59:32', '2024-11-13 15:59:32');
-INSERT INTO `hero` VALUES (23, '包容的内马尔', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 23, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 15:07:16', '2024-11-14 15:07:16');
-INSERT INTO `hero` VALUES (24, '大罗一眼定情', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 24, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 15:08:44', '2024-11-14 15:08:44');
-INSERT INTO `hero` VALUES (25, '巴蒂斯图塔爆射世界杯', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 25, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 15:13:42', '2024-11-14 15:13:42');
-INSERT INTO `hero` VALUES (26, '托尼求而不得', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 26, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:04:42', '2024-11-14 17:04:42');
-INSERT INTO `hero` VALUES (27, '文静的一休', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 27, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 2, 0, 0, 0, 3, '2024-11-14 17:05:40', '2024-11-14 17:09:41');
-INSERT INTO `hero` VALUES (28, '朝气蓬勃的尤西比奥', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 28, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:06:23', '2024-11-14 17:06:23');
-INSERT INTO `hero` VALUES (29, '肖申克心花怒放', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 29, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:09:54', '2024-11-14 17:09:54');
-INSERT INTO `hero` VALUES (30, '害怕的哈吉', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 30, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:27:50', '2024-11-14 17:27:50');
-INSERT INTO `hero` VALUES (31, '罗马里奥完成了帽子戏法', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 31, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:27:56', '2024-11-14 17:27:56');
-INSERT INTO `hero` VALUES (32, '保罗舞力四射', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 32, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:28:43', '2024-11-14 17:28:43');
-INSERT INTO `hero` VALUES (33, '卡卡横扫千军', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 33, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:29:21', '2024-11-14 17:29:21');
-INSERT INTO `hero` VALUES (34, '贝克汉姆完成了帽子戏法', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 34, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '2024-11-14 17:31:53', '2024-11-14 17:31:53');
-INSERT INTO `hero` VALUES (35, '拼搏的雅典娜', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 35, 0, 1, 1420, 1300, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 2, 0, 0, 0, 3, '2024-11-14 17:31:57', '2024-11-14 17:31:57');
+INSERT INTO `hero` VALUES (1, '陶醉的永恩', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 1, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 12:13:04', '2024-11-13 12:13:04');
+INSERT INTO `hero` VALUES (2, '肖申克在巴黎徒步', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 2, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 12:25:50', '2024-11-13 12:25:50');
+INSERT INTO `hero` VALUES (3, '呆萌的乔布斯', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 3, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 14:42:35', '2024-11-13 14:42:35');
+INSERT INTO `hero` VALUES (4, '科比打豆豆', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 4, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 14:58:35', '2024-11-13 14:58:35');
+INSERT INTO `hero` VALUES (5, '细腻的普拉蒂尼', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 5, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 14:59:41', '2024-11-13 14:59:41');
+INSERT INTO `hero` VALUES (6, '风中的哈维', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 6, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:00:19', '2024-11-13 15:00:19');
+INSERT INTO `hero` VALUES (7, '野性的雅典娜', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 7, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:00:39', '2024-11-13 15:00:39');
+INSERT INTO `hero` VALUES (8, '柔弱的齐达內', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 8, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:01:22', '2024-11-13 15:01:22');
+INSERT INTO `hero` VALUES (9, '粗犷的姆巴佩', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 9, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:01:56', '2024-11-13 15:01:56');
+INSERT INTO `hero` VALUES (10, '一休走向人生巅峰', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 10, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:02:14', '2024-11-13 15:02:14');
+INSERT INTO `hero` VALUES (11, '欧文完成了帽子戏法', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 11, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:32:55', '2024-11-13 15:32:55');
+INSERT INTO `hero` VALUES (12, '听话的鲁尼', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 12, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:36:49', '2024-11-13 15:36:49');
+INSERT INTO `hero` VALUES (13, '尤西比奥一眼定情', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 13, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:37:03', '2024-11-13 15:37:03');
+INSERT INTO `hero` VALUES (14, '约翰·查尔斯在武汉看电影', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 14, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:45:45', '2024-11-13 15:45:45');
+INSERT INTO `hero` VALUES (15, '罗马里奥有亿点点忧伤', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 15, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:47:33', '2024-11-13 15:47:33');
+INSERT INTO `hero` VALUES (16, '巴乔横扫六合', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 16, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:48:42', '2024-11-13 15:48:42');
+INSERT INTO `hero` VALUES (17, '加林查吃爆米花', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 17, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:49:37', '2024-11-13 15:49:37');
+INSERT INTO `hero` VALUES (18, '懵懂的伊布', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 18, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:49:53', '2024-11-13 15:49:53');
+INSERT INTO `hero` VALUES (19, '普拉蒂尼掐指一算', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 19, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:51:38', '2024-11-13 15:51:38');
+INSERT INTO `hero` VALUES (20, '知性的贝利', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 20, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:51:49', '2024-11-13 15:51:49');
+INSERT INTO `hero` VALUES (21, '美好的永恩', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 21, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:59:13', '2024-11-13 15:59:13');
+INSERT INTO `hero` VALUES (22, '永恩求而不得', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 22, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-13 15:59:32', '2024-11-13 15:59:32');
+INSERT INTO `hero` VALUES (23, '包容的内马尔', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 23, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 15:07:16', '2024-11-14 15:07:16');
+INSERT INTO `hero` VALUES (24, '大罗一眼定情', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 24, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 15:08:44', '2024-11-14 15:08:44');
+INSERT INTO `hero` VALUES (25, '巴蒂斯图塔爆射世界杯', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 25, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 15:13:42', '2024-11-14 15:13:42');
+INSERT INTO `hero` VALUES (26, '托尼求而不得', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 26, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:04:42', '2024-11-14 17:04:42');
+INSERT INTO `hero` VALUES (27, '文静的一休', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 27, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 2, 0, 0, 0, 3, '1,2', '2024-11-14 17:05:40', '2024-11-14 17:09:41');
+INSERT INTO `hero` VALUES (28, '朝气蓬勃的尤西比奥', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 28, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:06:23', '2024-11-14 17:06:23');
+INSERT INTO `hero` VALUES (29, '肖申克心花怒放', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 29, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:09:54', '2024-11-14 17:09:54');
+INSERT INTO `hero` VALUES (30, '害怕的哈吉', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 30, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:27:50', '2024-11-14 17:27:50');
+INSERT INTO `hero` VALUES (31, '罗马里奥完成了帽子戏法', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 31, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:27:56', '2024-11-14 17:27:56');
+INSERT INTO `hero` VALUES (32, '保罗舞力四射', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 32, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:28:43', '2024-11-14 17:28:43');
+INSERT INTO `hero` VALUES (33, '卡卡横扫千军', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 33, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:29:21', '2024-11-14 17:29:21');
+INSERT INTO `hero` VALUES (34, '贝克汉姆完成了帽子戏法', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 34, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 1, 0, 0, 0, 3, '1,2', '2024-11-14 17:31:53', '2024-11-14 17:31:53');
+INSERT INTO `hero` VALUES (35, '拼搏的雅典娜', 'https://img2.baidu.com/it/u=3171875674,3530712457&fm=253&fmt=auto&app=120&f=JPEG?w=800&h=800', 0, 35, 0, 1, 1420, 1300, 0, 0, 44, 78, 1000, 1000, 5, 22, 28, 22, 20, 300, 2, 0, 0, 0, 3, '1,2', '2024-11-14 17:31:57', '2024-11-14 17:31:57');
+
+-- ----------------------------
+-- Table structure for hero_item
+-- ----------------------------
+DROP TABLE IF EXISTS `hero_item`;
+CREATE TABLE `hero_item`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `hero_id` bigint(20) NOT NULL,
+  `item_id` int(11) NOT NULL,
+  `count` int(11) NOT NULL DEFAULT 0 COMMENT '数量',
+  `slot` int(11) NOT NULL DEFAULT 0 COMMENT '背包格子',
+  `equip_pos` tinyint(4) NOT NULL DEFAULT 0 COMMENT '装备位置: 0 在背包内, 1 武器, 2 衣服, 3 头盔, 4 鞋子, 5 戒指',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `hero_nk`(`hero_id`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '角色拥有的物品' ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for hero_level
+-- ----------------------------
+DROP TABLE IF EXISTS `hero_level`;
+CREATE TABLE `hero_level`  (
+  `id` int(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `attr_type` tinyint(255) NOT NULL DEFAULT 0 COMMENT '属性类型:0 力量，1敏捷, 2智慧',
+  `level` int(11) NOT NULL DEFAULT 1,
+  `experience` bigint(20) NOT NULL DEFAULT 0 COMMENT '升到下一级需要的经验, 0为满级',
+  `strength` bigint(20) NOT NULL DEFAULT 0 COMMENT '升到该等级增加的力量',
+  `agility` bigint(20) NOT NULL DEFAULT 0 COMMENT '升到该等级增加的敏捷',
+  `intelligence` bigint(20) NOT NULL DEFAULT 0 COMMENT '升到该等级增加的智慧',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `attr_level_uk`(`attr_type`, `level`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 31 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '角色等级' ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Records of hero_level
+-- ----------------------------
+INSERT INTO `hero_level` VALUES (1, 0, 1, 100, 0, 0, 0, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (2, 0, 2, 400, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (3, 0, 3, 900, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (4, 0, 4, 1600, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (5, 0, 5, 2500, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (6, 0, 6, 3600, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (7, 0, 7, 4900, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (8, 0, 8, 6400, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (9, 0, 9, 8100, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (10, 0, 10, 0, 3, 1, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (11, 1, 1, 100, 0, 0, 0, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (12, 1, 2, 400, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (13, 1, 3, 900, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (14, 1, 4, 1600, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (15, 1, 5, 2500, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (16, 1, 6, 3600, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (17, 1, 7, 4900, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (18, 1, 8, 6400, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (19, 1, 9, 8100, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (20, 1, 10, 0, 1, 3, 1, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (21, 2, 1, 100, 0, 0, 0, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (22, 2, 2, 400, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (23, 2, 3, 900, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (24, 2, 4, 1600, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (25, 2, 5, 2500, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (26, 2, 6, 3600, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (27, 2, 7, 4900, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (28, 2, 8, 6400, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (29, 2, 9, 8100, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `hero_level` VALUES (30, 2, 10, 0, 1, 1, 3, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+
+-- ----------------------------
+-- Table structure for instance_record
+-- ----------------------------
+DROP TABLE IF EXISTS `instance_record`;
+CREATE TABLE `instance_record`  (
+  `id` int(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `instance_id` bigint(20) NOT NULL COMMENT '副本实例id',
+  `scene_id` int(11) NOT NULL COMMENT '副本模板场景',
+  `uids` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '参与的玩家',
+  `result` tinyint(4) NOT NULL DEFAULT 0 COMMENT '1 成功 2 超时 3 放弃',
+  `used_time` int(11) NOT NULL DEFAULT 0 COMMENT '用时(秒)',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `instance_uk`(`instance_id`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for item
+-- ----------------------------
+DROP TABLE IF EXISTS `item`;
+CREATE TABLE `item`  (
+  `id` int(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `icon` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '图标',
+  `item_type` tinyint(255) NOT NULL DEFAULT 0 COMMENT '物品类型: 0 材料，1 消耗品, 2 装备',
+  `max_stack` int(11) NOT NULL DEFAULT 1 COMMENT '最大堆叠数量',
+  `equip_pos` tinyint(4) NOT NULL DEFAULT 0 COMMENT '装备位置: 0 不能装备, 1 武器, 2 衣服, 3 头盔, 4 鞋子, 5 戒指',
+  `attack` int(11) NOT NULL DEFAULT 0 COMMENT '装备增加的攻击',
+  `defense` int(11) NOT NULL DEFAULT 0 COMMENT '装备增加的防御',
+  `life` int(11) NOT NULL DEFAULT 0 COMMENT '装备增加的生命上限, 消耗品恢复的生命',
+  `mana` int(11) NOT NULL DEFAULT 0 COMMENT '装备增加的魔法上限, 消耗品恢复的魔法',
+  `description` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 5 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '物品模板' ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Records of item
+-- ----------------------------
+INSERT INTO `item` VALUES (1, '兽皮', 'item1', 0, 99, 0, 0, 0, 0, 0, '怪物身上剥下来的皮', '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `item` VALUES (2, '小还丹', 'item2', 1, 20, 0, 0, 0, 100, 0, '恢复少量生命', '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `item` VALUES (3, '铁剑', 'item3', 2, 1, 1, 10, 0, 0, 0, '普通的铁剑', '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `item` VALUES (4, '布衣', 'item4', 2, 1, 2, 0, 5, 50, 0, '粗布做的衣服', '2024-11-20 10:00:00', '2024-11-20 10:00:00');
 
 -- ----------------------------
 -- Table structure for login
@@ -497,6 +739,7 @@ CREATE TABLE `monster`  (
   `attack_range` smallint(255) NOT NULL DEFAULT 5 COMMENT '攻击范围',
   `attack_duration` smallint(255) NOT NULL DEFAULT 0 COMMENT '攻击间隔',
   `description` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '简介',
+  `instance_scene_id` int(11) NOT NULL DEFAULT 0 COMMENT 'npc可以进入的副本',
   `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
   `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
   PRIMARY KEY (`id`) USING BTREE
@@ -505,10 +748,36 @@ CREATE TABLE `monster`  (
 -- ----------------------------
 -- Records of monster
 -- ----------------------------
-INSERT INTO `monster` VALUES (1, 'm1', 'monster1', 0, 1, 0, 0, 100, 100, 2, 5, 5, 2, 2, 2, 250, 300, 200, 250, 3, 2000, 'monster1', '2024-10-17 18:46:38', '2024-11-13 15:49:16');
-INSERT INTO `monster` VALUES (2, 'm2', 'monster2', 0, 2, 0, 1, 150, 120, 4, 10, 0, 3, 3, 3, 250, 300, 200, 250, 3, 2000, 'monster2', '2024-10-25 15:29:50', '2024-11-13 15:49:19');
-INSERT INTO `monster` VALUES (3, '场景1npc', 'npc1', 1, 100, 0, 0, 10000, 10000, 1000, 5000, 0, 200, 200, 200, 250, 300, 200, 250, 3, 1000, 'npc1', '2024-10-17 18:46:38', '2024-11-14 17:22:39');
-INSERT INTO `monster` VALUES (4, '场景2npc', 'npc1', 1, 100, 0, 0, 10000, 10000, 1000, 5000, 0, 200, 200, 200, 250, 300, 200, 250, 3, 1000, 'npc1', '2024-10-17 18:46:38', '2024-11-14 17:22:39');
+INSERT INTO `monster` VALUES (1, 'm1', 'monster1', 0, 1, 0, 0, 100, 100, 2, 5, 5, 2, 2, 2, 250, 300, 200, 250, 3, 2000, 'monster1', 0, '2024-10-17 18:46:38', '2024-11-13 15:49:16');
+INSERT INTO `monster` VALUES (2, 'm2', 'monster2', 0, 2, 0, 1, 150, 120, 4, 10, 0, 3, 3, 3, 250, 300, 200, 250, 3, 2000, 'monster2', 0, '2024-10-25 15:29:50', '2024-11-13 15:49:19');
+INSERT INTO `monster` VALUES (3, '场景1npc', 'npc1', 1, 100, 0, 0, 10000, 10000, 1000, 5000, 0, 200, 200, 200, 250, 300, 200, 250, 3, 1000, 'npc1', 0, '2024-10-17 18:46:38', '2024-11-14 17:22:39');
+INSERT INTO `monster` VALUES (4, '场景2npc', 'npc1', 1, 100, 0, 0, 10000, 10000, 1000, 5000, 0, 200, 200, 200, 250, 300, 200, 250, 3, 1000, 'npc1', 0, '2024-10-17 18:46:38', '2024-11-14 17:22:39');
+
+-- ----------------------------
+-- Table structure for monster_drop
+-- ----------------------------
+DROP TABLE IF EXISTS `monster_drop`;
+CREATE TABLE `monster_drop`  (
+  `id` int(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `monster_id` bigint(20) NOT NULL,
+  `item_id` int(11) NOT NULL DEFAULT 0 COMMENT '掉落的物品, 0为金币',
+  `min_count` int(11) NOT NULL DEFAULT 1,
+  `max_count` int(11) NOT NULL DEFAULT 1,
+  `probability` int(11) NOT NULL DEFAULT 0 COMMENT '掉落概率(万分比)',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `monster_nk`(`monster_id`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 6 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = '怪物掉落' ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Records of monster_drop
+-- ----------------------------
+INSERT INTO `monster_drop` VALUES (1, 1, 0, 1, 5, 8000, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `monster_drop` VALUES (2, 1, 1, 1, 2, 5000, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `monster_drop` VALUES (3, 2, 0, 2, 10, 8000, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `monster_drop` VALUES (4, 2, 2, 1, 1, 3000, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
+INSERT INTO `monster_drop` VALUES (5, 2, 3, 1, 1, 500, '2024-11-20 10:00:00', '2024-11-20 10:00:00');
 
 -- ----------------------------
 -- Table structure for online
@@ -600,6 +869,7 @@ CREATE TABLE `scene`  (
   `enterx` int(255) NOT NULL,
   `entery` int(255) NOT NULL,
   `enterz` int(255) NOT NULL,
+  `time_limit` int(11) NOT NULL DEFAULT 0 COMMENT '副本的时间限制(秒)',
   `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
   `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
   PRIMARY KEY (`id`) USING BTREE
@@ -608,8 +878,29 @@ CREATE TABLE `scene`  (
 -- ----------------------------
 -- Records of scene
 -- ----------------------------
-INSERT INTO `scene` VALUES (1, 'xinshoucun', '0', 'xinshoucun', 10, 140, 0, '2024-10-17 18:35:00', '2024-10-17 18:35:00');
-INSERT INTO `scene` VALUES (2, 'xinshoucun2', '0', 'xinshoucun', 10, 140, 0, '2024-10-17 18:35:00', '2024-10-17 18:35:00');
+INSERT INTO `scene` VALUES (1, 'xinshoucun', '0', 'xinshoucun', 10, 140, 0, 0, '2024-10-17 18:35:00', '2024-10-17 18:35:00');
+INSERT INTO `scene` VALUES (2, 'xinshoucun2', '0', 'xinshoucun', 10, 140, 0, 0, '2024-10-17 18:35:00', '2024-10-17 18:35:00');
+
+-- ----------------------------
+-- Table structure for scene_cell
+-- ----------------------------
+DROP TABLE IF EXISTS `scene_cell`;
+CREATE TABLE `scene_cell`  (
+  `id` int(10) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `scene_id` int(11) NOT NULL,
+  `service_addr` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'game节点在集群内的地址',
+  `node_addr` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'game节点之间同步ghost的地址',
+  `cell_index` int(11) NOT NULL DEFAULT -1 COMMENT '从左到右的序号, -1 未分配',
+  `min_x` int(11) NOT NULL DEFAULT 0,
+  `max_x` int(11) NOT NULL DEFAULT 0,
+  `width` int(11) NOT NULL DEFAULT 0 COMMENT '场景宽度',
+  `version` int(11) NOT NULL DEFAULT 0 COMMENT '划分的版本',
+  `heartbeat` bigint(20) NOT NULL DEFAULT 0 COMMENT '最后一次心跳的时间(毫秒)',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  `update_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0) ON UPDATE CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `scene_node_uk`(`scene_id`, `service_addr`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
 -- ----------------------------
 -- Table structure for scene_door
@@ -797,4 +1088,21 @@ INSERT INTO `user` VALUES (33, '', '', 2, 10, 2, '', 1731576560, '', '', 1, 1, 1
 INSERT INTO `user` VALUES (34, '', '', 2, 10, 2, '', 1731576712, '', '', 1, 1, 1, '2024-11-14 17:31:52', '2024-11-14 17:31:52');
 INSERT INTO `user` VALUES (35, '', '', 2, 10, 2, '', 1731576716, '', '', 1, 1, 1, '2024-11-14 17:31:56', '2024-11-14 17:31:56');
 
+-- ----------------------------
+-- Table structure for voice_clip
+-- ----------------------------
+DROP TABLE IF EXISTS `voice_clip`;
+CREATE TABLE `voice_clip`  (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT,
+  `file_id` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+  `uid` bigint(20) NOT NULL COMMENT '上传的玩家',
+  `size` int(11) NOT NULL DEFAULT 0 COMMENT '字节数',
+  `duration` int(11) NOT NULL DEFAULT 0 COMMENT '时长(毫秒)',
+  `upload_at` bigint(20) NOT NULL COMMENT '上传时间(毫秒)',
+  `create_at` datetime(0) NOT NULL DEFAULT CURRENT_TIMESTAMP(0),
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `file_id_uk`(`file_id`) USING BTREE,
+  INDEX `upload_at_idx`(`upload_at`) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 SET FOREIGN_KEY_CHECKS = 1;
@@ -19,6 +19,7 @@ require (
 	github.com/gorilla/mux v1.6.2
 	github.com/lonng/nano v0.5.1
 	github.com/lonng/nex v1.4.1
+	github.com/mattn/go-sqlite3 v1.14.24
 	github.com/pborman/uuid v1.2.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/viper v1.2.1
@@ -28,6 +29,7 @@ require (
 	golang.org/x/crypto v0.23.0
 	golang.org/x/net v0.25.0
 	golang.org/x/text v0.15.0
+	google.golang.org/grpc v1.39.0
 	gopkg.in/chanxuehong/wechat.v2 v2.0.0-20180924084534-7e0579cb5377
 )
 
@@ -46,7 +48,6 @@ require (
 	github.com/konsorten/go-windows-terminal-sequences v1.0.3 // indirect
 	github.com/lib/pq v1.10.9 // indirect
 	github.com/magiconair/properties v1.8.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.24 // indirect
 	github.com/mitchellh/mapstructure v1.0.0 // indirect
 	github.com/pelletier/go-toml v1.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
@@ -59,7 +60,6 @@ require (
 	golang.org/x/sys v0.20.0 // indirect
 	google.golang.org/appengine v1.5.0 // indirect
 	google.golang.org/genproto v0.0.0-20210630183607-d20f26d13c79 // indirect
-	google.golang.org/grpc v1.39.0 // indirect
 	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
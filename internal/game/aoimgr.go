@@ -1,22 +1,86 @@
 package game
 
 // AOI管理器
+// 每个场景的AOI算法可以通过配置选择: aoi.type是默认算法, aoi.scenes按"场景id:算法"单独指定
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nano/gameserver/constants"
 	"github.com/nano/gameserver/pkg/aoi"
 	"github.com/nano/gameserver/pkg/coord"
+	"github.com/spf13/viper"
+)
+
+const (
+	AOI_TYPE_GRID     = "grid"
+	AOI_TYPE_QUADTREE = "quadtree"
 )
 
 type aoiMgr struct {
 	aoi aoi.AOI
 }
 
-func newAoiMgr(areaWidth int, gridCount int) *aoiMgr {
+func newAoiMgr(aoiType string, areaWidth int, gridCount int) *aoiMgr {
 	mgr := &aoiMgr{}
-	//基于大格子算法的AOI，找的一个基于九宫格的aoi库直接使用了，自己也可以定义二维数组的方式实现
-	mgr.aoi = aoi.NewGridManager(0, 0, areaWidth, gridCount)
+	switch aoiType {
+	case AOI_TYPE_QUADTREE:
+		//四叉树, 对象分布不均匀的大地图更合适
+		mgr.aoi = aoi.NewQuadTree(0, 0, float64(areaWidth))
+	default:
+		//基于大格子算法的AOI，找的一个基于九宫格的aoi库直接使用了，自己也可以定义二维数组的方式实现
+		mgr.aoi = aoi.NewGridManager(0, 0, areaWidth, gridCount)
+	}
 	return mgr
 }
 
+// 解析"场景id:算法"的配置, 多个场景用逗号分隔, 例如"1:quadtree,2:grid"
+func parseAoiTypes(str string) (map[int]string, error) {
+	result := make(map[int]string)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		arr := strings.Split(item, ":")
+		if len(arr) != 2 {
+			return nil, fmt.Errorf("aoi配置格式错误: %s", item)
+		}
+		sceneId, err := strconv.Atoi(strings.TrimSpace(arr[0]))
+		if err != nil {
+			return nil, fmt.Errorf("aoi配置格式错误: %s", item)
+		}
+		aoiType := strings.TrimSpace(arr[1])
+		if aoiType != AOI_TYPE_GRID && aoiType != AOI_TYPE_QUADTREE {
+			return nil, fmt.Errorf("不支持的aoi算法: %s", item)
+		}
+		result[sceneId] = aoiType
+	}
+	return result, nil
+}
+
+// 场景使用的AOI算法, 没有配置时使用九宫格
+func sceneAoiType(sceneId int) string {
+	types, err := parseAoiTypes(viper.GetString("aoi.scenes"))
+	if err != nil {
+		logger.Errorf("解析aoi配置失败: %v", err)
+	} else if t, ok := types[sceneId]; ok {
+		return t
+	}
+	if t := viper.GetString("aoi.type"); t == AOI_TYPE_QUADTREE {
+		return t
+	}
+	return AOI_TYPE_GRID
+}
+
+// 按场景的宽高创建, 不是正方形时按长的一边
+func newSceneAoiMgr(sceneId int, width, height int) *aoiMgr {
+	size := max(width, height)
+	gridCount := max(size/constants.SCENE_AOI_GRID_SIZE, 1)
+	return newAoiMgr(sceneAoiType(sceneId), size, gridCount)
+}
+
 func (m *aoiMgr) Enter(entity IMovableEntity) {
 	m.aoi.Add(float64(entity.GetPos().X), float64(entity.GetPos().Y), entity.GetUUID(), entity)
 }
@@ -34,3 +98,13 @@ func (m *aoiMgr) Search(x, y coord.Coord) []interface{} {
 	result := m.aoi.Search(float64(x), float64(y))
 	return result
 }
+
+// 矩形范围内的对象, 范围可以超过九宫格
+func (m *aoiMgr) SearchRect(minX, minY, maxX, maxY coord.Coord) []interface{} {
+	return m.aoi.SearchRect(float64(minX), float64(minY), float64(maxX), float64(maxY))
+}
+
+// 圆形范围内的对象
+func (m *aoiMgr) SearchRadius(x, y, r coord.Coord) []interface{} {
+	return m.aoi.SearchRadius(float64(x), float64(y), float64(r))
+}
@@ -0,0 +1,42 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSceneAoiType(t *testing.T) {
+	viper.Set("aoi.scenes", "1:quadtree, 2:grid")
+	defer viper.Set("aoi.scenes", "")
+	assert.Equal(t, AOI_TYPE_QUADTREE, sceneAoiType(1))
+	assert.Equal(t, AOI_TYPE_GRID, sceneAoiType(2))
+	assert.Equal(t, AOI_TYPE_GRID, sceneAoiType(3))
+
+	_, err := parseAoiTypes("1:hex")
+	assert.Error(t, err)
+}
+
+func TestGetEntitiesByRange(t *testing.T) {
+	for _, aoiType := range []string{AOI_TYPE_GRID, AOI_TYPE_QUADTREE} {
+		s := &Scene{aoiMgr: newAoiMgr(aoiType, 600, 10)}
+		near := NewHero(nil, &model.Hero{Id: 1})
+		near.SetPos(110, 100, 0)
+		// 超过九宫格的范围
+		far := NewHero(nil, &model.Hero{Id: 2})
+		far.SetPos(300, 100, 0)
+		out := NewHero(nil, &model.Hero{Id: 3})
+		out.SetPos(100, 400, 0)
+		for _, h := range []*Hero{near, far, out} {
+			s.aoiMgr.Enter(h)
+		}
+
+		result := s.getEntitiesByRange(100, 100, coord.Coord(250))
+		assert.Len(t, result, 2, aoiType)
+		assert.Contains(t, result, near.GetUUID(), aoiType)
+		assert.Contains(t, result, far.GetUUID(), aoiType)
+	}
+}
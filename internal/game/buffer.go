@@ -22,6 +22,14 @@ func NewBuffer(target IEntity, state *model.BufferState) *Buffer {
 	return buf
 }
 
+// 从快照恢复，前端进入视野时会收到buffers，这里不再广播
+func restoreBuffer(target IEntity, o *object.BufferObject) *Buffer {
+	return &Buffer{
+		BufferObject: o,
+		target:       target,
+	}
+}
+
 func (buf *Buffer) Add(state *model.BufferState) {
 	//已经存在相同的
 	if state.Stackable != 0 {
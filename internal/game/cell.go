@@ -0,0 +1,266 @@
+package game
+
+// 大地图按x坐标切分成多个cell, 流程见protocol/cell.go
+// 当前节点只运行自己cell内的hero和monster(real), 相邻cell边界附近的实体以ghost的形式同步过来, 只用于显示
+// monster按出生点划分到cell, 重新划分后出生点不在自己cell内的monster直接移除, 由新的cell重新创建
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 上报心跳和读取划分的间隔
+	CELL_HEARTBEAT_INTERVAL = 2 * time.Second
+	// 同步给相邻cell的边界宽度(格子), 需要大于hero的视野范围
+	CELL_GHOST_MARGIN = 40
+	// 迁移失败后重试的间隔(毫秒)
+	CELL_MIGRATE_RETRY = 3000
+	// 超过这个时间没有同步的ghost删除, 相邻节点宕机时使用(毫秒)
+	CELL_GHOST_EXPIRE = 3000
+)
+
+// 当前节点负责的范围, 更新时整体替换, 可以在其他携程读取
+type sceneCell struct {
+	version   int
+	index     int //-1 master还没有分配
+	minX      int
+	maxX      int
+	neighbors []model.SceneCell
+}
+
+func (c *sceneCell) contains(x coord.Coord) bool {
+	return c.index >= 0 && int(x) >= c.minX && int(x) < c.maxX
+}
+
+// 需要同步给neighbor的位置
+func (c *sceneCell) nearBorder(x coord.Coord, neighbor *model.SceneCell) bool {
+	if !c.contains(x) {
+		return false
+	}
+	if neighbor.CellIndex < c.index {
+		return int(x) < c.minX+CELL_GHOST_MARGIN
+	}
+	return int(x) >= c.maxX-CELL_GHOST_MARGIN
+}
+
+// 配置了game-server.cell_scenes的场景按cell运行
+func isCellScene(sceneId int) bool {
+	for _, str := range strings.Split(viper.GetString("game-server.cell_scenes"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(str)); err == nil && id == sceneId {
+			return true
+		}
+	}
+	return false
+}
+
+// 与nano.Listen的地址一致, master按这个地址路由
+func cellServiceAddr() string {
+	return fmt.Sprintf(":%d", viper.GetInt("game-server.port"))
+}
+
+// 在场景携程内执行, 划分的版本变化后更新负责的范围
+func (s *Scene) applyCellLayout(cells []model.SceneCell) {
+	old := s.cell.Load()
+	if old == nil {
+		return
+	}
+	var self *model.SceneCell
+	for i := range cells {
+		if cells[i].ServiceAddr == cellServiceAddr() {
+			self = &cells[i]
+		}
+	}
+	if self == nil || self.CellIndex < 0 || self.Version <= old.version {
+		return
+	}
+	cell := &sceneCell{
+		version: self.Version,
+		index:   self.CellIndex,
+		minX:    self.MinX,
+		maxX:    self.MaxX,
+	}
+	for _, c := range cells {
+		if c.Version == self.Version && (c.CellIndex == self.CellIndex-1 || c.CellIndex == self.CellIndex+1) {
+			cell.neighbors = append(cell.neighbors, c)
+		}
+	}
+	s.cell.Store(cell)
+	logger.Infof("scene:%d cell划分变化, 版本:%d, 序号:%d, 范围:[%d,%d), 相邻:%d", s.sceneId, cell.version, cell.index, cell.minX, cell.maxX, len(cell.neighbors))
+	s.rebalanceMonsters(cell)
+}
+
+// 出生点不在自己cell内的monster交给其他cell, 新划分进来的出生点创建monster
+// 不在自己cell内的hero在自己的update里迁移
+func (s *Scene) rebalanceMonsters(cell *sceneCell) {
+	s.monsters.Range(func(key, value any) bool {
+		m := value.(*Monster)
+		if m.cfg != nil && !cell.contains(coord.Coord(m.cfg.Bornx)) {
+			m.PushTask(m.Destroy)
+		}
+		return true
+	})
+	s.rebornMonsters.Range(func(key, value any) bool {
+		if !cell.contains(coord.Coord(value.(*rebornMonster).Cfg.Bornx)) {
+			s.rebornMonsters.Delete(key)
+		}
+		return true
+	})
+	for _, cfg := range s.sceneData.MonsterConfigList {
+		in := cell.contains(coord.Coord(cfg.Bornx))
+		if !in || s.cellMonsterCfgs[cfg.Id] {
+			s.cellMonsterCfgs[cfg.Id] = in
+			continue
+		}
+		if err := s.initMonsterByConfig(cfg); err != nil {
+			logger.Errorf("scene:%d 创建monster:%d 失败: %v", s.sceneId, cfg.Id, err)
+			continue
+		}
+		s.cellMonsterCfgs[cfg.Id] = true
+	}
+}
+
+// 复活时出生点已经不在自己的cell内了
+func (s *Scene) ownsMonsterConfig(cfg *model.SceneMonsterConfig) bool {
+	cell := s.cell.Load()
+	return cell == nil || cell.contains(coord.Coord(cfg.Bornx))
+}
+
+// 在hero的task内执行, 走出自己的cell后通知master迁移到相邻的cell
+func (h *Hero) checkCell(now int64) {
+	if h.scene == nil || h.session == nil {
+		return
+	}
+	cell := h.scene.cell.Load()
+	if cell == nil || cell.index < 0 {
+		return
+	}
+	pos := h.GetPos()
+	if cell.contains(pos.X) || now-h.cellMigrateAt < CELL_MIGRATE_RETRY {
+		return
+	}
+	h.cellMigrateAt = now
+	req := &protocol.HeroCellMigrateRequest{
+		Uid:     h.GetUID(),
+		HeroId:  h.GetID(),
+		SceneId: h.scene.sceneId,
+		PosX:    pos.X,
+		PosY:    pos.Y,
+	}
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+	logger.Debugf("hero:%d-%s 走出cell:%d, 位置:%d", h.GetID(), h._name, cell.index, pos.X)
+	if err := h.session.RPC("Manager.HeroCellMigrate", req); err != nil {
+		logger.Errorf("rpc.Call(Manager.HeroCellMigrate) err: %v", err)
+	}
+}
+
+// 迁移前通知客户端删除不会同步到其他cell的对象, hero和monster由新的cell以ghost或者real的形式继续显示
+func (h *Hero) clearCellOnlyViews(s *session.Session) {
+	h.viewList.Range(func(key, value interface{}) bool {
+		if item, ok := value.(*GroundItem); ok {
+			s.Push(protocol.OnExitView, protocol.TargetExitViewResponse{
+				EntityType: item.GetEntityType(),
+				ID:         item.GetID(),
+			})
+		}
+		return true
+	})
+}
+
+// 在场景携程内执行, 把边界附近的real同步给相邻的cell
+func (s *Scene) syncGhosts(now int64) {
+	cell := s.cell.Load()
+	if cell == nil || cell.index < 0 {
+		return
+	}
+	for i := range cell.neighbors {
+		neighbor := &cell.neighbors[i]
+		req := &protocol.CellGhostSyncRequest{
+			SceneId:   s.sceneId,
+			FromAddr:  cellServiceAddr(),
+			Version:   cell.version,
+			Timestamp: now,
+			Heros:     make([]*object.HeroObject, 0),
+			Monsters:  make([]*object.MonsterObject, 0),
+		}
+		s.heros.Range(func(key, value any) bool {
+			h := value.(*Hero)
+			if cell.nearBorder(h.GetPos().X, neighbor) {
+				o := *h.HeroObject
+				req.Heros = append(req.Heros, &o)
+			}
+			return true
+		})
+		s.monsters.Range(func(key, value any) bool {
+			m := value.(*Monster)
+			if m.IsAlive() && cell.nearBorder(m.GetPos().X, neighbor) {
+				o := *m.MonsterObject
+				req.Monsters = append(req.Monsters, &o)
+			}
+			return true
+		})
+		req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+		addr := neighbor.NodeAddr
+		async.Run(func() {
+			if err := defaultNodeLink.notify(addr, "SceneManager.CellGhostSync", req); err != nil {
+				logger.Warningf("scene:%d 同步ghost到%s失败: %v", s.sceneId, addr, err)
+			}
+		})
+	}
+}
+
+// 相邻的cell同步过来的ghost
+func (manager *SceneManager) CellGhostSync(s *session.Session, req *protocol.CellGhostSyncRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("scene:%d 同步ghost签名错误, from:%s", req.SceneId, req.FromAddr)
+		return errutil.ErrPermissionDenied
+	}
+	scene := manager.scenes[req.SceneId]
+	if scene == nil || scene.cell.Load() == nil {
+		return fmt.Errorf("scene:%d not found", req.SceneId)
+	}
+	scene.PushTask(func() {
+		scene.applyGhosts(req, time.Now().UnixMilli())
+	})
+	return nil
+}
+
+// 每个节点定时上报自己负责的大地图, 读取master的划分
+func (manager *SceneManager) cellHeartbeat() {
+	now := time.Now().UnixMilli()
+	for _, scene := range manager.scenes {
+		if scene.cell.Load() == nil {
+			continue
+		}
+		err := db.SaveSceneCellHeartbeat(&model.SceneCell{
+			SceneId:     scene.sceneId,
+			ServiceAddr: cellServiceAddr(),
+			NodeAddr:    nodeAddr(),
+			Width:       int(scene.GetWidth()),
+			Heartbeat:   now,
+		})
+		if err != nil {
+			logger.Errorf("scene:%d 上报cell心跳失败: %v", scene.sceneId, err)
+			continue
+		}
+		cells, err := db.SceneCellListByScene(scene.sceneId)
+		if err != nil {
+			logger.Errorf("scene:%d 读取cell划分失败: %v", scene.sceneId, err)
+			continue
+		}
+		scene.PushTask(func() {
+			scene.applyCellLayout(cells)
+		})
+	}
+}
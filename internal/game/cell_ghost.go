@@ -0,0 +1,179 @@
+package game
+
+// 相邻cell同步过来的hero和monster, 只在当前节点的AOI内显示, 不能攻击也不会被攻击
+// 只在场景携程内创建和更新
+import (
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/protocol"
+)
+
+type GhostEntity struct {
+	movableEntity
+	data     interface{} //*object.HeroObject 或者 *object.MonsterObject
+	realType int
+	realUuid string //real在原来节点上的uuid
+	fromAddr string
+	life     int64
+	syncAt   int64
+}
+
+func NewGhostEntity(id int64, name string, realType int, realUuid string, fromAddr string) *GhostEntity {
+	e := &GhostEntity{
+		realType: realType,
+		realUuid: realUuid,
+		fromAddr: fromAddr,
+	}
+	e.initEntity(id, "ghost"+name, constants.ENTITY_TYPE_GHOST, 16)
+	return e
+}
+
+func (e *GhostEntity) SetPos(x, y, z coord.Coord) {
+	oldx, oldy := e.GetPos().X, e.GetPos().Y
+	e.movableEntity.SetPos(x, y, z)
+	if e.scene != nil && (oldx != x || oldy != y) {
+		e.scene.entityMoved(e, x, y, oldx, oldy)
+	}
+}
+
+// 更新数据, 位置和生命变化时通知能看见ghost的hero
+func (e *GhostEntity) sync(data interface{}, pos coord.Vector3, life, maxLife int64, now int64) {
+	e.data = data
+	e.syncAt = now
+	if pos != e.GetPos() {
+		e.SetPos(pos.X, pos.Y, pos.Z)
+		var route string
+		var msg interface{}
+		if e.realType == constants.ENTITY_TYPE_HERO {
+			route, msg = protocol.OnHeroMoveStopped, &protocol.HeroMoveStopResponse{ID: e.GetID(), PosX: pos.X, PosY: pos.Y, PosZ: pos.Z}
+		} else {
+			route, msg = protocol.OnMonsterMoveStopped, &protocol.MonsterMoveStopResponse{ID: e.GetID(), PosX: pos.X, PosY: pos.Y, PosZ: pos.Z}
+		}
+		e.broadcast(route, msg)
+	}
+	if life != e.life {
+		e.broadcast(protocol.OnLifeChanged, &protocol.LifeChangedResponse{
+			ID:         e.GetID(),
+			EntityType: e.realType,
+			Damage:     e.life - life,
+			Life:       life,
+			MaxLife:    maxLife,
+		})
+		e.life = life
+	}
+}
+
+func (e *GhostEntity) broadcast(route string, msg interface{}) {
+	e.canSeeMeViewList.Range(func(key, value interface{}) bool {
+		if h, ok := value.(*Hero); ok {
+			h.SendMsg(route, msg)
+		}
+		return true
+	})
+}
+
+func (e *GhostEntity) Destroy() {
+	e.destroy(true)
+}
+
+// notify为false时不通知客户端, 同一个对象的real进入了当前节点, 客户端继续显示
+func (e *GhostEntity) destroy(notify bool) {
+	if e.scene != nil {
+		e.scene.removeGhost(e)
+	}
+	e.canSeeMeViewList.Range(func(key, value interface{}) bool {
+		if h, ok := value.(*Hero); ok && !notify {
+			h.movableEntity.onExitView(e)
+		} else {
+			value.(IMovableEntity).onExitView(e)
+		}
+		return true
+	})
+	e.movableEntity.Destroy()
+}
+
+func (s *Scene) addGhost(e *GhostEntity) {
+	e.onEnterScene(s)
+	s.ghosts.Store(e.realUuid, e)
+	s.aoiMgr.Enter(e)
+	s.addToBuildViewList(e)
+}
+
+func (s *Scene) removeGhost(e *GhostEntity) {
+	s.aoiMgr.Leave(e)
+	s.leaveViewGrid(e)
+	s.ghosts.Delete(e.realUuid)
+	e.onExitScene(s)
+}
+
+// 在场景携程内执行, 用发送方的全量数据更新ghost, 不在列表内的删除
+func (s *Scene) applyGhosts(req *protocol.CellGhostSyncRequest, now int64) {
+	cell := s.cell.Load()
+	if cell == nil || cell.version != req.Version {
+		// 重新划分中, 双方的版本一致后再同步
+		return
+	}
+	seen := make(map[string]bool)
+	for _, o := range req.Heros {
+		if _, ok := s.heros.Load(o.Id); ok {
+			// real已经迁移到当前节点了
+			continue
+		}
+		seen[o.Uuid] = true
+		pos := coord.Vector3{X: o.Posx, Y: o.Posy, Z: o.Posz}
+		s.loadOrAddGhost(o.Id, o.Name, constants.ENTITY_TYPE_HERO, o.Uuid, req.FromAddr, pos).sync(o, pos, o.Life, o.MaxLife, now)
+	}
+	for _, o := range req.Monsters {
+		seen[o.Uuid] = true
+		pos := coord.Vector3{X: o.Posx, Y: o.Posy, Z: o.Posz}
+		s.loadOrAddGhost(o.Id, o.Name, constants.ENTITY_TYPE_MONSTER, o.Uuid, req.FromAddr, pos).sync(o, pos, o.Life, o.MaxLife, now)
+	}
+	s.ghosts.Range(func(key, value any) bool {
+		e := value.(*GhostEntity)
+		if e.fromAddr == req.FromAddr && !seen[e.realUuid] {
+			e.destroy(!s.hasRealHero(e))
+		}
+		return true
+	})
+}
+
+func (s *Scene) loadOrAddGhost(id int64, name string, realType int, realUuid string, fromAddr string, pos coord.Vector3) *GhostEntity {
+	if v, ok := s.ghosts.Load(realUuid); ok {
+		return v.(*GhostEntity)
+	}
+	e := NewGhostEntity(id, name, realType, realUuid, fromAddr)
+	e.SetPos(pos.X, pos.Y, pos.Z)
+	s.addGhost(e)
+	return e
+}
+
+// hero从相邻的cell迁移进来, 删除它的ghost
+func (s *Scene) replaceGhostHero(heroId int64) {
+	s.ghosts.Range(func(key, value any) bool {
+		e := value.(*GhostEntity)
+		if e.realType == constants.ENTITY_TYPE_HERO && e.GetID() == heroId {
+			e.destroy(false)
+		}
+		return true
+	})
+}
+
+// real迁移到当前节点后删除ghost时不能通知客户端, 客户端会按id删除real
+func (s *Scene) hasRealHero(e *GhostEntity) bool {
+	if e.realType != constants.ENTITY_TYPE_HERO {
+		return false
+	}
+	_, ok := s.heros.Load(e.GetID())
+	return ok
+}
+
+// 相邻节点宕机或者不再相邻时删除过期的ghost
+func (s *Scene) expireGhosts(now int64) {
+	s.ghosts.Range(func(key, value any) bool {
+		e := value.(*GhostEntity)
+		if now-e.syncAt > CELL_GHOST_EXPIRE {
+			e.destroy(!s.hasRealHero(e))
+		}
+		return true
+	})
+}
@@ -0,0 +1,114 @@
+package game
+
+// game节点下线流程: 通知master -> 拒绝进入场景 -> 倒计时通知hero -> 保存hero和快照 -> 停止场景 -> 退出
+import (
+	"time"
+
+	"github.com/lonng/nano"
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	DRAIN_DEFAULT_SECONDS = 30
+)
+
+func drainSeconds() int {
+	if viper.IsSet("game-server.drain_seconds") {
+		return viper.GetInt("game-server.drain_seconds")
+	}
+	return DRAIN_DEFAULT_SECONDS
+}
+
+// 收到SIGTERM等信号时nano会调用, 阻塞到下线流程执行完
+func (manager *SceneManager) BeforeShutdown() {
+	manager.drain(drainSeconds())
+}
+
+// 管理员调用, 下线流程执行完后关闭进程
+func (manager *SceneManager) Drain(s *session.Session, req *protocol.DrainRequest) error {
+	token := viper.GetString("game-server.admin_token")
+	if token == "" || req.Token != token {
+		return errutil.ErrPermissionDenied
+	}
+	seconds := req.Seconds
+	if seconds <= 0 {
+		seconds = drainSeconds()
+	}
+	logger.Infof("管理员请求下线game节点, 倒计时:%d秒", seconds)
+	// handler在nano的同一条线程执行，这里不能阻塞
+	async.Run(func() {
+		manager.drain(seconds)
+		nano.Shutdown()
+	})
+	return nil
+}
+
+func (manager *SceneManager) IsDraining() bool {
+	return manager.draining.Load()
+}
+
+// 多次调用只会执行一次, 并发调用的会等待第一次执行完成
+func (manager *SceneManager) drain(seconds int) {
+	manager.drainOnce.Do(func() {
+		logger.Infof("game节点开始下线, 场景:%v", manager.sceneIds)
+		manager.draining.Store(true)
+		manager.notifyMasterDraining(seconds)
+		manager.drainCountdown(seconds)
+
+		for _, scene := range manager.allScenes() {
+			scene.flushHeros(HERO_FLUSH_TIMEOUT)
+		}
+		if err := db.FlushHeroes(HERO_FLUSH_TIMEOUT); err != nil {
+			logger.Errorf("flush heros error:%v", err)
+		}
+		if err := flushBags(HERO_FLUSH_TIMEOUT); err != nil {
+			logger.Errorf("flush bags error:%v", err)
+		}
+		// 副本不使用快照, 分线和常驻场景一样需要保存
+		for _, scene := range manager.residentScenes() {
+			if err := scene.saveSnapshot(); err != nil {
+				logger.Errorf("scene:%d save snapshot error:%v", scene.GetSceneId(), err)
+			}
+		}
+		for _, scene := range manager.allScenes() {
+			scene.Stop()
+		}
+		logger.Infof("game节点下线完成")
+	})
+}
+
+// master收到后不再把hero分配到这些场景, 没有在线的hero时也要通知, 直接发给master节点
+func (manager *SceneManager) notifyMasterDraining(seconds int) {
+	req := &protocol.GameNodeDrainingRequest{
+		SceneIds: manager.sceneIds,
+		Seconds:  seconds,
+	}
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+	if err := defaultNodeLink.notify(masterAddr(), "Manager.GameNodeDraining", req); err != nil {
+		logger.Errorf("通知master节点下线失败: %v", err)
+	}
+}
+
+// 所有hero都离开后提前结束倒计时
+func (manager *SceneManager) drainCountdown(seconds int) {
+	for remain := seconds; remain > 0; remain-- {
+		total := 0
+		for _, scene := range manager.allScenes() {
+			total += scene.totalPlayerCount()
+		}
+		if total == 0 {
+			return
+		}
+		if remain == seconds || remain <= 10 || remain%10 == 0 {
+			for _, scene := range manager.allScenes() {
+				scene.broadcastAll(protocol.OnServerDrain, &protocol.ServerDrainResponse{Seconds: remain})
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
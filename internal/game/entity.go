@@ -2,6 +2,7 @@ package game
 
 import (
 	"fmt"
+	"sync"
 	"sync/atomic"
 
 	"github.com/google/uuid"
@@ -11,43 +12,25 @@ import (
 
 type Entity struct {
 	scene *Scene
-	// nano的handler都在一条go scheduler.Sched()线程中执行的，
-	// 所以这里定义每个对象都需要有自己的独立运行的携程
-	_chTasks        chan scheduler.Task
-	_chTasksBufSize int
-	_chDestroy      chan struct{}
-	_uuid           string // 不存储在数据库，只作为运行对象的唯一值
-	_id             int64
-	_name           string
-	_entityType     int
-	_pos            coord.Vector3
-	_destroyed      atomic.Bool
+	// 对象没有自己的携程, task都投递到所在场景的携程内按顺序执行
+	// 不在场景内时(进入场景之前、切换场景中)先缓存起来, 进入场景后再投递
+	_pendingMu       sync.Mutex
+	_pendingTasks    []scheduler.Task
+	_pendingTasksMax int
+	_uuid            string // 不存储在数据库，只作为运行对象的唯一值
+	_id              int64
+	_name            string
+	_entityType      int
+	_pos             coord.Vector3
+	_destroyed       atomic.Bool
 }
 
 func (e *Entity) initEntity(id int64, name string, entityType int, bufSize int) {
-	e._chTasksBufSize = bufSize
-	e._chTasks = make(chan scheduler.Task, bufSize)
-	e._chDestroy = make(chan struct{})
+	e._pendingTasksMax = bufSize
 	e._uuid = uuid.New().String()
 	e._id = id
 	e._name = name
 	e._entityType = entityType
-	go e._tasksFunc()
-}
-
-func (e *Entity) _tasksFunc() {
-	for {
-		select {
-		case <-e._chDestroy:
-			logger.Printf("destroy entity:%d-%s-%s\n", e.GetID(), e._name, e._uuid)
-			e.scene = nil
-			e._name += "_destroyed"
-			e._destroyed.Store(true)
-			return
-		case task := <-e._chTasks:
-			e._doTask(task)
-		}
-	}
 }
 
 func (e *Entity) _doTask(f func()) {
@@ -59,41 +42,77 @@ func (e *Entity) _doTask(f func()) {
 	f()
 }
 
+// 可以在任意携程调用, task在对象所在场景的携程内执行
 func (e *Entity) PushTask(task scheduler.Task) {
-	//todo 这里的task内可能会再有PushTask的调用，如果缓存区满后可能会导致死锁住，所以这里开携程了
 	if e._destroyed.Load() {
 		return
 	}
-	if len(e._chTasks) >= e._chTasksBufSize {
-		logger.Errorf("Entity:%d-%s task buffer is full 开启携程", e._id, e._name)
-		go func() {
-			e._chTasks <- task
-		}()
+	e._pendingMu.Lock()
+	scene := e.scene
+	if scene == nil {
+		defer e._pendingMu.Unlock()
+		if len(e._pendingTasks) >= e._pendingTasksMax {
+			logger.Errorf("Entity:%d-%s 不在场景内, 缓存的task已满, 丢弃", e._id, e._name)
+			return
+		}
+		e._pendingTasks = append(e._pendingTasks, task)
 		return
 	}
-	e._chTasks <- task
+	e._pendingMu.Unlock()
+	e.pushSceneTask(scene, task)
+}
+
+func (e *Entity) pushSceneTask(scene *Scene, task scheduler.Task) {
+	scene.PushTask(func() {
+		if e._destroyed.Load() {
+			return
+		}
+		if e.GetScene() != scene {
+			// 执行前已经切换了场景, 转发给新的场景
+			e.PushTask(task)
+			return
+		}
+		e._doTask(task)
+	})
 }
 
 func (e *Entity) Destroy() {
-	if e._destroyed.Load() {
+	if !e._destroyed.CompareAndSwap(false, true) {
 		return
 	}
-	close(e._chDestroy)
+	logger.Printf("destroy entity:%d-%s-%s\n", e.GetID(), e._name, e._uuid)
+	e._pendingMu.Lock()
+	e.scene = nil
+	e._pendingTasks = nil
+	e._pendingMu.Unlock()
+	e._name += "_destroyed"
 }
 
 func (e *Entity) IsDestroyed() bool {
 	return e._destroyed.Load()
 }
 
+// 进入场景后按顺序投递不在场景内时缓存的task
 func (e *Entity) onEnterScene(scene *Scene) {
+	e._pendingMu.Lock()
+	defer e._pendingMu.Unlock()
 	e.scene = scene
+	for _, task := range e._pendingTasks {
+		e.pushSceneTask(scene, task)
+	}
+	e._pendingTasks = nil
 }
 
 func (e *Entity) onExitScene(scene *Scene) {
+	e._pendingMu.Lock()
+	defer e._pendingMu.Unlock()
 	e.scene = nil
 }
 
+// 切换场景时在其他携程修改, 需要加锁读取
 func (e *Entity) GetScene() *Scene {
+	e._pendingMu.Lock()
+	defer e._pendingMu.Unlock()
 	return e.scene
 }
 
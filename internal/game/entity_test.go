@@ -0,0 +1,278 @@
+package game
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lonng/nano/scheduler"
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntityPushTask(t *testing.T) {
+	s := &Scene{chTasks: make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE)}
+	h := NewHero(nil, &model.Hero{Id: 1})
+	defer close(h.destroyCh)
+
+	// 不在场景内时缓存, 进入场景后按顺序投递到场景
+	result := make([]int, 0)
+	h.PushTask(func() { result = append(result, 1) })
+	assert.Empty(t, s.chTasks)
+	h.Entity.onEnterScene(s)
+	h.PushTask(func() { result = append(result, 2) })
+	assert.Len(t, s.chTasks, 2)
+	for len(s.chTasks) > 0 {
+		s._doTask(<-s.chTasks)
+	}
+	assert.Equal(t, []int{1, 2}, result)
+
+	// 执行前切换了场景的转发给新的场景
+	s2 := &Scene{chTasks: make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE)}
+	h.PushTask(func() { result = append(result, 3) })
+	h.Entity.onEnterScene(s2)
+	s._doTask(<-s.chTasks)
+	assert.Len(t, s2.chTasks, 1)
+	s2._doTask(<-s2.chTasks)
+	assert.Equal(t, []int{1, 2, 3}, result)
+
+	h.Entity.Destroy()
+	h.PushTask(func() { result = append(result, 4) })
+	assert.Empty(t, s2.chTasks)
+}
+
+// 场景携程执行task时其他携程切换场景, 用-race检查
+func TestScenePushTaskOverflow(t *testing.T) {
+	s := &Scene{chTasks: make(chan scheduler.Task, 2)}
+	result := make([]int, 0)
+	push := func(i int) {
+		s.PushTask(func() { result = append(result, i) })
+	}
+	// 缓冲区满了不阻塞, 之后的任务排在overflow后面
+	for i := 1; i <= 4; i++ {
+		push(i)
+	}
+	assert.Len(t, s.chTasks, 2)
+	assert.Len(t, s.overflow, 2)
+	// 场景携程内继续投递也不会阻塞
+	s.PushTask(func() {
+		result = append(result, 5)
+		push(7)
+	})
+	push(6)
+	for len(s.chTasks) > 0 {
+		s._doTask(<-s.chTasks)
+		s._doOverflow()
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7}, result)
+	assert.Empty(t, s.overflow)
+}
+
+func TestEntitySwitchSceneConcurrent(t *testing.T) {
+	s1 := &Scene{chTasks: make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE)}
+	s2 := &Scene{chTasks: make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE)}
+	h := NewHero(nil, &model.Hero{Id: 1})
+	defer close(h.destroyCh)
+	h.Entity.onEnterScene(s1)
+
+	var done sync.WaitGroup
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		for i := 0; i < 100; i++ {
+			h.Entity.onExitScene(s1)
+			h.Entity.onEnterScene(s2)
+			h.Entity.onExitScene(s2)
+			h.Entity.onEnterScene(s1)
+		}
+	}()
+	var count atomic.Int32
+	for i := 0; i < 100; i++ {
+		h.PushTask(func() { count.Add(1) })
+		for len(s1.chTasks) > 0 {
+			s1._doTask(<-s1.chTasks)
+		}
+	}
+	done.Wait()
+	for len(s1.chTasks) > 0 || len(s2.chTasks) > 0 {
+		select {
+		case task := <-s1.chTasks:
+			s1._doTask(task)
+		case task := <-s2.chTasks:
+			s2._doTask(task)
+		}
+	}
+	assert.Equal(t, int32(100), count.Load())
+}
+
+// 只记录关闭次数的连接
+type closeCountEntity struct {
+	closed atomic.Int32
+}
+
+func (e *closeCountEntity) Push(route string, v interface{}) error      { return nil }
+func (e *closeCountEntity) RPC(route string, v interface{}) error       { return nil }
+func (e *closeCountEntity) LastMid() uint64                             { return 0 }
+func (e *closeCountEntity) Response(v interface{}) error                { return nil }
+func (e *closeCountEntity) ResponseMid(mid uint64, v interface{}) error { return nil }
+func (e *closeCountEntity) RemoteAddr() net.Addr                        { return nil }
+func (e *closeCountEntity) Close() error {
+	e.closed.Add(1)
+	return nil
+}
+
+func TestHeroSendOverflow(t *testing.T) {
+	entity := &closeCountEntity{}
+	h := NewHero(session.New(entity), &model.Hero{Id: 1})
+	defer close(h.destroyCh)
+
+	// 消息堆积已满时只断开一次连接
+	h.onSendOverflow(protocol.OnEnterView)
+	h.onSendOverflow(protocol.OnExitView)
+	assert.Eventually(t, func() bool { return entity.closed.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), entity.closed.Load())
+}
+
+// 改为场景携程之前的对象模型: 每个对象一个携程和task通道, 场景每帧给每个对象投递一个update
+type legacyEntity struct {
+	movableEntity
+	chTasks chan func()
+	chStop  chan struct{}
+}
+
+func newLegacyEntity(id int64) *legacyEntity {
+	e := &legacyEntity{chTasks: make(chan func(), 128), chStop: make(chan struct{})}
+	e.initEntity(id, "legacy", constants.ENTITY_TYPE_MONSTER, 128)
+	go func() {
+		for {
+			select {
+			case <-e.chStop:
+				return
+			case task := <-e.chTasks:
+				task()
+			}
+		}
+	}()
+	return e
+}
+
+func newBenchEntity(id int64) *movableEntity {
+	e := &movableEntity{}
+	e.initEntity(id, "bench", constants.ENTITY_TYPE_MONSTER, 128)
+	return e
+}
+
+// 创建n个对象后的内存, 包括携程的栈
+func entityMemory(n int, create func(i int) func()) (uint64, int) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	base := runtime.NumGoroutine()
+	stops := make([]func(), n)
+	for i := range stops {
+		stops[i] = create(i)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	goroutines := runtime.NumGoroutine() - base
+	for _, stop := range stops {
+		stop()
+	}
+	// 等携程都退出, 不影响下一次统计
+	for runtime.NumGoroutine() > base {
+		time.Sleep(time.Millisecond)
+	}
+	mem := int64(after.HeapAlloc+after.StackInuse) - int64(before.HeapAlloc+before.StackInuse)
+	return uint64(max(mem, 0)) / uint64(n), goroutines
+}
+
+// 每次模拟一帧: 更新所有对象, 等待全部完成
+func BenchmarkEntityTick(b *testing.B) {
+	ts := time.Now().UnixMilli()
+	for _, n := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("goroutine-%d", n), func(b *testing.B) {
+			entities := make([]*legacyEntity, n)
+			for i := range entities {
+				entities[i] = newLegacyEntity(int64(i))
+			}
+			defer func() {
+				for _, e := range entities {
+					close(e.chStop)
+				}
+			}()
+			var wg sync.WaitGroup
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				wg.Add(n)
+				for _, e := range entities {
+					e.chTasks <- func() {
+						e.update(ts, 100)
+						wg.Done()
+					}
+				}
+				wg.Wait()
+			}
+		})
+		b.Run(fmt.Sprintf("scene-%d", n), func(b *testing.B) {
+			entities := make([]*movableEntity, n)
+			for i := range entities {
+				entities[i] = newBenchEntity(int64(i))
+			}
+			s := &Scene{chTasks: make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE)}
+			for _, e := range entities {
+				e.Entity.onEnterScene(s)
+			}
+			go func() {
+				for task := range s.chTasks {
+					s._doTask(task)
+				}
+			}()
+			defer close(s.chTasks)
+			done := make(chan struct{})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.PushTask(func() {
+					for _, e := range entities {
+						s.updateEntity(&e.Entity, func() error {
+							return e.update(ts, 100)
+						})
+					}
+					done <- struct{}{}
+				})
+				<-done
+			}
+		})
+	}
+}
+
+func BenchmarkEntityMemory(b *testing.B) {
+	const n = 10000
+	b.Run("goroutine", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mem, goroutines := entityMemory(n, func(i int) func() {
+				e := newLegacyEntity(int64(i))
+				return func() { close(e.chStop) }
+			})
+			b.ReportMetric(float64(mem), "B/entity")
+			b.ReportMetric(float64(goroutines), "goroutines")
+		}
+	})
+	b.Run("scene", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mem, goroutines := entityMemory(n, func(i int) func() {
+				e := newBenchEntity(int64(i))
+				return func() { _ = e }
+			})
+			b.ReportMetric(float64(mem), "B/entity")
+			b.ReportMetric(float64(goroutines), "goroutines")
+		}
+	})
+}
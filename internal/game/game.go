@@ -10,6 +10,8 @@ import (
 	"github.com/lonng/nano"
 	"github.com/lonng/nano/component"
 	"github.com/lonng/nano/serialize/json"
+	"github.com/nano/gameserver/pkg/algoutil"
+	"github.com/nano/gameserver/pkg/sceneline"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -22,6 +24,10 @@ var (
 
 // Startup 初始化游戏服务器
 func Startup(scenes string) {
+	// 签名密钥没有配置时任何客户端都可以伪造节点之间的请求
+	if err := algoutil.CheckClusterSecret(viper.GetString("cluster.secret")); err != nil {
+		panic(err)
+	}
 	rand.Seed(time.Now().Unix())
 	version = viper.GetString("update.version")
 
@@ -32,8 +38,9 @@ func Startup(scenes string) {
 
 	forceUpdate = viper.GetBool("update.force")
 	// register game handler
-	sceneIds := parseScenes(scenes)
+	sceneIds, lineIds := parseScenes(scenes)
 	defaultSceneManager.setSceneIds(sceneIds)
+	defaultSceneManager.setLineIds(lineIds)
 	comps := &component.Components{}
 	comps.Register(defaultSceneManager)
 
@@ -61,14 +68,30 @@ func Startup(scenes string) {
 	)
 }
 
-func parseScenes(scenes string) []int {
+// "1"运行场景1配置的所有分线, "1#2"只运行场景1的第2条线
+func parseScenes(scenes string) ([]int, map[int][]int) {
 	sceneIds := make([]int, 0)
+	lineIds := make(map[int][]int)
 	for _, s := range strings.Split(scenes, ",") {
-		sid, err := strconv.Atoi(s)
+		arr := strings.Split(s, "#")
+		sid, err := strconv.Atoi(arr[0])
 		if err != nil {
 			panic(err)
 		}
-		sceneIds = append(sceneIds, sid)
+		if _, ok := lineIds[sid]; !ok {
+			sceneIds = append(sceneIds, sid)
+		}
+		if len(arr) > 1 {
+			lineId, err := strconv.Atoi(arr[1])
+			if err != nil || lineId < 1 {
+				panic(fmt.Errorf("分线格式错误: %s", s))
+			}
+			lineIds[sid] = append(lineIds[sid], lineId)
+			continue
+		}
+		for lineId := 1; lineId <= sceneline.Count(sid); lineId++ {
+			lineIds[sid] = append(lineIds[sid], lineId)
+		}
 	}
-	return sceneIds
+	return sceneIds, lineIds
 }
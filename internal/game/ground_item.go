@@ -0,0 +1,262 @@
+package game
+
+// 怪物死亡后掉落在地上的物品, 保护期内只有伤害最高的hero能拾取, 超时后消失
+import (
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/protocol"
+)
+
+const (
+	ITEM_ID_COIN = 0
+	// 掉落物品的保护时间(毫秒)
+	GROUND_ITEM_PROTECT_TIME = 30000
+	// 掉落物品的消失时间(毫秒)
+	GROUND_ITEM_EXPIRE_TIME = 120000
+	// 拾取距离(格子)
+	GROUND_ITEM_PICKUP_RANGE = 2
+)
+
+var (
+	ErrItemNotFound   = errors.New("item not found")
+	ErrItemProtected  = errors.New("item is protected")
+	ErrItemOutOfRange = errors.New("item is out of range")
+)
+
+// 怪物级别的掉落倍数, 概率和金币数量都会乘以这个倍数, 启动时从game-server.monster_grade_drop_rate加载
+var monsterGradeDropRate = []int64{1, 2, 3, 5, 8, 8}
+
+// 掉落物品散开的位置
+var dropOffsets = [][2]coord.Coord{{0, 0}, {1, 0}, {0, 1}, {-1, 0}, {0, -1}, {1, 1}, {-1, 1}, {1, -1}, {-1, -1}}
+
+var groundItemSeq atomic.Int64
+
+type dropResult struct {
+	ItemId int
+	Count  int
+}
+
+// randn返回[0,n)的随机数
+func rollDrops(drops []model.MonsterDrop, grade int, randn func(n int) int) []dropResult {
+	rate := int(monsterGradeDropRate[0])
+	if grade >= 0 && grade < len(monsterGradeDropRate) {
+		rate = int(monsterGradeDropRate[grade])
+	}
+	result := make([]dropResult, 0)
+	for _, d := range drops {
+		if randn(10000) >= min(d.Probability*rate, 10000) {
+			continue
+		}
+		count := d.MinCount
+		if d.MaxCount > d.MinCount {
+			count += randn(d.MaxCount - d.MinCount + 1)
+		}
+		if d.ItemId == ITEM_ID_COIN {
+			count *= rate
+		}
+		if count <= 0 {
+			continue
+		}
+		result = append(result, dropResult{ItemId: d.ItemId, Count: count})
+	}
+	return result
+}
+
+type GroundItem struct {
+	*object.ItemObject
+	movableEntity
+	picked atomic.Bool
+}
+
+func NewGroundItem(o *object.ItemObject) *GroundItem {
+	e := &GroundItem{
+		ItemObject: o,
+	}
+	o.Id = groundItemSeq.Add(1)
+	e.initEntity(o.Id, "item"+o.Name, constants.ENTITY_TYPE_ITEM, 16)
+	e.GameObject.Uuid = e.GetUUID()
+	return e
+}
+
+func (e *GroundItem) SetPos(x, y, z coord.Coord) {
+	e.Posx = x
+	e.Posy = y
+	e.Posz = z
+	e.movableEntity.SetPos(x, y, z)
+}
+
+func (e *GroundItem) IsExpired(now int64) bool {
+	return now >= e.ExpireAt
+}
+
+func (e *GroundItem) canPickup(h *Hero, now int64) error {
+	if e.IsExpired(now) || e.picked.Load() {
+		return ErrItemNotFound
+	}
+	if e.OwnerId > 0 && e.OwnerId != h.GetID() && now < e.ProtectUntil && (e.OwnerPartyId == 0 || e.OwnerPartyId != h.partyId()) {
+		return ErrItemProtected
+	}
+	if gridDistance(h.GetPos().X, h.GetPos().Y, e.GetPos().X, e.GetPos().Y) > GROUND_ITEM_PICKUP_RANGE {
+		return ErrItemOutOfRange
+	}
+	return nil
+}
+
+// 同时拾取的只有一个能成功
+func (e *GroundItem) claim() bool {
+	return e.picked.CompareAndSwap(false, true)
+}
+
+func (e *GroundItem) unclaim() {
+	e.picked.Store(false)
+}
+
+func (e *GroundItem) Destroy() {
+	if e.scene != nil {
+		e.scene.removeItem(e)
+	}
+	e.canSeeMeViewList.Range(func(key, value interface{}) bool {
+		value.(IMovableEntity).onExitView(e)
+		return true
+	})
+	e.movableEntity.Destroy()
+}
+
+// 加载怪物的掉落配置
+func (s *Scene) loadDropTable(monsterId int64) {
+	if _, ok := s.dropTables.Load(monsterId); ok {
+		return
+	}
+	drops, err := db.MonsterDropList(monsterId)
+	if err != nil {
+		logger.Warningf("monster:%d 加载掉落配置失败: %v", monsterId, err)
+		return
+	}
+	for _, d := range drops {
+		if d.ItemId != ITEM_ID_COIN && object.GetItemConfig(d.ItemId) == nil {
+			logger.Warningf("monster:%d 掉落的物品:%d不存在", monsterId, d.ItemId)
+		}
+	}
+	s.dropTables.Store(monsterId, drops)
+}
+
+// 在monster的task内调用, ownerId为0时所有人都可以拾取
+func (s *Scene) dropMonsterLoot(m *Monster, ownerId int64) {
+	v, ok := s.dropTables.Load(m.Data.Id)
+	if !ok {
+		return
+	}
+	now := time.Now().UnixMilli()
+	pos := m.GetPos()
+	for i, r := range rollDrops(v.([]model.MonsterDrop), m.Grade, rand.Intn) {
+		var data *model.Item
+		if r.ItemId != ITEM_ID_COIN {
+			if data = object.GetItemConfig(r.ItemId); data == nil {
+				continue
+			}
+		}
+		o := object.NewItemObject(data, r.Count)
+		if data == nil {
+			o.Name = "金币"
+		}
+		o.OwnerId, o.OwnerPartyId = s.lootOwner(m, ownerId)
+		o.ProtectUntil = now + GROUND_ITEM_PROTECT_TIME
+		o.ExpireAt = now + GROUND_ITEM_EXPIRE_TIME
+		e := NewGroundItem(o)
+		x, y := s.dropPos(pos, i)
+		e.SetPos(x, y, pos.Z)
+		s.addItem(e)
+		logger.Debugf("monster:%d 掉落物品:%d-%s x%d 位置:%d,%d", m.GetID(), o.ItemId, o.Name, o.Count, x, y)
+	}
+}
+
+// 多个物品在怪物周围散开, 不可行走的位置放在怪物脚下
+func (s *Scene) dropPos(pos coord.Vector3, index int) (coord.Coord, coord.Coord) {
+	offset := dropOffsets[index%len(dropOffsets)]
+	x, y := pos.X+offset[0], pos.Y+offset[1]
+	if !s.IsWalkable(x, y) {
+		return pos.X, pos.Y
+	}
+	return x, y
+}
+
+func (h *Hero) PickupItem(id int64) error {
+	h.PushTask(func() {
+		if err := h.doPickupItem(id, time.Now().UnixMilli()); err != nil {
+			logger.Debugf("hero:%d pickup item:%d err: %v", h.GetID(), id, err)
+		}
+	})
+	return nil
+}
+
+func (h *Hero) doPickupItem(id int64, now int64) error {
+	if h.scene == nil {
+		return nil
+	}
+	if !h.IsAlive() {
+		return ErrHeroDead
+	}
+	v, ok := h.scene.items.Load(id)
+	if !ok {
+		return ErrItemNotFound
+	}
+	item := v.(*GroundItem)
+	if err := item.canPickup(h, now); err != nil {
+		return err
+	}
+	if !item.claim() {
+		return ErrItemNotFound
+	}
+	// 保存完成前物品留在地上, 其他人不能拾取
+	picked := func(err error) {
+		item.PushTask(func() { h.onItemPicked(item, err) })
+	}
+	if item.ItemId == ITEM_ID_COIN {
+		uid := h.GetUID()
+		async.Run(func() {
+			picked(db.UserAddCoin(uid, int64(item.Count)))
+		})
+		return nil
+	}
+	data := object.GetItemConfig(item.ItemId)
+	if data == nil {
+		item.unclaim()
+		return ErrItemNotFound
+	}
+	if err := h.bag.Add(data, item.Count); err != nil {
+		item.unclaim()
+		return err
+	}
+	h.saveBagThen(picked)
+	return nil
+}
+
+// 在物品所在场景的携程内执行
+func (h *Hero) onItemPicked(item *GroundItem, err error) {
+	if err != nil {
+		// 保存失败, 物品留在地上
+		logger.Errorf("hero:%d pickup item:%d err: %v", h.GetID(), item.GetID(), err)
+		item.unclaim()
+		return
+	}
+	item.Destroy()
+	if item.ItemId != ITEM_ID_COIN {
+		h.PushTask(h.sendBag)
+	}
+	h.SendMsg(protocol.OnItemPickup, &protocol.ItemPickupResponse{
+		ID:     item.GetID(),
+		ItemId: item.ItemId,
+		Name:   item.Name,
+		Count:  item.Count,
+	})
+	logger.Debugf("hero:%d-%s 拾取物品:%d-%s x%d", h.GetID(), h._name, item.ItemId, item.Name, item.Count)
+}
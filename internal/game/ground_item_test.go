@@ -0,0 +1,50 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollDrops(t *testing.T) {
+	drops := []model.MonsterDrop{
+		{ItemId: ITEM_ID_COIN, MinCount: 10, MaxCount: 20, Probability: 10000},
+		{ItemId: 1, MinCount: 1, MaxCount: 1, Probability: 3000},
+		{ItemId: 2, MinCount: 1, MaxCount: 3, Probability: 6000},
+	}
+	// 随机数固定为5000
+	randn := func(n int) int {
+		return min(5000, n-1)
+	}
+
+	result := rollDrops(drops, 0, randn)
+	assert.Equal(t, []dropResult{{ItemId: ITEM_ID_COIN, Count: 20}, {ItemId: 2, Count: 3}}, result)
+
+	// 小头目概率和金币翻倍
+	result = rollDrops(drops, 1, randn)
+	assert.Equal(t, []dropResult{{ItemId: ITEM_ID_COIN, Count: 40}, {ItemId: 1, Count: 1}, {ItemId: 2, Count: 3}}, result)
+
+	assert.Empty(t, rollDrops(nil, 0, randn))
+
+	// 掉落倍数从配置加载
+	viper.Set("game-server.monster_grade_drop_rate", "1,3")
+	defer viper.Set("game-server.monster_grade_drop_rate", "")
+	rates := monsterGradeDropRate
+	defer func() { monsterGradeDropRate = rates }()
+	assert.Nil(t, loadMonsterConfig())
+	result = rollDrops(drops, 1, randn)
+	assert.Equal(t, []dropResult{{ItemId: ITEM_ID_COIN, Count: 60}, {ItemId: 1, Count: 1}, {ItemId: 2, Count: 3}}, result)
+
+	viper.Set("game-server.monster_grade_drop_rate", "1,x")
+	assert.NotNil(t, loadMonsterConfig())
+}
+
+func TestPartyExpShare(t *testing.T) {
+	assert.Equal(t, int64(100), partyExpShare(100, 1))
+	// 2人平分, 加成10%
+	assert.Equal(t, int64(55), partyExpShare(100, 2))
+	assert.Equal(t, int64(1), partyExpShare(1, 3))
+	assert.Equal(t, int64(0), partyExpShare(100, 0))
+}
@@ -1,17 +1,35 @@
 package game
 
 import (
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lonng/nano/session"
 	constants2 "github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
 	"github.com/nano/gameserver/db/model"
 	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/async"
 	"github.com/nano/gameserver/pkg/coord"
 	"github.com/nano/gameserver/protocol"
 )
 
+const (
+	// hero普通攻击间隔(毫秒)
+	HERO_ATTACK_DURATION = 1000
+)
+
+var (
+	ErrAttackTargetNotFound = errors.New("attack target not found")
+	ErrAttackInvalidTarget  = errors.New("attack target is invalid")
+	ErrAttackOutOfRange     = errors.New("attack target is out of range")
+	ErrAttackCooldown       = errors.New("attack is cooling down")
+	ErrHeroDead             = errors.New("hero is dead")
+)
+
 type routeMsg struct {
 	Route string      `json:"route"`
 	Msg   interface{} `json:"msg"`
@@ -21,11 +39,27 @@ type Hero struct {
 	*object.HeroObject
 	movableEntity
 	session                   *session.Session
-	tracePath                 [][]int32 //当前移动路径
-	traceIndex                int       //当前已移动到第几步
-	traceTotalTime            int64     //当前移动的总时间
-	targetX, targetY, targetZ int       //移动的目标点
+	tracePath                 [][]int32     //当前移动路径
+	traceIndex                int           //当前已移动到第几步
+	traceTotalTime            int64         //当前移动的总时间
+	targetX, targetY, targetZ int           //移动的目标点
+	moveCheckPos              coord.Vector3 //速度校验的起点
+	moveCheckTs               int64         //速度校验的起始时间(毫秒)
+	nextAttackTime            int64         //下次可以普通攻击的时间(毫秒)
+	spells                    []*object.SpellObject
+	bag                       *object.Bag
+	inDoorId                  int   //当前所在的传送门范围
+	invincibleUntil           int64 //复活后无敌的截止时间(毫秒)
+	cellMigrateAt             int64 //上次请求迁移cell的时间(毫秒)
+	party                     atomic.Pointer[heroParty]
+	viewFocus                 atomic.Value              //当前目标的uuid
+	viewAttackers             sync.Map                  //最近攻击过自己的对象, uuid -> 截止时间(毫秒)
+	viewHidden                int                       //超过视野上限没有推送的对象数量, 只在场景携程内访问
+	bagSaved                  chan struct{}             //上一次背包保存完成后关闭, 只在场景携程内访问
+	bagItemIds                map[*model.HeroItem]int64 //新增物品保存后的id, 只在保存背包的携程内按顺序访问
+	reviving                  bool                      //原地复活扣费中, 只在场景携程内访问
 	messagesCh                chan routeMsg
+	sendOverflow              atomic.Bool //消息堆积已满, 已经断开连接等待重连
 	destroyCh                 chan struct{}
 }
 
@@ -170,17 +204,42 @@ func (h *Hero) IsOffline() bool {
 
 func (h *Hero) save() {
 	h.PushTask(func() {
-		h.InitPosx = int(h.Posx)
-		h.InitPosy = int(h.Posy)
-		h.InitPosz = int(h.Posz)
-		h.UpdateProperty()
-		if h.scene != nil {
-			h.SceneId = h.scene.sceneId
-		}
-		// todo保存数据，可以推入mq执行保存操作, 还可以细分保存的频度，部分数据需要立即存储
+		h.syncData()
+		h.persist(false)
 	})
 }
 
+// 立即保存，下线、离开场景时在场景携程内调用
+func (h *Hero) saveNow() {
+	h.syncData()
+	h.persist(true)
+}
+
+func (h *Hero) syncData() {
+	h.InitPosx = int(h.Posx)
+	h.InitPosy = int(h.Posy)
+	h.InitPosz = int(h.Posz)
+	if !h.IsAlive() && h.scene != nil {
+		//死亡状态下线的, 重新上线时在出生点
+		pos := h.respawnPos()
+		h.InitPosx, h.InitPosy, h.InitPosz = int(pos.X), int(pos.Y), int(pos.Z)
+	}
+	h.UpdateProperty()
+	if h.scene != nil {
+		h.SceneId = h.scene.sceneId
+	}
+}
+
+// 只提交有变化的字段，由db异步批量写入
+func (h *Hero) persist(immediate bool) {
+	cols := h.DirtyColumns()
+	if len(cols) == 0 {
+		return
+	}
+	db.SaveHero(&h.Hero, cols, immediate)
+	h.MarkPersisted()
+}
+
 func (h *Hero) onEnterView(target IMovableEntity) {
 	h.movableEntity.onEnterView(target)
 	var data interface{}
@@ -208,17 +267,39 @@ func (h *Hero) onEnterView(target IMovableEntity) {
 			Buffers:    buffers,
 		})
 		val.sendDataToHero(h)
+	case *GroundItem:
+		h.SendMsg(protocol.OnEnterView, &protocol.TargetEnterViewResponse{
+			EntityType: ttype,
+			Data:       val.ItemObject,
+		})
+	case *GhostEntity:
+		h.SendMsg(protocol.OnEnterView, &protocol.TargetEnterViewResponse{
+			EntityType: val.realType,
+			Data:       val.data,
+		})
+	case *SpellEntity:
+		//飞行中的技能, 包含当前位置和目标位置
+		h.SendMsg(protocol.OnEnterView, &protocol.TargetEnterViewResponse{
+			EntityType: ttype,
+			Data:       val.SpellObject,
+		})
 	}
 }
 
 func (h *Hero) onExitView(target IMovableEntity) {
 	h.movableEntity.onExitView(target)
 	ttype := -1
-	switch target.(type) {
+	switch val := target.(type) {
 	case *Hero:
 		ttype = constants2.ENTITY_TYPE_HERO
 	case *Monster:
 		ttype = constants2.ENTITY_TYPE_MONSTER
+	case *GroundItem:
+		ttype = constants2.ENTITY_TYPE_ITEM
+	case *GhostEntity:
+		ttype = val.realType
+	case *SpellEntity:
+		ttype = constants2.ENTITY_TYPE_SPELL
 	}
 	logger.Debugf("对象:%d-%d离开hero:%d_%s视野:", target.GetID(), ttype, h.GetID(), h._name)
 	if ttype > -1 {
@@ -233,9 +314,11 @@ func (h *Hero) onExitView(target IMovableEntity) {
 func (h *Hero) SendMsg(route string, msg interface{}) {
 	h.PushTask(func() {
 		if h.session != nil {
-			h.messagesCh <- routeMsg{
-				Route: route,
-				Msg:   msg,
+			//在场景携程内执行, 发送堆积时不能阻塞整个场景
+			select {
+			case h.messagesCh <- routeMsg{Route: route, Msg: msg}:
+			default:
+				h.onSendOverflow(route)
 			}
 			//logger.Debugf("hero:%s msgchan len::%d", h._name, len(h.messagesCh))
 		} else {
@@ -244,6 +327,19 @@ func (h *Hero) SendMsg(route string, msg interface{}) {
 	})
 }
 
+// 丢弃消息后客户端的状态就不对了, 断开连接让客户端重连后重新同步
+// 断开后由onPlayerDisconnect保存并销毁hero
+func (h *Hero) onSendOverflow(route string) {
+	if !h.sendOverflow.CompareAndSwap(false, true) {
+		return
+	}
+	logger.Errorf("hero: %d消息堆积已满, 断开连接: %s", h._id, route)
+	s := h.session
+	async.Run(func() {
+		s.Close()
+	})
+}
+
 // 广播给所有能看见自己的对象
 func (h *Hero) Broadcast(route string, msg interface{}, includeSelf bool) {
 	if includeSelf {
@@ -332,8 +428,11 @@ func (h *Hero) Run() {
 	h.SetState(constants2.ACTION_STATE_RUN)
 }
 
+// 死亡后停止移动, 清除身上的buffer, 等待玩家请求复活
 func (h *Hero) Die() {
 	h.SetState(constants2.ACTION_STATE_DIE)
+	h.clearTracePaths()
+	h.clearBuffers()
 	logger.Debugf("hero:%d-%s die", h.GetID(), h._name)
 	h.Broadcast(protocol.OnEntityDie, &protocol.EntityDieResponse{
 		ID:         h.GetID(),
@@ -341,12 +440,19 @@ func (h *Hero) Die() {
 	}, true)
 }
 
-// update都会在chTask携程内执行
+// update在场景携程内按顺序执行
 func (h *Hero) update(curMilliSecond int64, elapsedTime int64) error {
 	err := h.movableEntity.update(curMilliSecond, elapsedTime)
 	if h.haveStepsToGo() {
 		h.updateHeroPosition(curMilliSecond, elapsedTime)
 	}
+	h.checkDoor()
+	h.checkCell(curMilliSecond)
+	for _, spell := range h.spells {
+		if spell.CurCdTime > 0 {
+			spell.Update(elapsedTime)
+		}
+	}
 	return err
 }
 
@@ -386,23 +492,30 @@ func (h *Hero) MoveByPaths(targetx, targety, targetz int, paths [][]int32) error
 		if h.scene == nil {
 			return
 		}
+		if !h.IsAlive() {
+			logger.Debugf("hero:%d 已死亡不能移动", h.GetID())
+			return
+		}
 		if h.targetX == targetx && h.targetY == targety {
 			//目标点一致,什么都不做
 			return
 		}
-		// todo 这里需要校验当前服务器的位置与前端的差距是否合理范围
+		if err := h.checkMove(paths, time.Now().UnixMilli()); err != nil {
+			logger.Warningf("hero:%d 移动校验失败: %v, pos:%v, paths:%v", h.GetID(), err, h.GetPos(), paths)
+			h.correctPosition()
+			return
+		}
 		h.tracePath = paths
 		h.traceIndex = 0
 		h.traceTotalTime = 0
 		h.targetX = targetx
 		h.targetY = targety
 		h.targetZ = targetz
-		if len(paths) > 0 {
-			firstStep := paths[0]
-			if h.GetPos().X != coord.Coord(firstStep[1]) || h.GetPos().Y != coord.Coord(firstStep[0]) {
-				h.SetPos(coord.Coord(firstStep[1]), coord.Coord(firstStep[0]), h.GetPos().Z)
-				//todo 这里看是否需要调用scene.refreshEntityViewList立即刷新视野
-			}
+		// 起点已经校验过在允许的偏差内, 以前端为准
+		firstStep := paths[0]
+		if h.GetPos().X != coord.Coord(firstStep[1]) || h.GetPos().Y != coord.Coord(firstStep[0]) {
+			h.SetPos(coord.Coord(firstStep[1]), coord.Coord(firstStep[0]), h.GetPos().Z)
+			//todo 这里看是否需要调用scene.refreshEntityViewList立即刷新视野
 		}
 		h.Broadcast(protocol.OnHeroMoveTrace, &protocol.HeroMoveTraceResponse{
 			ID:         h.GetID(),
@@ -418,6 +531,14 @@ func (h *Hero) MoveByPaths(targetx, targety, targetz int, paths [][]int32) error
 
 func (h *Hero) MoveStop(x, y, z coord.Coord) error {
 	h.PushTask(func() {
+		if h.scene == nil || !h.IsAlive() {
+			return
+		}
+		if err := h.checkMoveStop(x, y, time.Now().UnixMilli()); err != nil {
+			logger.Warningf("hero:%d 停止移动校验失败: %v, pos:%v, stop:(%d,%d)", h.GetID(), err, h.GetPos(), x, y)
+			h.correctPosition()
+			return
+		}
 		h.clearTracePaths()
 		h.SetPos(x, y, z)
 		h.Broadcast(protocol.OnHeroMoveStopped, &protocol.HeroMoveStopResponse{
@@ -430,17 +551,80 @@ func (h *Hero) MoveStop(x, y, z coord.Coord) error {
 	return nil
 }
 
-func (h *Hero) AttackTarget(targetId int64) error {
+// 普通攻击, 目前只能攻击怪物
+func (h *Hero) AttackTarget(targetId int64, targetType int) error {
+	h.PushTask(func() {
+		if err := h.doAttackTarget(targetId, targetType, time.Now().UnixMilli()); err != nil {
+			logger.Debugf("hero:%d attack target:%d-%d err: %v", h.GetID(), targetType, targetId, err)
+		}
+	})
 	return nil
 }
 
-func (h *Hero) BeenAttacked(damage int64) (int, error) {
-
-	return 0, nil
+func (h *Hero) doAttackTarget(targetId int64, targetType int, now int64) error {
+	if h.scene == nil {
+		return nil
+	}
+	if !h.IsAlive() {
+		return ErrHeroDead
+	}
+	if targetType != constants2.ENTITY_TYPE_MONSTER {
+		return ErrAttackInvalidTarget
+	}
+	val, ok := h.scene.monsters.Load(targetId)
+	if !ok {
+		return ErrAttackTargetNotFound
+	}
+	target := val.(*Monster)
+	if !h.CanAttackTarget(target) {
+		return ErrAttackInvalidTarget
+	}
+	if now < h.nextAttackTime {
+		return ErrAttackCooldown
+	}
+	if !h.IsInAttackRange(target.GetPos().X, target.GetPos().Y) {
+		return ErrAttackOutOfRange
+	}
+	h.nextAttackTime = now + HERO_ATTACK_DURATION
+	h.setViewFocus(target)
+	h.AttackAction()
+	damage := h.GetAttack() - target.GetDefense()
+	if damage < 1 { //至少有1点伤害
+		damage = 1
+	}
+	target.onBeenHurtBy(h, damage)
+	target.onBeenAttacked(h)
+	h.Broadcast(protocol.OnHeroCommonAttack, &protocol.HeroAttackResponse{
+		ID:         h.GetID(),
+		Action:     "common",
+		Damage:     damage,
+		TargetId:   target.GetID(),
+		EntityType: constants2.ENTITY_TYPE_MONSTER,
+		PosX:       h.GetPos().X,
+		PosY:       h.GetPos().Y,
+		PosZ:       h.GetPos().Z,
+	}, true)
+	return nil
 }
 
 func (h *Hero) CanAttackTarget(target IEntity) bool {
-	return true
+	switch val := target.(type) {
+	case *Monster:
+		return val.IsAlive() && !val.IsDestroyed()
+	case *Hero:
+		// 暂时不支持pk
+		return false
+	}
+	return false
+}
+
+// 攻击范围是格子数, 没有配置的按近战1格计算
+func (h *Hero) IsInAttackRange(x, y coord.Coord) bool {
+	arange := coord.Coord(h.AttackRange)
+	if arange <= 0 {
+		arange = 1
+	}
+	return gridDistance(h.GetPos().X, h.GetPos().Y, x, y) <= arange
 }
 
 func (h *Hero) onBeenHurt(damage int64) {
@@ -449,6 +633,9 @@ func (h *Hero) onBeenHurt(damage int64) {
 			logger.Warningln("hero is dead")
 			return
 		}
+		if damage > 0 && h.IsInvincible() {
+			return
+		}
 		h.Life -= damage
 		if h.Life < 0 {
 			h.Life = 0
@@ -470,28 +657,93 @@ func (h *Hero) onBeenHurt(damage int64) {
 	})
 }
 
-func (h *Hero) onBeenAttacked(target IMovableEntity) {
-}
-
-func (h *Hero) manaCost(mana int64) {
+// 获得经验, 升级后回满血蓝并立即保存
+func (h *Hero) addExperience(exp int64) {
 	h.PushTask(func() {
-		if !h.IsAlive() {
-			logger.Warningln("hero is dead")
+		if h.scene == nil {
 			return
 		}
-		h.Mana -= mana
-		if h.Mana < 0 {
-			h.Mana = 0
+		up := h.AddExperience(exp)
+		h.SendMsg(protocol.OnHeroExpChanged, &protocol.HeroExpChangedResponse{
+			ID:         h.GetID(),
+			Add:        exp,
+			Experience: h.Experience,
+			Level:      h.Level,
+		})
+		if up <= 0 {
+			return
 		}
-		if h.Mana > h.MaxMana {
+		if h.IsAlive() {
+			h.Life = h.MaxLife
 			h.Mana = h.MaxMana
 		}
-		h.Broadcast(protocol.OnManaChanged, &protocol.ManaChangedResponse{
-			ID:         h.GetID(),
-			EntityType: constants2.ENTITY_TYPE_HERO,
-			Cost:       mana,
-			Mana:       h.Mana,
-			MaxMana:    h.MaxMana,
+		logger.Debugf("hero:%d-%s 升级到:%d", h.GetID(), h._name, h.Level)
+		h.Broadcast(protocol.OnHeroLevelUp, &protocol.HeroLevelUpResponse{
+			ID:           h.GetID(),
+			Level:        h.Level,
+			Experience:   h.Experience,
+			Strength:     h.Strength,
+			Agility:      h.Agility,
+			Intelligence: h.Intelligence,
+			Attack:       h.Attack,
+			Defense:      h.Defense,
+			Life:         h.Life,
+			MaxLife:      h.MaxLife,
+			Mana:         h.Mana,
+			MaxMana:      h.MaxMana,
 		}, true)
+		h.syncData()
+		h.persist(false)
+	})
+}
+
+func (h *Hero) manaCost(mana int64) {
+	h.PushTask(func() {
+		h.doManaCost(mana)
 	})
 }
+
+// 需要在场景携程内执行, 释放技能时检查和扣除魔法要同时完成
+func (h *Hero) doManaCost(mana int64) {
+	if !h.IsAlive() {
+		logger.Warningln("hero is dead")
+		return
+	}
+	h.Mana -= mana
+	if h.Mana < 0 {
+		h.Mana = 0
+	}
+	if h.Mana > h.MaxMana {
+		h.Mana = h.MaxMana
+	}
+	h.Broadcast(protocol.OnManaChanged, &protocol.ManaChangedResponse{
+		ID:         h.GetID(),
+		EntityType: constants2.ENTITY_TYPE_HERO,
+		Cost:       mana,
+		Mana:       h.Mana,
+		MaxMana:    h.MaxMana,
+	}, true)
+}
+
+// 需要在hero的task携程内执行
+func (h *Hero) snapshot() *heroSnapshot {
+	return &heroSnapshot{
+		Id:      h.GetID(),
+		Uid:     h.GetUID(),
+		Pos:     h.GetPos(),
+		Life:    h.Life,
+		Mana:    h.Mana,
+		Buffers: snapshotBuffers(h.buffers),
+	}
+}
+
+// 需要在进入场景之前执行
+func (h *Hero) restoreSnapshot(hs *heroSnapshot) {
+	h.SetPos(hs.Pos.X, hs.Pos.Y, hs.Pos.Z)
+	h.Life = min(hs.Life, h.MaxLife)
+	h.Mana = min(hs.Mana, h.MaxMana)
+	if h.Life <= 0 {
+		h.SetState(constants2.ACTION_STATE_DIE)
+	}
+	h.restoreBuffers(h, hs.Buffers)
+}
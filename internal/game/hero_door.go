@@ -0,0 +1,66 @@
+package game
+
+// hero走进传送门时由game节点通知master切换场景, 客户端不能直接指定目标场景
+import (
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 传送门的触发范围(格子)
+	SCENE_DOOR_RANGE = 1
+)
+
+func (s *Scene) findDoor(x, y coord.Coord) *model.SceneDoor {
+	for i := range s.sceneData.DoorList {
+		door := &s.sceneData.DoorList[i]
+		if door.TargetSceneId <= 0 {
+			continue
+		}
+		if gridDistance(x, y, coord.Coord(door.Posx), coord.Coord(door.Posy)) <= SCENE_DOOR_RANGE {
+			return door
+		}
+	}
+	return nil
+}
+
+func (s *Scene) doorIdAt(x, y coord.Coord) int {
+	if door := s.findDoor(x, y); door != nil {
+		return door.Id
+	}
+	return 0
+}
+
+// 在hero的task内执行, 走进传送门的范围时触发一次, 离开范围后才能再次触发
+func (h *Hero) checkDoor() {
+	if h.scene == nil || h.session == nil || !h.IsAlive() {
+		return
+	}
+	pos := h.GetPos()
+	door := h.scene.findDoor(pos.X, pos.Y)
+	doorId := 0
+	if door != nil {
+		doorId = door.Id
+	}
+	if doorId == h.inDoorId {
+		return
+	}
+	h.inDoorId = doorId
+	if door == nil {
+		return
+	}
+	h.clearTracePaths()
+	req := &protocol.HeroEnterDoorRequest{
+		Uid:     h.GetUID(),
+		HeroId:  h.GetID(),
+		SceneId: h.scene.sceneId,
+		DoorId:  door.Id,
+	}
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+	logger.Debugf("hero:%d-%s 进入传送门:%d-%s, 目标场景:%d", h.GetID(), h._name, door.Id, door.Name, door.TargetSceneId)
+	if err := h.session.RPC("Manager.HeroEnterDoor", req); err != nil {
+		logger.Errorf("rpc.Call(Manager.HeroEnterDoor) err: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package game
+
+// 公会由master管理, game节点只保存在HeroObject内用于显示
+import (
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+// 在hero携程内修改, 广播给看得见自己的hero
+func (h *Hero) setGuild(guild *protocol.HeroGuild) {
+	h.PushTask(func() {
+		var guildId int64
+		var name string
+		if guild != nil {
+			guildId, name = guild.GuildId, guild.Name
+		}
+		if h.GuildId == guildId && h.GuildName == name {
+			return
+		}
+		h.GuildId, h.GuildName = guildId, name
+		h.Broadcast(protocol.OnHeroGuildChanged, &protocol.HeroGuildResponse{
+			ID:        h.GetID(),
+			GuildId:   guildId,
+			GuildName: name,
+		}, true)
+	})
+}
+
+// master通知hero所在的公会变化
+func (manager *SceneManager) HeroGuildChanged(s *session.Session, req *protocol.HeroGuildChangedRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("hero:%d 公会变化签名错误", req.HeroId)
+		return errutil.ErrPermissionDenied
+	}
+	h, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	if h.GetID() != req.HeroId {
+		return errutil.ErrHeroNotFound
+	}
+	h.setGuild(req.Guild)
+	return nil
+}
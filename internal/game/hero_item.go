@@ -0,0 +1,302 @@
+package game
+
+// hero的背包操作都在hero的task内执行, 每次修改后立即保存到数据库
+// 保存在场景携程外按提交的顺序执行, 不阻塞场景
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/protocol"
+)
+
+var (
+	ErrItemNotUsable   = errors.New("item can not be used")
+	ErrBagFlushTimeout = errors.New("bag flush timeout")
+)
+
+// 执行中的背包保存, 节点下线时等待完成
+var bagSaves sync.WaitGroup
+
+// 进入场景前加载, 穿着的装备计入属性
+func (h *Hero) loadBag() error {
+	items, err := db.HeroItemList(h.GetID())
+	if err != nil {
+		return err
+	}
+	h.bag = object.NewBag(h.GetID(), constants.BAG_SIZE, items, object.GetItemConfig)
+	h.applyEquipBonus()
+	//初始化的时候满血满蓝
+	h.Life = h.MaxLife
+	h.Mana = h.MaxMana
+	// 修正过位置的物品, 保存失败时下次加载会重新修正
+	h.saveBag()
+	return nil
+}
+
+func (h *Hero) applyEquipBonus() {
+	h.SetEquipBonus(h.bag.EquipBonus())
+}
+
+func (h *Hero) saveBag() {
+	h.saveBagThen(nil)
+}
+
+// 在上一次保存完成后保存背包的变化, 完成后在场景携程外调用then
+// 保存失败时从数据库重新加载, 保证内存和数据库一致
+func (h *Hero) saveBagThen(then func(err error)) {
+	changed, removed := h.bag.TakeChanges()
+	// 保存时场景携程还会修改背包, 保存副本
+	items := make([]model.HeroItem, len(changed))
+	for i, item := range changed {
+		items[i] = *item
+	}
+	removedIds := make([]int64, 0, len(removed))
+	unsaved := make([]*model.HeroItem, 0)
+	for _, item := range removed {
+		if item.Id > 0 {
+			removedIds = append(removedIds, item.Id)
+		} else {
+			unsaved = append(unsaved, item)
+		}
+	}
+	prev := h.bagSaved
+	done := make(chan struct{})
+	h.bagSaved = done
+	heroId := h.GetID()
+	bagSaves.Add(1)
+	async.Run(func() {
+		defer bagSaves.Done()
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		err := h.writeBag(changed, items, removedIds, unsaved)
+		if err != nil {
+			logger.Errorf("hero:%d 保存背包失败: %v", heroId, err)
+			h.PushTask(func() { h.reloadBagAfter(h.bagSaved) })
+		}
+		if then != nil {
+			then(err)
+		}
+	})
+}
+
+// 按顺序在保存背包的携程内执行, 之前保存中的新增物品从bagItemIds取id
+func (h *Hero) writeBag(changed []*model.HeroItem, items []model.HeroItem, removedIds []int64, unsaved []*model.HeroItem) error {
+	if h.bagItemIds == nil {
+		h.bagItemIds = map[*model.HeroItem]int64{}
+	}
+	save := make([]*model.HeroItem, len(items))
+	inserts := make([]int, 0)
+	for i := range items {
+		if items[i].Id == 0 {
+			if id, ok := h.bagItemIds[changed[i]]; ok {
+				items[i].Id = id
+			} else {
+				inserts = append(inserts, i)
+			}
+		}
+		save[i] = &items[i]
+	}
+	for _, item := range unsaved {
+		if id, ok := h.bagItemIds[item]; ok {
+			removedIds = append(removedIds, id)
+			delete(h.bagItemIds, item)
+		}
+	}
+	if err := db.SaveHeroItems(save, removedIds); err != nil {
+		return err
+	}
+	inserted := make(map[*model.HeroItem]int64, len(inserts))
+	for _, i := range inserts {
+		inserted[changed[i]] = items[i].Id
+	}
+	if len(inserted) == 0 {
+		return nil
+	}
+	for item, id := range inserted {
+		h.bagItemIds[item] = id
+	}
+	// 回填背包内物品的id
+	h.PushTask(func() {
+		for item, id := range inserted {
+			item.Id = id
+		}
+	})
+	return nil
+}
+
+// 等最后一次提交的保存完成后从数据库重新加载
+func (h *Hero) reloadBagAfter(saved chan struct{}) {
+	heroId := h.GetID()
+	async.Run(func() {
+		<-saved
+		items, err := db.HeroItemList(heroId)
+		if err != nil {
+			logger.Errorf("hero:%d 重新加载背包失败: %v", heroId, err)
+			return
+		}
+		h.PushTask(func() {
+			if h.bagSaved != saved {
+				// 加载期间又提交了保存
+				h.reloadBagAfter(h.bagSaved)
+				return
+			}
+			h.bag = object.NewBag(heroId, constants.BAG_SIZE, items, object.GetItemConfig)
+			h.applyEquipBonus()
+			h.sendBag()
+			h.sendAttrChanged()
+		})
+	})
+}
+
+// 等待已提交的背包保存全部完成
+func flushBags(timeout time.Duration) error {
+	done := make(chan struct{})
+	async.Run(func() {
+		bagSaves.Wait()
+		close(done)
+	})
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return ErrBagFlushTimeout
+	}
+}
+
+func (h *Hero) sendBag() {
+	h.SendMsg(protocol.OnHeroBag, &protocol.HeroBagResponse{
+		Size:  h.bag.Size(),
+		Items: h.bag.Items(),
+	})
+}
+
+func (h *Hero) sendAttrChanged() {
+	h.SendMsg(protocol.OnHeroAttrChanged, &protocol.HeroAttrChangedResponse{
+		ID:      h.GetID(),
+		Attack:  h.Attack,
+		Defense: h.Defense,
+		Life:    h.Life,
+		MaxLife: h.MaxLife,
+		Mana:    h.Mana,
+		MaxMana: h.MaxMana,
+	})
+}
+
+// 执行背包操作, 成功后保存并推送最新的背包
+func (h *Hero) bagTask(name string, f func() error) error {
+	h.PushTask(func() {
+		if h.scene == nil || h.bag == nil {
+			return
+		}
+		if err := f(); err != nil {
+			logger.Debugf("hero:%d %s err: %v", h.GetID(), name, err)
+			return
+		}
+		h.saveBag()
+		h.sendBag()
+	})
+	return nil
+}
+
+func (h *Hero) ListItems() error {
+	h.PushTask(func() {
+		if h.bag != nil {
+			h.sendBag()
+		}
+	})
+	return nil
+}
+
+func (h *Hero) MoveItem(from, to int) error {
+	return h.bagTask("move item", func() error {
+		return h.bag.Move(from, to)
+	})
+}
+
+// 使用消耗品, 恢复生命和魔法
+func (h *Hero) UseItem(slot int) error {
+	return h.bagTask("use item", func() error {
+		if !h.IsAlive() {
+			return ErrHeroDead
+		}
+		item := h.bag.Get(slot)
+		if item == nil {
+			return object.ErrBagEmptySlot
+		}
+		if item.Data.ItemType != constants.ITEM_TYPE_CONSUMABLE {
+			return ErrItemNotUsable
+		}
+		if _, err := h.bag.Remove(slot, 1); err != nil {
+			return err
+		}
+		if item.Data.Life > 0 {
+			h.onBeenHurt(-item.Data.Life)
+		}
+		if item.Data.Mana > 0 {
+			h.manaCost(-item.Data.Mana)
+		}
+		logger.Debugf("hero:%d-%s 使用物品:%d-%s", h.GetID(), h._name, item.ItemId, item.Data.Name)
+		return nil
+	})
+}
+
+// 丢弃的物品掉在脚下, 所有人都可以拾取
+// 保存成功后才放到地上, 保存失败时物品重新加载回背包
+func (h *Hero) DropItem(slot int, count int) error {
+	h.PushTask(func() {
+		if h.scene == nil || h.bag == nil {
+			return
+		}
+		item, err := h.bag.Remove(slot, count)
+		if err != nil {
+			logger.Debugf("hero:%d drop item err: %v", h.GetID(), err)
+			return
+		}
+		scene, pos := h.scene, h.GetPos()
+		h.saveBagThen(func(err error) {
+			if err != nil {
+				return
+			}
+			scene.PushTask(func() {
+				o := object.NewItemObject(item.Data, count)
+				o.ExpireAt = time.Now().UnixMilli() + GROUND_ITEM_EXPIRE_TIME
+				e := NewGroundItem(o)
+				e.SetPos(pos.X, pos.Y, pos.Z)
+				scene.addItem(e)
+			})
+		})
+		h.sendBag()
+		logger.Debugf("hero:%d-%s 丢弃物品:%d-%s x%d", h.GetID(), h._name, item.ItemId, item.Data.Name, count)
+	})
+	return nil
+}
+
+func (h *Hero) EquipItem(slot int) error {
+	return h.bagTask("equip item", func() error {
+		if err := h.bag.Equip(slot); err != nil {
+			return err
+		}
+		h.applyEquipBonus()
+		h.sendAttrChanged()
+		return nil
+	})
+}
+
+func (h *Hero) UnequipItem(pos int) error {
+	return h.bagTask("unequip item", func() error {
+		if err := h.bag.Unequip(pos); err != nil {
+			return err
+		}
+		h.applyEquipBonus()
+		h.sendAttrChanged()
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lonng/nano/scheduler"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/dbtest"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveBag(t *testing.T) {
+	dbtest.Start(t, new(model.HeroItem))
+
+	potion := &model.Item{Id: 1, MaxStack: 10, ItemType: constants.ITEM_TYPE_CONSUMABLE}
+	s := &Scene{chTasks: make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE)}
+	h := NewHero(nil, &model.Hero{Id: 1})
+	defer close(h.destroyCh)
+	h.Entity.onEnterScene(s)
+	h.bag = object.NewBag(1, 3, nil, func(int) *model.Item { return potion })
+	flush := func() {
+		assert.Nil(t, flushBags(time.Second))
+		for len(s.chTasks) > 0 {
+			s._doTask(<-s.chTasks)
+		}
+	}
+
+	// 新增的物品保存完成前继续修改, 不会重复插入
+	assert.Nil(t, h.bag.Add(potion, 3))
+	h.saveBag()
+	_, err := h.bag.Remove(0, 1)
+	assert.Nil(t, err)
+	h.saveBag()
+	flush()
+	items, err := db.HeroItemList(1)
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, 2, items[0].Count)
+	assert.Equal(t, items[0].Id, h.bag.Get(0).Id)
+
+	// 保存完成前删除的新增物品也会删除
+	assert.Nil(t, h.bag.Move(0, 1))
+	assert.Nil(t, h.bag.Add(potion, 9))
+	h.saveBag()
+	_, err = h.bag.Remove(0, 1)
+	assert.Nil(t, err)
+	h.saveBag()
+	flush()
+	items, err = db.HeroItemList(1)
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, 10, items[0].Count)
+}
+
+func TestDropItemAfterSave(t *testing.T) {
+	dbtest.Start(t, new(model.HeroItem))
+
+	potion := &model.Item{Id: 1, MaxStack: 10, ItemType: constants.ITEM_TYPE_CONSUMABLE}
+	s := &Scene{
+		chTasks:  make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE),
+		aoiMgr:   newAoiMgr(AOI_TYPE_GRID, 100, 10),
+		viewGrid: newViewGrid(100, 100),
+	}
+	h := NewHero(nil, &model.Hero{Id: 1})
+	defer close(h.destroyCh)
+	h.Entity.onEnterScene(s)
+	h.bag = object.NewBag(1, 3, nil, func(int) *model.Item { return potion })
+	assert.Nil(t, h.bag.Add(potion, 3))
+	h.saveBag()
+
+	groundItems := func() (items []*GroundItem) {
+		s.items.Range(func(key, value interface{}) bool {
+			items = append(items, value.(*GroundItem))
+			return true
+		})
+		return
+	}
+
+	// 保存完成前不在地上
+	assert.Nil(t, h.DropItem(0, 2))
+	s._doTask(<-s.chTasks)
+	assert.Equal(t, 1, h.bag.Get(0).Count)
+	assert.Empty(t, groundItems())
+	assert.Nil(t, flushBags(time.Second))
+	for len(s.chTasks) > 0 {
+		s._doTask(<-s.chTasks)
+	}
+	items := groundItems()
+	assert.Len(t, items, 1)
+	assert.Equal(t, 2, items[0].Count)
+}
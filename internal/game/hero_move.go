@@ -0,0 +1,163 @@
+package game
+
+// hero移动校验, 服务器根据StepTime预测hero的位置, 前端提交的路径与预测位置偏差过大时拉回
+import (
+	"errors"
+
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/protocol"
+)
+
+const (
+	// 前端位置与服务器预测位置允许的最大偏差(格子), 用来容忍网络延迟
+	HERO_MOVE_MAX_DEVIATION = 3
+	// 速度校验的统计周期(毫秒), 超过后重新记录起点
+	HERO_MOVE_CHECK_PERIOD = 5000
+)
+
+var (
+	ErrMoveEmptyPath   = errors.New("move path is empty")
+	ErrMoveInvalidStep = errors.New("move step is invalid")
+	ErrMoveTooFar      = errors.New("move start is too far from server position")
+	ErrMoveNotWalkable = errors.New("move step is not walkable")
+	ErrMoveNotAdjacent = errors.New("move steps are not adjacent")
+	ErrMoveTooFast     = errors.New("move is too fast")
+)
+
+// 格子距离, 斜着走一步也算一格
+func gridDistance(x1, y1, x2, y2 coord.Coord) coord.Coord {
+	dx, dy := x1-x2, y1-y2
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
+
+// 沿直线逐格检查(Bresenham), 两点之间不能穿过不可行走的格子
+func isLineWalkable(x0, y0, x1, y1 coord.Coord, walkable func(x, y coord.Coord) bool) bool {
+	dx, dy := x1-x0, y1-y0
+	sx, sy := coord.Coord(1), coord.Coord(1)
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	err := dx - dy
+	for {
+		if !walkable(x0, y0) {
+			return false
+		}
+		if x0 == x1 && y0 == y1 {
+			return true
+		}
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// 校验路径: 起点离服务器位置不能太远, 每一段都不能穿墙, 每一步都可行走且与上一步相邻
+// 寻路的路径0是y坐标，1是X坐标
+func checkTracePaths(pos coord.Vector3, paths [][]int32, walkable func(x, y coord.Coord) bool) error {
+	if len(paths) == 0 {
+		return ErrMoveEmptyPath
+	}
+	for i, step := range paths {
+		if len(step) < 2 {
+			return ErrMoveInvalidStep
+		}
+		x, y := coord.Coord(step[1]), coord.Coord(step[0])
+		if !walkable(x, y) {
+			return ErrMoveNotWalkable
+		}
+		if i == 0 {
+			if gridDistance(pos.X, pos.Y, x, y) > HERO_MOVE_MAX_DEVIATION {
+				return ErrMoveTooFar
+			}
+			if !isLineWalkable(pos.X, pos.Y, x, y, walkable) {
+				return ErrMoveNotWalkable
+			}
+			continue
+		}
+		prev := paths[i-1]
+		px, py := coord.Coord(prev[1]), coord.Coord(prev[0])
+		if gridDistance(px, py, x, y) > 1 {
+			return ErrMoveNotAdjacent
+		}
+		if !isLineWalkable(px, py, x, y, walkable) {
+			return ErrMoveNotWalkable
+		}
+	}
+	return nil
+}
+
+// 统计周期内从起点移动的格子数不能超过按StepTime计算的步数
+func (h *Hero) checkMoveSpeed(x, y coord.Coord, now int64) error {
+	if h.moveCheckTs == 0 || now-h.moveCheckTs > HERO_MOVE_CHECK_PERIOD {
+		h.moveCheckTs = now
+		h.moveCheckPos = h.GetPos()
+	}
+	if h.StepTime <= 0 {
+		return nil
+	}
+	steps := coord.Coord((now - h.moveCheckTs) / int64(h.StepTime))
+	if gridDistance(h.moveCheckPos.X, h.moveCheckPos.Y, x, y) > steps+HERO_MOVE_MAX_DEVIATION {
+		return ErrMoveTooFast
+	}
+	return nil
+}
+
+func (h *Hero) checkMove(paths [][]int32, now int64) error {
+	return checkMovePaths(h.GetPos(), paths, h.scene.IsWalkable, func(x, y coord.Coord) error {
+		return h.checkMoveSpeed(x, y, now)
+	})
+}
+
+// 只按服务器时间校验路径起点的速度, 之后的每一步都和上一步相邻, 由移动的定时器按StepTime走
+func checkMovePaths(pos coord.Vector3, paths [][]int32, walkable func(x, y coord.Coord) bool, checkSpeed func(x, y coord.Coord) error) error {
+	if err := checkTracePaths(pos, paths, walkable); err != nil {
+		return err
+	}
+	return checkSpeed(coord.Coord(paths[0][1]), coord.Coord(paths[0][0]))
+}
+
+func (h *Hero) checkMoveStop(x, y coord.Coord, now int64) error {
+	return checkMoveStop(h.GetPos(), x, y, h.scene.IsWalkable, func(x, y coord.Coord) error {
+		return h.checkMoveSpeed(x, y, now)
+	})
+}
+
+// 停止点离服务器位置不能太远, 并且从服务器位置直线走过去不能穿墙
+func checkMoveStop(pos coord.Vector3, x, y coord.Coord, walkable func(x, y coord.Coord) bool, checkSpeed func(x, y coord.Coord) error) error {
+	if gridDistance(pos.X, pos.Y, x, y) > HERO_MOVE_MAX_DEVIATION {
+		return ErrMoveTooFar
+	}
+	if !isLineWalkable(pos.X, pos.Y, x, y, walkable) {
+		return ErrMoveNotWalkable
+	}
+	return checkSpeed(x, y)
+}
+
+// 校验失败时停在服务器的位置, 前端收到自己的OnHeroMoveStopped后需要拉回
+func (h *Hero) correctPosition() {
+	h.clearTracePaths()
+	h.Broadcast(protocol.OnHeroMoveStopped, &protocol.HeroMoveStopResponse{
+		ID:   h.GetID(),
+		PosX: h.GetPos().X,
+		PosY: h.GetPos().Y,
+		PosZ: h.GetPos().Z,
+	}, true)
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTracePaths(t *testing.T) {
+	// x=5这一列是墙
+	walkable := func(x, y coord.Coord) bool {
+		return x >= 0 && y >= 0 && x < 10 && y < 10 && x != 5
+	}
+	pos := coord.Vector3{X: 1, Y: 1}
+
+	assert.Equal(t, ErrMoveEmptyPath, checkTracePaths(pos, nil, walkable))
+	assert.Equal(t, ErrMoveInvalidStep, checkTracePaths(pos, [][]int32{{1}}, walkable))
+	assert.Nil(t, checkTracePaths(pos, [][]int32{{1, 1}, {1, 2}, {2, 3}, {3, 4}}, walkable))
+	assert.Nil(t, checkTracePaths(pos, [][]int32{{2, 3}, {3, 4}}, walkable))
+	assert.Equal(t, ErrMoveTooFar, checkTracePaths(pos, [][]int32{{1, 8}, {1, 9}}, walkable))
+	assert.Equal(t, ErrMoveNotAdjacent, checkTracePaths(pos, [][]int32{{1, 1}, {1, 3}}, walkable))
+	assert.Equal(t, ErrMoveNotWalkable, checkTracePaths(pos, [][]int32{{1, 3}, {1, 4}, {1, 5}, {1, 6}}, walkable))
+}
+
+func TestCheckMoveThroughWall(t *testing.T) {
+	// x=5这一列是墙
+	walkable := func(x, y coord.Coord) bool {
+		return x >= 0 && y >= 0 && x < 10 && y < 10 && x != 5
+	}
+	noSpeedCheck := func(x, y coord.Coord) error { return nil }
+	pos := coord.Vector3{X: 4, Y: 1}
+
+	// 路径起点和停止点都可行走, 但是直线过去要穿过墙
+	assert.Equal(t, ErrMoveNotWalkable, checkTracePaths(pos, [][]int32{{1, 6}, {1, 7}}, walkable))
+	assert.Equal(t, ErrMoveNotWalkable, checkMoveStop(pos, 6, 1, walkable, noSpeedCheck))
+	assert.Nil(t, checkMoveStop(pos, 3, 2, walkable, noSpeedCheck))
+	assert.Equal(t, ErrMoveTooFar, checkMoveStop(pos, 0, 8, walkable, noSpeedCheck))
+	assert.True(t, isLineWalkable(0, 0, 4, 9, walkable))
+	assert.False(t, isLineWalkable(0, 0, 9, 4, walkable))
+}
+
+func TestCheckMoveLongPath(t *testing.T) {
+	walkable := func(x, y coord.Coord) bool {
+		return x >= 0 && y >= 0 && x < 100 && y < 100
+	}
+	h := NewHero(nil, &model.Hero{Id: 1, StepTime: 300})
+	defer close(h.destroyCh)
+	h.SetPos(1, 1, 0)
+	checkSpeed := func(now int64) func(x, y coord.Coord) error {
+		return func(x, y coord.Coord) error { return h.checkMoveSpeed(x, y, now) }
+	}
+
+	// 30步的路径需要9000毫秒, 超过速度校验的统计周期
+	paths := make([][]int32, 0)
+	for x := int32(1); x <= 30; x++ {
+		paths = append(paths, []int32{1, x})
+	}
+	var now int64 = 1000
+	assert.Nil(t, checkMovePaths(h.GetPos(), paths, walkable, checkSpeed(now)))
+	// 按StepTime走完全程, 中途重新提交剩余的路径
+	for i := 1; i < len(paths); i++ {
+		now += int64(h.StepTime)
+		h.SetPos(coord.Coord(paths[i][1]), coord.Coord(paths[i][0]), 0)
+		assert.Nil(t, checkMovePaths(h.GetPos(), paths[i:], walkable, checkSpeed(now)), "step %d", i)
+	}
+
+	// 起点超出按时间计算的距离
+	h.SetPos(31, 1, 0)
+	assert.Equal(t, ErrMoveTooFast, h.checkMoveSpeed(40, 1, now+300))
+}
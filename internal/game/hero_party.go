@@ -0,0 +1,124 @@
+package game
+
+// 队伍由master管理, game节点只保存hero所在队伍的快照, 用于分配经验和掉落
+import (
+	"sync/atomic"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 分享经验和掉落的距离(格子)
+	PARTY_SHARE_RANGE = 30
+	// 每多一个分享的队员增加的经验(百分比)
+	PARTY_EXP_BONUS = 10
+)
+
+type heroParty struct {
+	id       int64
+	lootMode int
+	heroIds  []int64
+}
+
+func newHeroParty(info *protocol.PartyInfo) *heroParty {
+	if info == nil {
+		return nil
+	}
+	p := &heroParty{
+		id:       info.PartyId,
+		lootMode: info.LootMode,
+		heroIds:  make([]int64, 0, len(info.Members)),
+	}
+	for _, m := range info.Members {
+		p.heroIds = append(p.heroIds, m.HeroId)
+	}
+	return p
+}
+
+// 在handler和场景携程内都会读取
+func (h *Hero) setParty(info *protocol.PartyInfo) {
+	h.party.Store(newHeroParty(info))
+}
+
+func (h *Hero) partyId() int64 {
+	if p := h.party.Load(); p != nil {
+		return p.id
+	}
+	return 0
+}
+
+// 同一个场景内活着的并且在附近的队员, 按队伍内的顺序
+func (s *Scene) partyHerosNear(p *heroParty, pos coord.Vector3) []*Hero {
+	heros := make([]*Hero, 0, len(p.heroIds))
+	for _, heroId := range p.heroIds {
+		v, ok := s.heros.Load(heroId)
+		if !ok {
+			continue
+		}
+		h := v.(*Hero)
+		if h.partyId() != p.id || !h.IsAlive() {
+			continue
+		}
+		if gridDistance(h.GetPos().X, h.GetPos().Y, pos.X, pos.Y) > PARTY_SHARE_RANGE {
+			continue
+		}
+		heros = append(heros, h)
+	}
+	return heros
+}
+
+// 每个队员的经验, 队伍获得的经验由附近的队员平分, 人数越多加成越高
+func partyExpShare(exp int64, cnt int) int64 {
+	if cnt <= 0 {
+		return 0
+	}
+	share := exp * int64(100+PARTY_EXP_BONUS*(cnt-1)) / 100 / int64(cnt)
+	if share < 1 {
+		share = 1
+	}
+	return share
+}
+
+// 掉落物品的归属, 自由拾取时队员都可以拾取, 轮流分配时依次归附近的一个队员
+func (s *Scene) lootOwner(m *Monster, ownerId int64) (int64, int64) {
+	v, ok := s.heros.Load(ownerId)
+	if !ok {
+		return ownerId, 0
+	}
+	p := v.(*Hero).party.Load()
+	if p == nil {
+		return ownerId, 0
+	}
+	if p.lootMode != constants.PARTY_LOOT_ROUND {
+		return ownerId, p.id
+	}
+	heros := s.partyHerosNear(p, m.GetPos())
+	if len(heros) == 0 {
+		return ownerId, 0
+	}
+	c, _ := s.partyLoots.LoadOrStore(p.id, &atomic.Int64{})
+	index := c.(*atomic.Int64).Add(1) - 1
+	return heros[index%int64(len(heros))].GetID(), 0
+}
+
+// master通知hero所在的队伍变化
+func (manager *SceneManager) HeroPartyChanged(s *session.Session, req *protocol.HeroPartyChangedRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("hero:%d 队伍变化签名错误", req.HeroId)
+		return errutil.ErrPermissionDenied
+	}
+	h, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	if h.GetID() != req.HeroId {
+		return errutil.ErrHeroNotFound
+	}
+	h.setParty(req.Party)
+	return nil
+}
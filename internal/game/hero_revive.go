@@ -0,0 +1,132 @@
+package game
+
+// hero死亡后可以原地复活(消耗金币)或者回到场景的出生点复活, 复活后有一段时间无敌
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/protocol"
+)
+
+const (
+	HERO_REVIVE_RESPAWN  = 0 //出生点复活
+	HERO_REVIVE_IN_PLACE = 1 //原地复活
+
+	// 原地复活消耗的金币
+	HERO_REVIVE_COIN = 10
+	// 复活后的无敌时间(毫秒)
+	HERO_INVINCIBLE_TIME = 3000
+)
+
+var (
+	ErrHeroAlive    = errors.New("hero is alive")
+	ErrHeroReviving = errors.New("hero is reviving")
+)
+
+func (h *Hero) IsInvincible() bool {
+	return time.Now().UnixMilli() < h.invincibleUntil
+}
+
+// 场景的出生点, 没有配置的话在原地复活
+func (h *Hero) respawnPos() coord.Vector3 {
+	sceneData := h.scene.sceneData
+	if sceneData.Enterx > 0 && sceneData.Entery > 0 {
+		return coord.Vector3{X: coord.Coord(sceneData.Enterx), Y: coord.Coord(sceneData.Entery), Z: coord.Coord(sceneData.Enterz)}
+	}
+	return h.GetPos()
+}
+
+func (h *Hero) Revive(mode int) error {
+	h.PushTask(func() {
+		if err := h.doRevive(mode, time.Now().UnixMilli()); err != nil {
+			logger.Warningf("hero:%d revive mode:%d err: %v", h.GetID(), mode, err)
+		}
+	})
+	return nil
+}
+
+func (h *Hero) doRevive(mode int, now int64) error {
+	if h.scene == nil {
+		return nil
+	}
+	if h.IsAlive() {
+		return ErrHeroAlive
+	}
+	if h.reviving {
+		return ErrHeroReviving
+	}
+	switch mode {
+	case HERO_REVIVE_IN_PLACE:
+		// 扣费完成前不能再复活, 不会重复扣费
+		h.reviving = true
+		uid := h.GetUID()
+		async.Run(func() {
+			if err := db.UserLoseCoinByUID(uid, HERO_REVIVE_COIN); err != nil {
+				h.PushTask(func() {
+					h.reviving = false
+					logger.Warningf("hero:%d revive mode:%d err: %v", h.GetID(), mode, err)
+				})
+				return
+			}
+			h.reviveAfterPaid(uid)
+		})
+	case HERO_REVIVE_RESPAWN:
+		h.revive(h.respawnPos(), now)
+	default:
+		return errors.New("unknown revive mode")
+	}
+	return nil
+}
+
+// 扣费后在场景携程内复活, 复活的task执行前hero已经销毁时退回金币, 两者只会执行一个
+func (h *Hero) reviveAfterPaid(uid int64) {
+	var settled atomic.Bool
+	done := make(chan struct{})
+	h.PushTask(func() {
+		if !settled.CompareAndSwap(false, true) {
+			return
+		}
+		close(done)
+		h.reviving = false
+		h.revive(h.GetPos(), time.Now().UnixMilli())
+	})
+	select {
+	case <-done:
+	case <-h.destroyCh:
+		if !settled.CompareAndSwap(false, true) {
+			return
+		}
+		if err := db.UserAddCoin(uid, HERO_REVIVE_COIN); err != nil {
+			logger.Errorf("hero:%d 退回复活金币失败: %v", h.GetID(), err)
+		}
+	}
+}
+
+func (h *Hero) revive(pos coord.Vector3, now int64) {
+	h.Life = h.MaxLife
+	h.Mana = h.MaxMana
+	h.Idle()
+	h.invincibleUntil = now + HERO_INVINCIBLE_TIME
+	// 传送后重新开始移动速度的校验
+	h.moveCheckTs = 0
+	// 位置变化后由场景刷新视野, 看不到的会收到OnExitView, 新看到的会收到OnEnterView
+	h.SetPos(pos.X, pos.Y, pos.Z)
+	h.Broadcast(protocol.OnHeroRevive, &protocol.HeroReviveResponse{
+		ID:             h.GetID(),
+		EntityType:     constants.ENTITY_TYPE_HERO,
+		Life:           h.Life,
+		MaxLife:        h.MaxLife,
+		Mana:           h.Mana,
+		MaxMana:        h.MaxMana,
+		PosX:           pos.X,
+		PosY:           pos.Y,
+		PosZ:           pos.Z,
+		InvincibleTime: HERO_INVINCIBLE_TIME,
+	}, true)
+	logger.Debugf("hero:%d-%s revive at:%v", h.GetID(), h._name, pos)
+}
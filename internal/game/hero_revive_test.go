@@ -0,0 +1,64 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lonng/nano/scheduler"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/dbtest"
+	"github.com/nano/gameserver/db/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviveInPlace(t *testing.T) {
+	dbtest.Start(t, new(model.User))
+	uid, err := db.InsertUser(&model.User{Coin: HERO_REVIVE_COIN})
+	assert.Nil(t, err)
+
+	s := &Scene{chTasks: make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE)}
+	h := NewHero(nil, &model.Hero{Id: 1, Uid: uid, BaseLife: 100})
+	defer close(h.destroyCh)
+	h.Entity.onEnterScene(s)
+	// 扣费在场景携程外执行, 完成后投递回场景
+	runTasks := func() {
+		assert.Eventually(t, func() bool { return len(s.chTasks) > 0 }, time.Second, time.Millisecond)
+		for len(s.chTasks) > 0 {
+			s._doTask(<-s.chTasks)
+		}
+	}
+
+	h.Life = 0
+	now := time.Now().UnixMilli()
+	assert.Nil(t, h.doRevive(HERO_REVIVE_IN_PLACE, now))
+	assert.Equal(t, ErrHeroReviving, h.doRevive(HERO_REVIVE_RESPAWN, now))
+	runTasks()
+	assert.True(t, h.IsAlive())
+	assert.False(t, h.reviving)
+	u, err := db.QueryUser(uid)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), u.Coin)
+
+	// 金币不足时不复活
+	h.Life = 0
+	assert.Nil(t, h.doRevive(HERO_REVIVE_IN_PLACE, now))
+	runTasks()
+	assert.False(t, h.IsAlive())
+	assert.False(t, h.reviving)
+
+	// 复活的task执行前下线, 退回金币
+	assert.Nil(t, db.UserAddCoin(uid, HERO_REVIVE_COIN))
+	h2 := NewHero(nil, &model.Hero{Id: 2, Uid: uid, BaseLife: 100})
+	h2.Entity.onEnterScene(s)
+	h2.Life = 0
+	assert.Nil(t, h2.doRevive(HERO_REVIVE_IN_PLACE, now))
+	assert.Eventually(t, func() bool { return len(s.chTasks) > 0 }, time.Second, time.Millisecond)
+	h2.Entity.Destroy()
+	close(h2.destroyCh)
+	runTasks()
+	assert.False(t, h2.IsAlive())
+	assert.Eventually(t, func() bool {
+		u, err := db.QueryUser(uid)
+		return err == nil && u.Coin == HERO_REVIVE_COIN
+	}, time.Second, time.Millisecond)
+}
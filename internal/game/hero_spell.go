@@ -0,0 +1,131 @@
+package game
+
+import (
+	"errors"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/coord"
+)
+
+var (
+	ErrSpellNotFound      = errors.New("spell not found")
+	ErrSpellCooldown      = errors.New("spell is cooling down")
+	ErrSpellNoMana        = errors.New("not enough mana")
+	ErrSpellInvalidTarget = errors.New("spell target is invalid")
+	ErrSpellOutOfRange    = errors.New("spell target is out of range")
+)
+
+func (h *Hero) SetSpells(spells []*object.SpellObject) {
+	h.spells = make([]*object.SpellObject, 0, len(spells))
+	for _, spell := range spells {
+		// 需要拷贝对象，不能直接用指针，否则cd会共用一个指针
+		var spell2 = *spell
+		h.spells = append(h.spells, &spell2)
+	}
+}
+
+func (h *Hero) GetSpell(spellId int) *object.SpellObject {
+	for _, spell := range h.spells {
+		if spell.SpellId == spellId {
+			return spell
+		}
+	}
+	return nil
+}
+
+// 技能释放距离, 没有配置的按hero的攻击范围计算
+func (h *Hero) IsInSpellAttackRange(spell *object.SpellObject, x, y coord.Coord) bool {
+	arange := coord.Coord(spell.Data.AttackRange)
+	if arange <= 0 {
+		arange = coord.Coord(h.AttackRange)
+	}
+	if arange <= 0 {
+		arange = 1
+	}
+	return gridDistance(h.GetPos().X, h.GetPos().Y, x, y) <= arange
+}
+
+func (h *Hero) ReleaseSpell(spellId int, targetId int64, targetType int) error {
+	h.PushTask(func() {
+		if err := h.doReleaseSpell(spellId, targetId, targetType); err != nil {
+			logger.Debugf("hero:%d release spell:%d target:%d-%d err: %v", h.GetID(), spellId, targetType, targetId, err)
+		}
+	})
+	return nil
+}
+
+func (h *Hero) doReleaseSpell(spellId int, targetId int64, targetType int) error {
+	if h.scene == nil {
+		return nil
+	}
+	if !h.IsAlive() {
+		return ErrHeroDead
+	}
+	spell := h.GetSpell(spellId)
+	if spell == nil {
+		return ErrSpellNotFound
+	}
+	if spell.CurCdTime > 0 {
+		return ErrSpellCooldown
+	}
+	if h.Mana < spell.Data.Mana {
+		return ErrSpellNoMana
+	}
+	target, err := h.findSpellTarget(spell, targetId, targetType)
+	if err != nil {
+		return err
+	}
+	if target != h && !h.IsInSpellAttackRange(spell, target.GetPos().X, target.GetPos().Y) {
+		return ErrSpellOutOfRange
+	}
+	if target != h {
+		h.setViewFocus(target)
+	}
+	h.AttackAction()
+	spell.ResetCDTime()
+	h.doManaCost(spell.Data.Mana)
+	// 飞行中的技能对象单独一份, 不影响hero身上技能的cd
+	cast := *spell
+	h.scene.CreateSpellEntity(h, &cast, target)
+	logger.Debugf("hero:%d release spell:%d-%s target:%d", h.GetID(), spell.SpellId, spell.Name, target.GetID())
+	return nil
+}
+
+// 根据技能类型检查释放对象
+func (h *Hero) findSpellTarget(spell *object.SpellObject, targetId int64, targetType int) (IMovableEntity, error) {
+	switch spell.SpellType {
+	case constants.SPELL_TYPE_SELF:
+		return h, nil
+	case constants.SPELL_TYPE_ALLY:
+		if targetId == 0 || targetId == h.GetID() {
+			return h, nil
+		}
+		if targetType != constants.ENTITY_TYPE_HERO {
+			return nil, ErrSpellInvalidTarget
+		}
+		val, ok := h.scene.heros.Load(targetId)
+		if !ok {
+			return nil, ErrAttackTargetNotFound
+		}
+		target := val.(*Hero)
+		if !target.IsAlive() || target.IsDestroyed() {
+			return nil, ErrSpellInvalidTarget
+		}
+		return target, nil
+	case constants.SPELL_TYPE_ENEMY:
+		if targetType != constants.ENTITY_TYPE_MONSTER {
+			return nil, ErrSpellInvalidTarget
+		}
+		val, ok := h.scene.monsters.Load(targetId)
+		if !ok {
+			return nil, ErrAttackTargetNotFound
+		}
+		target := val.(*Monster)
+		if !h.CanAttackTarget(target) {
+			return nil, ErrSpellInvalidTarget
+		}
+		return target, nil
+	}
+	return nil, ErrSpellInvalidTarget
+}
@@ -0,0 +1,111 @@
+package game
+
+// hero切换场景时在场景之间传递运行时数据, 流程见protocol/transfer.go
+import (
+	"errors"
+
+	"github.com/lonng/nano/session"
+	constants2 "github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+// 需要在hero的task携程内执行
+func (h *Hero) transferState() *protocol.HeroState {
+	st := &protocol.HeroState{
+		Life:            h.Life,
+		Mana:            h.Mana,
+		Buffers:         snapshotBuffers(h.buffers),
+		SpellCdTimes:    make(map[int]int),
+		InvincibleUntil: h.invincibleUntil,
+	}
+	st.ViewRange[0], st.ViewRange[1] = h.GetViewRange()
+	for _, spell := range h.spells {
+		if spell.CurCdTime > 0 {
+			st.SpellCdTimes[spell.SpellId] = spell.CurCdTime
+		}
+	}
+	return st
+}
+
+// 需要在进入场景之前执行
+func (h *Hero) restoreTransferState(st *protocol.HeroState) {
+	h.Life = min(st.Life, h.MaxLife)
+	h.Mana = min(st.Mana, h.MaxMana)
+	if h.Life <= 0 {
+		h.SetState(constants2.ACTION_STATE_DIE)
+	}
+	h.invincibleUntil = st.InvincibleUntil
+	for _, spell := range h.spells {
+		spell.CurCdTime = st.SpellCdTimes[spell.SpellId]
+	}
+	h.restoreBuffers(h, st.Buffers)
+	if st.ViewRange[0] > 0 && st.ViewRange[1] > 0 {
+		h.SetViewRange(st.ViewRange[0], st.ViewRange[1])
+	}
+}
+
+// master通知hero离开场景, 保存数据并移除后把最新的数据回复给master
+func (manager *SceneManager) HeroTransferOut(s *session.Session, req *protocol.HeroTransferOutRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("scene:%d Hero:%d HeroTransferOut 签名错误", req.SceneId, req.HeroId)
+		return errutil.ErrPermissionDenied
+	}
+	scene := manager.findScene(req.SceneId, req.LineId, req.InstanceId)
+	if scene == nil {
+		logger.Errorf("scene:%d Hero:%d HeroTransferOut err: scene not found", req.SceneId, req.HeroId)
+		return errors.New("scene not found")
+	}
+	v, ok := scene.heros.Load(req.HeroId)
+	if !ok {
+		logger.Errorf("scene:%d Hero:%d HeroTransferOut err: hero not found", req.SceneId, req.HeroId)
+		return errors.New("hero not found")
+	}
+	hero := v.(*Hero)
+	if hero.GetUID() != s.UID() {
+		logger.Warningf("scene:%d Hero:%d HeroTransferOut 不是自己的hero, uid:%d", req.SceneId, req.HeroId, s.UID())
+		return errutil.ErrPermissionDenied
+	}
+	hero.PushTask(func() {
+		if hero.scene != scene {
+			return
+		}
+		st := hero.transferState()
+		hero.saveNow()
+		heroData := hero.Hero
+		if req.CellMigrate {
+			hero.clearCellOnlyViews(s)
+		}
+		logger.Debugf("hero:%d_%s 离开场景:%d, transfer:%d", hero.GetID(), hero._name, req.SceneId, req.TransferId)
+		hero.DestroyWithoutSession()
+		// 背包保存完成后再通知master, 新的场景从数据库加载背包
+		hero.saveBagThen(func(error) {
+			ready := &protocol.HeroTransferReadyRequest{
+				Uid:        heroData.Uid,
+				HeroId:     heroData.Id,
+				TransferId: req.TransferId,
+				HeroData:   &heroData,
+				State:      st,
+			}
+			ready.Sign = ready.SignWith(viper.GetString("cluster.secret"))
+			if err := s.RPC("Manager.HeroTransferReady", ready); err != nil {
+				logger.Errorf("rpc.Call(Manager.HeroTransferReady) err: %v", err)
+			}
+		})
+	})
+	return nil
+}
+
+func notifyTransferDone(s *session.Session, h *Hero, sceneId int, transferId int64) {
+	done := &protocol.HeroTransferDoneRequest{
+		Uid:        h.GetUID(),
+		HeroId:     h.GetID(),
+		SceneId:    sceneId,
+		TransferId: transferId,
+	}
+	done.Sign = done.SignWith(viper.GetString("cluster.secret"))
+	if err := s.RPC("Manager.HeroTransferDone", done); err != nil {
+		logger.Errorf("rpc.Call(Manager.HeroTransferDone) err: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeroTransferOutSign(t *testing.T) {
+	viper.Set("cluster.secret", "test-secret")
+	defer viper.Set("cluster.secret", "")
+	manager := &SceneManager{}
+
+	// 客户端直接调用, 没有签名
+	req := &protocol.HeroTransferOutRequest{SceneId: 1, HeroId: 2, TransferId: 3}
+	assert.Equal(t, errutil.ErrPermissionDenied, manager.HeroTransferOut(nil, req))
+
+	// 签名后改成别人的hero
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+	req.HeroId = 4
+	assert.Equal(t, errutil.ErrPermissionDenied, manager.HeroTransferOut(nil, req))
+}
@@ -0,0 +1,200 @@
+package game
+
+// 视野数量上限, 同屏对象太多时按优先级选择推送给客户端的对象
+// 优先级: 队友 > 当前目标 > 攻击自己的对象 > BOSS, 相同时距离越近越优先
+// 已经在视野内的对象额外加分, 避免在上限附近反复进出视野; 没有推送的对象只推送数量(OnViewHidden)
+import (
+	"sort"
+	"time"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 没有配置时的视野上限
+	VIEW_DEFAULT_MAX_ENTITIES = 100
+
+	VIEW_PRIORITY_PARTY    = 1000
+	VIEW_PRIORITY_TARGET   = 800
+	VIEW_PRIORITY_ATTACKER = 600
+	VIEW_PRIORITY_BOSS     = 400
+	// 已经在视野内的加分, 要比视野范围内的最大距离大
+	VIEW_PRIORITY_VISIBLE = 100
+
+	// 攻击过自己的对象保持优先的时间(毫秒)
+	VIEW_ATTACKER_KEEP = 10 * 1000
+)
+
+func viewMaxEntities() int {
+	if n := viper.GetInt("game-server.view_max_entities"); n > 0 {
+		return n
+	}
+	return VIEW_DEFAULT_MAX_ENTITIES
+}
+
+type viewCandidate struct {
+	entity   IMovableEntity
+	priority int
+	visible  bool
+}
+
+// 当前攻击或者释放技能的目标
+func (h *Hero) setViewFocus(target IMovableEntity) {
+	h.viewFocus.Store(target.GetUUID())
+}
+
+func (h *Hero) onBeenAttacked(target IMovableEntity) {
+	h.viewAttackers.Store(target.GetUUID(), time.Now().UnixMilli()+VIEW_ATTACKER_KEEP)
+}
+
+func (h *Hero) isRecentAttacker(uuid string, now int64) bool {
+	v, ok := h.viewAttackers.Load(uuid)
+	if !ok {
+		return false
+	}
+	if now > v.(int64) {
+		h.viewAttackers.Delete(uuid)
+		return false
+	}
+	return true
+}
+
+func (h *Hero) isPartyMember(heroId int64) bool {
+	p := h.party.Load()
+	if p == nil {
+		return false
+	}
+	for _, id := range p.heroIds {
+		if id == heroId {
+			return true
+		}
+	}
+	return false
+}
+
+func isBossEntity(e IMovableEntity) bool {
+	switch val := e.(type) {
+	case *Monster:
+		return val.Grade >= constants.MONSTER_GRADE_BOSS
+	case *GhostEntity:
+		if o, ok := val.data.(*object.MonsterObject); ok {
+			return o.Grade >= constants.MONSTER_GRADE_BOSS
+		}
+	}
+	return false
+}
+
+// 分数越高越优先
+func (h *Hero) viewPriority(e IMovableEntity, now int64) int {
+	pos, epos := h.GetPos(), e.GetPos()
+	priority := -int(gridDistance(pos.X, pos.Y, epos.X, epos.Y))
+	etype := e.GetEntityType()
+	if g, ok := e.(*GhostEntity); ok {
+		etype = g.realType
+	}
+	if etype == constants.ENTITY_TYPE_HERO && h.isPartyMember(e.GetID()) {
+		priority += VIEW_PRIORITY_PARTY
+	}
+	if focus, _ := h.viewFocus.Load().(string); focus != "" && focus == e.GetUUID() {
+		priority += VIEW_PRIORITY_TARGET
+	}
+	if h.isRecentAttacker(e.GetUUID(), now) {
+		priority += VIEW_PRIORITY_ATTACKER
+	}
+	if isBossEntity(e) {
+		priority += VIEW_PRIORITY_BOSS
+	}
+	return priority
+}
+
+// 在场景携程内执行, 从视野内的对象和新看见的对象中选出优先级最高的
+func (s *Scene) refreshHeroView(h *Hero, entities []IMovableEntity) {
+	now := time.Now().UnixMilli()
+	candidates := make([]viewCandidate, 0, len(entities))
+	h.viewList.Range(func(key, value any) bool {
+		e := value.(IMovableEntity)
+		candidates = append(candidates, viewCandidate{entity: e, priority: h.viewPriority(e, now) + VIEW_PRIORITY_VISIBLE, visible: true})
+		return true
+	})
+	for _, e := range entities {
+		if e == IMovableEntity(h) || h.IsInViewList(e) {
+			continue
+		}
+		candidates = append(candidates, viewCandidate{entity: e, priority: h.viewPriority(e, now)})
+	}
+	max := viewMaxEntities()
+	if len(candidates) > max {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].priority > candidates[j].priority
+		})
+	}
+	for i, c := range candidates {
+		switch {
+		case i < max && !c.visible:
+			h.onEnterView(c.entity)
+			c.entity.onEnterOtherView(h)
+		case i >= max && c.visible:
+			h.onExitView(c.entity)
+			c.entity.onExitOtherView(h)
+		}
+	}
+	hidden := len(candidates) - max
+	if hidden < 0 {
+		hidden = 0
+	}
+	h.setViewHidden(hidden)
+}
+
+// 其他对象走进hero的视野, 已经达到上限时替换掉优先级最低的
+func (s *Scene) admitHeroView(h *Hero, e IMovableEntity) {
+	count := 0
+	h.viewList.Range(func(key, value any) bool {
+		count++
+		return true
+	})
+	if count < viewMaxEntities() {
+		h.onEnterView(e)
+		e.onEnterOtherView(h)
+		return
+	}
+	now := time.Now().UnixMilli()
+	var lowest IMovableEntity
+	lowestPriority := 0
+	h.viewList.Range(func(key, value any) bool {
+		v := value.(IMovableEntity)
+		if p := h.viewPriority(v, now) + VIEW_PRIORITY_VISIBLE; lowest == nil || p < lowestPriority {
+			lowest, lowestPriority = v, p
+		}
+		return true
+	})
+	// 不管替换还是没有进入视野, 都多了一个没有推送的对象
+	h.setViewHidden(h.viewHidden + 1)
+	if lowest == nil || h.viewPriority(e, now) <= lowestPriority {
+		return
+	}
+	h.onExitView(lowest)
+	lowest.onExitOtherView(h)
+	h.onEnterView(e)
+	e.onEnterOtherView(h)
+}
+
+func (h *Hero) setViewHidden(n int) {
+	if n == h.viewHidden {
+		return
+	}
+	h.viewHidden = n
+	h.SendMsg(protocol.OnViewHidden, &protocol.ViewHiddenResponse{Count: n})
+}
+
+// 有对象没有推送的hero需要定时重新选择, 对象离开视野后补上, 目标和攻击者变化后重新排序
+func (s *Scene) addHiddenViewHeros() {
+	s.heros.Range(func(key, value any) bool {
+		if h := value.(*Hero); h.viewHidden > 0 {
+			s.toBuildViewList.Store(h.GetUUID(), h)
+		}
+		return true
+	})
+}
@@ -0,0 +1,53 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshHeroView(t *testing.T) {
+	viper.Set("game-server.view_max_entities", 2)
+	defer viper.Set("game-server.view_max_entities", 0)
+
+	newHero := func(id int64, x int) *Hero {
+		h := NewHero(nil, &model.Hero{Id: id})
+		h.SetPos(coord.Coord(x), 10, 0)
+		return h
+	}
+	h := newHero(1, 10)
+	near := newHero(2, 11)
+	mid := newHero(3, 15)
+	far := newHero(4, 20)
+	s := &Scene{viewGrid: newViewGrid(100, 100)}
+
+	s.refreshHeroView(h, []IMovableEntity{far, mid, near})
+	assert.True(t, h.IsInViewList(near))
+	assert.True(t, h.IsInViewList(mid))
+	assert.False(t, h.IsInViewList(far))
+	assert.Equal(t, 1, h.viewHidden)
+
+	// 当前目标优先级更高, 替换掉距离远的
+	h.setViewFocus(far)
+	s.refreshHeroView(h, []IMovableEntity{far, mid, near})
+	assert.True(t, h.IsInViewList(far))
+	assert.True(t, h.IsInViewList(near))
+	assert.False(t, h.IsInViewList(mid))
+
+	// 距离只差一点时不替换已经在视野内的
+	h.viewFocus.Store("")
+	mid.SetPos(coord.Coord(12), 10, 0)
+	s.refreshHeroView(h, []IMovableEntity{far, mid, near})
+	assert.True(t, h.IsInViewList(far))
+	assert.False(t, h.IsInViewList(mid))
+
+	// 队友优先
+	other := newHero(5, 25)
+	h.party.Store(&heroParty{id: 1, heroIds: []int64{1, 5}})
+	s.admitHeroView(h, other)
+	assert.True(t, h.IsInViewList(other))
+	assert.Equal(t, 2, h.viewHidden)
+}
@@ -0,0 +1,246 @@
+package game
+
+// 副本场景, 流程见protocol/instance.go
+// 副本模板不会常驻运行, hero进入时按模板创建一份独立的场景, 结束或者所有人离开后销毁
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 模板没有配置时间限制时使用(秒)
+	INSTANCE_DEFAULT_TIME_LIMIT = 1800
+	// 所有人离开超过这个时间后放弃副本(毫秒)
+	INSTANCE_EMPTY_TIMEOUT = 60 * 1000
+	// 超时后还有hero在副本内, 重复通知master的间隔(毫秒)
+	INSTANCE_REPORT_RETRY = 10 * 1000
+	// 和npc对话的距离
+	INSTANCE_NPC_RANGE = 5
+)
+
+var ErrInstanceNotFound = errors.New("instance not found")
+
+// 只在场景携程内访问, deadline创建后不再变化
+type sceneInstance struct {
+	createAt   int64
+	deadline   int64
+	emptyAt    int64 //所有人离开的时间, 有人时为0
+	result     int
+	reportedAt int64
+	uids       map[int64]bool //进入过副本的玩家
+	closed     bool
+}
+
+func newSceneInstance(sceneData *SceneData, now int64) *sceneInstance {
+	limit := sceneData.TimeLimit
+	if limit <= 0 {
+		limit = INSTANCE_DEFAULT_TIME_LIMIT
+	}
+	return &sceneInstance{
+		createAt: now,
+		deadline: now + int64(limit)*1000,
+		uids:     make(map[int64]bool),
+	}
+}
+
+func (i *sceneInstance) getDeadline() int64 {
+	if i == nil {
+		return 0
+	}
+	return i.deadline
+}
+
+// 剩下的monster都是npc时副本完成, 模板没有配置monster的只能等超时
+func (s *Scene) instanceCleared() bool {
+	if len(s.sceneData.MonsterConfigList) == 0 {
+		return false
+	}
+	cleared := true
+	s.monsters.Range(func(key, value any) bool {
+		m := value.(*Monster)
+		if m.MonsterType != constants.MONSTER_TYPE_NPC && m.IsAlive() {
+			cleared = false
+			return false
+		}
+		return true
+	})
+	return cleared
+}
+
+// 在场景携程内执行
+func (s *Scene) updateInstance(now int64) {
+	inst := s.instance
+	if inst.closed {
+		return
+	}
+	s.heros.Range(func(key, value any) bool {
+		inst.uids[value.(*Hero).GetUID()] = true
+		return true
+	})
+	empty := s.totalPlayerCount() == 0
+	if empty && inst.emptyAt == 0 {
+		inst.emptyAt = now
+	} else if !empty {
+		inst.emptyAt = 0
+	}
+
+	if inst.result == 0 {
+		if s.instanceCleared() {
+			s.finishInstance(constants.INSTANCE_RESULT_SUCCESS, now)
+		} else if now >= inst.deadline {
+			s.finishInstance(constants.INSTANCE_RESULT_TIMEOUT, now)
+		} else if empty && now-inst.emptyAt >= INSTANCE_EMPTY_TIMEOUT {
+			s.finishInstance(constants.INSTANCE_RESULT_ABANDON, now)
+		}
+	}
+
+	if inst.result != 0 && empty {
+		s.closeInstance(now)
+		return
+	}
+	if now >= inst.deadline && now-inst.reportedAt >= INSTANCE_REPORT_RETRY {
+		// master收到后把hero送出副本
+		s.reportInstance(now, false)
+	}
+}
+
+func (s *Scene) finishInstance(result int, now int64) {
+	s.instance.result = result
+	logger.Infof("副本:%d-%d 结束, 结果:%d, 用时:%d秒", s.sceneId, s.instanceId, result, s.instanceUsedTime(now))
+	s.broadcastAll(protocol.OnInstanceResult, &protocol.InstanceResultResponse{
+		InstanceId: s.instanceId,
+		SceneId:    s.sceneId,
+		Result:     result,
+		UsedTime:   s.instanceUsedTime(now),
+	})
+	s.reportInstance(now, false)
+}
+
+func (s *Scene) instanceUsedTime(now int64) int {
+	return int((min(now, s.instance.deadline) - s.instance.createAt) / 1000)
+}
+
+// 场景携程不能自己停止, 在其他携程内停止
+func (s *Scene) closeInstance(now int64) {
+	s.instance.closed = true
+	s.reportInstance(now, true)
+	async.Run(func() {
+		defaultSceneManager.instances.Delete(s.instanceId)
+		s.Stop()
+		logger.Infof("副本:%d-%d 已销毁", s.sceneId, s.instanceId)
+	})
+}
+
+// 副本内没有hero的session可以借用, 直接通知master节点
+func (s *Scene) reportInstance(now int64, closed bool) {
+	inst := s.instance
+	inst.reportedAt = now
+	req := &protocol.InstanceFinishedRequest{
+		InstanceId: s.instanceId,
+		SceneId:    s.sceneId,
+		Result:     inst.result,
+		Uids:       make([]int64, 0, len(inst.uids)),
+		UsedTime:   s.instanceUsedTime(now),
+		Expired:    now >= inst.deadline,
+		Closed:     closed,
+	}
+	for uid := range inst.uids {
+		req.Uids = append(req.Uids, uid)
+	}
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+	async.Run(func() {
+		if err := defaultNodeLink.notify(masterAddr(), "Manager.InstanceFinished", req); err != nil {
+			logger.Errorf("副本:%d-%d 通知master失败: %v", s.sceneId, s.instanceId, err)
+		}
+	})
+}
+
+// 在handler线程执行, 副本不存在时按模板创建
+func (manager *SceneManager) loadOrCreateInstance(sceneId int, instanceId int64) (*Scene, error) {
+	if v, ok := manager.instances.Load(instanceId); ok {
+		scene := v.(*Scene)
+		if scene.sceneId != sceneId {
+			return nil, fmt.Errorf("副本:%d 不是场景:%d", instanceId, sceneId)
+		}
+		return scene, nil
+	}
+	tpl := manager.templates[sceneId]
+	if tpl == nil {
+		return nil, fmt.Errorf("场景:%d 不是副本模板", sceneId)
+	}
+	scene := newScene(tpl, 1, instanceId)
+	manager.instances.Store(instanceId, scene)
+	logger.Infof("创建副本:%d-%d", sceneId, instanceId)
+	return scene, nil
+}
+
+// instanceId不为0时查找副本
+func (manager *SceneManager) findScene(sceneId int, lineId int, instanceId int64) *Scene {
+	if instanceId > 0 {
+		if v, ok := manager.instances.Load(instanceId); ok {
+			return v.(*Scene)
+		}
+		return nil
+	}
+	return manager.getLine(sceneId, lineId)
+}
+
+// 常驻的场景和分线, 不包括副本
+func (manager *SceneManager) residentScenes() []*Scene {
+	scenes := make([]*Scene, 0, len(manager.scenes)+len(manager.lines))
+	for _, scene := range manager.scenes {
+		scenes = append(scenes, scene)
+	}
+	for _, scene := range manager.lines {
+		scenes = append(scenes, scene)
+	}
+	return scenes
+}
+
+// 常驻的场景, 分线和所有副本
+func (manager *SceneManager) allScenes() []*Scene {
+	scenes := manager.residentScenes()
+	manager.instances.Range(func(key, value any) bool {
+		scenes = append(scenes, value.(*Scene))
+		return true
+	})
+	return scenes
+}
+
+// hero在npc旁边请求进入npc配置的副本
+func (manager *SceneManager) NpcEnterInstance(s *session.Session, req *protocol.NpcEnterInstanceRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	scene := p.scene
+	if scene == nil {
+		return ErrInstanceNotFound
+	}
+	v, ok := scene.monsters.Load(req.NpcId)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	npc := v.(*Monster)
+	if npc.MonsterType != constants.MONSTER_TYPE_NPC || npc.Data.InstanceSceneId <= 0 {
+		return ErrInstanceNotFound
+	}
+	if gridDistance(p.GetPos().X, p.GetPos().Y, npc.GetPos().X, npc.GetPos().Y) > INSTANCE_NPC_RANGE {
+		return errors.New("离npc太远")
+	}
+	enter := &protocol.HeroEnterInstanceRequest{
+		Uid:             p.GetUID(),
+		HeroId:          p.GetID(),
+		SceneId:         scene.sceneId,
+		InstanceSceneId: npc.Data.InstanceSceneId,
+	}
+	enter.Sign = enter.SignWith(viper.GetString("cluster.secret"))
+	logger.Debugf("hero:%d-%s 通过npc:%d进入副本:%d", p.GetID(), p._name, npc.GetID(), enter.InstanceSceneId)
+	return s.RPC("Manager.HeroEnterInstance", enter)
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nano/gameserver/constants"
@@ -13,6 +15,7 @@ import (
 	"github.com/nano/gameserver/pkg/path"
 	"github.com/nano/gameserver/pkg/shape"
 	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
 )
 
 type rebornMonster struct {
@@ -40,11 +43,13 @@ type Monster struct {
 	cfg            *model.SceneMonsterConfig
 	bornPos        coord.Vector3
 	spells         []*object.SpellObject
+	hurtRecords    map[int64]int64 //hero造成的伤害, heroId做key, 死亡时按伤害分配经验
 }
 
 func NewMonster(data *model.Monster, offset int) *Monster {
 	m := &Monster{
 		MonsterObject: object.NewMonsterObject(data, offset),
+		hurtRecords:   make(map[int64]int64),
 	}
 	m.initEntity(m.MonsterObject.Id, data.Name, constants.ENTITY_TYPE_MONSTER, 128)
 	m.GameObject.Uuid = m.GetUUID()
@@ -225,25 +230,27 @@ func (m *Monster) Die() {
 		EntityType: constants.ENTITY_TYPE_MONSTER,
 	})
 
-	// 加入复活队列中
-	m.scene.addRebornMonster(&rebornMonster{
-		Uid:             m.GetUUID(),
-		Data:            &m.MonsterObject.Data,
-		PreparePaths:    m.preparePaths,
-		MovableRect:     m.movableRect,
-		PathFinder:      m.pathFinder,
-		Aidata:          m.aimgr.GetAiData(),
-		Cfg:             m.cfg,
-		Spells:          m.spells,
-		RebornTimestamp: time.Now().UnixMilli() + int64(m.cfg.Reborn)*1000,
-	})
+	// 加入复活队列中, 副本内的monster不复活
+	if m.scene.instanceId == 0 {
+		m.scene.addRebornMonster(&rebornMonster{
+			Uid:             m.GetUUID(),
+			Data:            &m.MonsterObject.Data,
+			PreparePaths:    m.preparePaths,
+			MovableRect:     m.movableRect,
+			PathFinder:      m.pathFinder,
+			Aidata:          m.aimgr.GetAiData(),
+			Cfg:             m.cfg,
+			Spells:          m.spells,
+			RebornTimestamp: time.Now().UnixMilli() + int64(m.cfg.Reborn)*1000,
+		})
+	}
 
 	m.PushTask(func() {
 		m.Destroy()
 	})
 }
 
-// update都会在task携程内执行
+// update在场景携程内按顺序执行
 func (m *Monster) update(curMilliSecond int64, elapsedTime int64) error {
 	err := m.movableEntity.update(curMilliSecond, elapsedTime)
 	if m.haveStepsToGo() {
@@ -360,7 +367,7 @@ func (m *Monster) Stop() {
 func (m *Monster) CanAttackTarget(target IEntity) bool {
 	switch val := target.(type) {
 	case *Hero:
-		return val.IsAlive() && !val.IsOffline() && !val.IsDestroyed()
+		return val.IsAlive() && !val.IsOffline() && !val.IsDestroyed() && !val.IsInvincible()
 	case *Monster:
 		return false
 	}
@@ -381,17 +388,27 @@ func (m *Monster) onBeenAttacked(target IMovableEntity) {
 }
 
 func (m *Monster) onBeenHurt(damage int64) {
+	m.onBeenHurtBy(nil, damage)
+}
+
+// attacker为hero时记录伤害
+func (m *Monster) onBeenHurtBy(attacker IMovableEntity, damage int64) {
 	m.PushTask(func() {
 		if !m.IsAlive() {
 			logger.Warningln("hero is dead")
 			return
 		}
+		if h, ok := attacker.(*Hero); ok && damage > 0 {
+			m.hurtRecords[h.GetID()] += min(damage, m.Life)
+		}
 		m.Life -= damage
 		if m.Life < 0 {
 			m.Life = 0
 		}
-		if m.Life > m.MaxLife {
+		if m.Life >= m.MaxLife {
 			m.Life = m.MaxLife
+			//回满血之后之前的伤害不再参与经验和掉落的分配
+			m.hurtRecords = make(map[int64]int64)
 		}
 		m.Broadcast(protocol.OnLifeChanged, &protocol.LifeChangedResponse{
 			ID:         m.GetID(),
@@ -401,32 +418,87 @@ func (m *Monster) onBeenHurt(damage int64) {
 			MaxLife:    m.MaxLife,
 		})
 		if m.Life == 0 {
+			owner := m.topDamageHero()
+			m.awardExperience()
+			if m.scene != nil {
+				m.scene.dropMonsterLoot(m, owner)
+			}
 			m.Die()
 			//死亡了
 		}
 	})
 }
 
-func (m *Monster) manaCost(mana int64) {
-	m.PushTask(func() {
-		if !m.IsAlive() {
-			logger.Warningln("monster is died")
-			return
+// 伤害最高的hero, 掉落物品归他所有
+func (m *Monster) topDamageHero() int64 {
+	var owner, top int64
+	for heroId, damage := range m.hurtRecords {
+		if damage > top {
+			owner, top = heroId, damage
 		}
-		m.Mana -= mana
-		if m.Mana < 0 {
-			m.Mana = 0
+	}
+	return owner
+}
+
+// 按伤害比例分配经验, 已经离开场景的hero不分
+// 同一个队伍的伤害合在一起, 由附近的队员分享
+func (m *Monster) awardExperience() {
+	var total int64 = 0
+	for _, damage := range m.hurtRecords {
+		total += damage
+	}
+	if total <= 0 || m.scene == nil {
+		return
+	}
+	exp := m.KillExperience()
+	parties := make(map[int64]*heroParty)
+	partyDamages := make(map[int64]int64)
+	for heroId, damage := range m.hurtRecords {
+		v, ok := m.scene.heros.Load(heroId)
+		if !ok {
+			continue
 		}
-		if m.Mana > m.MaxMana {
-			m.Mana = m.MaxMana
+		h := v.(*Hero)
+		if p := h.party.Load(); p != nil {
+			parties[p.id] = p
+			partyDamages[p.id] += damage
+			continue
 		}
-		m.Broadcast(protocol.OnManaChanged, &protocol.ManaChangedResponse{
-			ID:         m.GetID(),
-			EntityType: constants.ENTITY_TYPE_MONSTER,
-			Cost:       mana,
-			Mana:       m.Mana,
-			MaxMana:    m.MaxMana,
-		})
+		share := exp * damage / total
+		if share < 1 {
+			share = 1
+		}
+		h.addExperience(share)
+	}
+	for id, p := range parties {
+		heros := m.scene.partyHerosNear(p, m.GetPos())
+		share := partyExpShare(exp*partyDamages[id]/total, len(heros))
+		for _, h := range heros {
+			h.addExperience(share)
+		}
+	}
+	m.hurtRecords = make(map[int64]int64)
+}
+
+// 需要在场景携程内执行, 释放技能时检查和扣除魔法要同时完成
+func (m *Monster) manaCost(mana int64) {
+	if !m.IsAlive() {
+		logger.Warningln("monster is died")
+		return
+	}
+	m.Mana -= mana
+	if m.Mana < 0 {
+		m.Mana = 0
+	}
+	if m.Mana > m.MaxMana {
+		m.Mana = m.MaxMana
+	}
+	m.Broadcast(protocol.OnManaChanged, &protocol.ManaChangedResponse{
+		ID:         m.GetID(),
+		EntityType: constants.ENTITY_TYPE_MONSTER,
+		Cost:       mana,
+		Mana:       m.Mana,
+		MaxMana:    m.MaxMana,
 	})
 }
 
@@ -540,6 +612,68 @@ func (m *Monster) SpellAttack(spell *object.SpellObject, target IMovableEntity)
 	}
 	m.AttackAction()
 	spell.ResetCDTime()
+	m.manaCost(spell.Data.Mana)
 	m.scene.CreateSpellEntity(m, spell, target)
 	return nil
 }
+
+// 需要在monster的task携程内执行, 已死亡的monster由复活队列保存
+func (m *Monster) snapshot() *monsterSnapshot {
+	if !m.IsAlive() || m.cfg == nil {
+		return nil
+	}
+	ms := &monsterSnapshot{
+		Id:           m.GetID(),
+		Cfg:          *m.cfg,
+		Pos:          m.GetPos(),
+		BornPos:      m.bornPos,
+		MovableRect:  m.movableRect,
+		PreparePaths: m.preparePaths,
+		Life:         m.Life,
+		Mana:         m.Mana,
+		SpellCdTimes: make([]int, 0, len(m.spells)),
+		Buffers:      snapshotBuffers(m.buffers),
+	}
+	for _, spell := range m.spells {
+		ms.SpellCdTimes = append(ms.SpellCdTimes, spell.CurCdTime)
+	}
+	if a, ok := m.aimgr.(*monsterai); ok {
+		ms.Ai = a.snapshot()
+	}
+	return ms
+}
+
+// 解析按怪物级别配置的倍数, 逗号分隔, 第几个就是第几级, 例如"1,2,4,10,20,20"
+func parseGradeRates(str string) ([]int64, error) {
+	result := make([]int64, 0)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		rate, err := strconv.ParseInt(item, 10, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("怪物级别倍数配置错误: %s", str)
+		}
+		result = append(result, rate)
+	}
+	return result, nil
+}
+
+// 加载怪物经验和掉落的配置, 没有配置的使用默认值
+func loadMonsterConfig() error {
+	rates, err := parseGradeRates(viper.GetString("game-server.monster_grade_exp_rate"))
+	if err != nil {
+		return err
+	}
+	object.SetMonsterExpConfig(viper.GetInt64("game-server.monster_exp_per_level"), rates)
+
+	rates, err = parseGradeRates(viper.GetString("game-server.monster_grade_drop_rate"))
+	if err != nil {
+		return err
+	}
+	if len(rates) > 0 {
+		monsterGradeDropRate = rates
+	}
+	return nil
+}
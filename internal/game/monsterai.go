@@ -113,19 +113,8 @@ func (a *monsterai) processIdleState(curMilliSecond int64, elapsedTime int64) er
 }
 
 func (a *monsterai) processAttackState(curMilliSecond int64, elapsedTime int64) error {
-	needClearEnemy := a.enemy == nil
-	if a.enemy != nil {
-		switch val := a.enemy.(type) {
-		case *Hero:
-			if val.IsOffline() || !val.IsAlive() || val.IsDestroyed() {
-				needClearEnemy = true
-			}
-		case *Monster:
-			if !val.IsAlive() || val.IsDestroyed() {
-				needClearEnemy = true
-			}
-		}
-	}
+	// 与索敌使用同样的判断, 死亡、下线、无敌的hero都不再作为目标
+	needClearEnemy := a.enemy == nil || !a.monster.CanAttackTarget(a.enemy)
 	if needClearEnemy {
 		if a.monster.haveStepsToGo() {
 			a.monster.Stop()
@@ -333,3 +322,20 @@ func (a *monsterai) clearChaseRect() {
 	a.chaseRect.Width = 0
 	a.chaseRect.Height = 0
 }
+
+// 敌人对象在重启后已不存在，只保存行为状态，攻击状态恢复后会因为没有敌人而返回原点
+func (a *monsterai) snapshot() *monsterAiSnapshot {
+	return &monsterAiSnapshot{
+		BehaviorState: a.behaviorState,
+		OriginX:       a.originX,
+		OriginY:       a.originY,
+		PreparePathId: a.preparePathId,
+	}
+}
+
+func (a *monsterai) restoreSnapshot(as *monsterAiSnapshot) {
+	a.behaviorState = as.BehaviorState
+	a.originX = as.OriginX
+	a.originY = as.OriginY
+	a.preparePathId = as.PreparePathId
+}
@@ -179,12 +179,28 @@ func (m *movableEntity) addBuffer(owner IMovableEntity, state *model.BufferState
 	})
 }
 
+// 快照恢复buffer, 保留已经过的时间
+func (m *movableEntity) restoreBuffers(owner IMovableEntity, list []*object.BufferObject) {
+	m.PushTask(func() {
+		for _, o := range list {
+			m.buffers[o.Id] = restoreBuffer(owner, o)
+		}
+	})
+}
+
 func (m *movableEntity) removeBuffer(bufId int) {
 	m.PushTask(func() {
 		delete(m.buffers, bufId)
 	})
 }
 
+// 需要在task携程内执行
+func (m *movableEntity) clearBuffers() {
+	for _, buf := range m.buffers {
+		buf.Remove()
+	}
+}
+
 func (m *movableEntity) updateBuffers(curMilliSecond int64, elapsedTime int64) {
 	for _, buf := range m.buffers {
 		buf.update(curMilliSecond, elapsedTime)
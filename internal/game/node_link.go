@@ -0,0 +1,109 @@
+package game
+
+// 节点之间直接调用对方nano节点的Member服务, 不经过master和gate, 用于没有hero session的通知(cell之间同步ghost, 通知master副本结束)
+// 对方会为每个发送方创建一个session, gateAddr指向发送方自己
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/lonng/nano/cluster/clusterpb"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+)
+
+const (
+	// 节点之间通知的超时时间
+	NODE_LINK_TIMEOUT = time.Second
+)
+
+// 其他节点访问当前节点的地址
+func nodeAddr() string {
+	return fmt.Sprintf("%s:%d", viper.GetString("game-server.host"), viper.GetInt("game-server.port"))
+}
+
+// master节点的地址
+func masterAddr() string {
+	return fmt.Sprintf("%s:%d", viper.GetString("master.host"), viper.GetInt("master.port"))
+}
+
+type nodeLinkConn struct {
+	conn   *grpc.ClientConn
+	client clusterpb.MemberClient
+}
+
+type nodeLink struct {
+	mu    sync.Mutex
+	conns map[string]*nodeLinkConn
+}
+
+var defaultNodeLink = &nodeLink{
+	conns: make(map[string]*nodeLinkConn),
+}
+
+// 负数的session id不会和gate的session冲突
+func nodeLinkSessionId() int64 {
+	return -int64(crc32.ChecksumIEEE([]byte(nodeAddr()))) - 1
+}
+
+func (l *nodeLink) conn(addr string) (*nodeLinkConn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.conns[addr]; ok {
+		return c, nil
+	}
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	c := &nodeLinkConn{conn: conn, client: clusterpb.NewMemberClient(conn)}
+	l.conns[addr] = c
+	return c, nil
+}
+
+// 通知失败的连接关闭并移除, 对方节点重启或者下线后下次重新连接
+func (l *nodeLink) evict(addr string, c *nodeLinkConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[addr] != c {
+		return
+	}
+	delete(l.conns, addr)
+	c.conn.Close()
+}
+
+// 节点关闭时断开所有连接
+func (l *nodeLink) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for addr, c := range l.conns {
+		c.conn.Close()
+		delete(l.conns, addr)
+	}
+}
+
+func (l *nodeLink) notify(addr string, route string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c, err := l.conn(addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), NODE_LINK_TIMEOUT)
+	defer cancel()
+	_, err = c.client.HandleNotify(ctx, &clusterpb.NotifyMessage{
+		GateAddr:  nodeAddr(),
+		SessionId: nodeLinkSessionId(),
+		Route:     route,
+		Data:      data,
+	})
+	if err != nil {
+		l.evict(addr, c)
+	}
+	return err
+}
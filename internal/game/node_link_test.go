@@ -0,0 +1,22 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeLinkEvict(t *testing.T) {
+	l := &nodeLink{conns: make(map[string]*nodeLinkConn)}
+	// 对方节点不存在, 通知失败后连接被移除
+	addr := "127.0.0.1:1"
+	assert.NotNil(t, l.notify(addr, "Manager.GameNodeDraining", struct{}{}))
+	assert.Empty(t, l.conns)
+
+	c, err := l.conn(addr)
+	assert.Nil(t, err)
+	c2, _ := l.conn(addr)
+	assert.Equal(t, c, c2)
+	l.close()
+	assert.Empty(t, l.conns)
+}
@@ -0,0 +1,305 @@
+package object
+
+// hero的背包和身上的装备, 只在hero的task携程内操作, 修改过的格子由调用方保存到数据库
+// 物品配置由game节点启动时从数据库item表加载, 之后只读
+import (
+	"errors"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
+)
+
+var (
+	ErrBagFull         = errors.New("bag is full")
+	ErrBagInvalidSlot  = errors.New("bag slot is invalid")
+	ErrBagEmptySlot    = errors.New("bag slot is empty")
+	ErrItemNotEnough   = errors.New("item is not enough")
+	ErrItemNotEquip    = errors.New("item can not be equipped")
+	ErrEquipInvalidPos = errors.New("equip pos is invalid")
+)
+
+var itemTable = map[int]*model.Item{}
+
+func SetItemTable(items []model.Item) {
+	table := make(map[int]*model.Item, len(items))
+	for i := range items {
+		table[items[i].Id] = &items[i]
+	}
+	itemTable = table
+}
+
+func GetItemConfig(itemId int) *model.Item {
+	return itemTable[itemId]
+}
+
+type BagItem struct {
+	model.HeroItem
+	Data  *model.Item `json:"data"`
+	taken bool        //已经取出保存过, 新增的物品保存完成前id为0
+}
+
+func (i *BagItem) maxStack() int {
+	if i.Data.MaxStack < 1 {
+		return 1
+	}
+	return i.Data.MaxStack
+}
+
+type Bag struct {
+	heroId  int64
+	slots   []*BagItem       //背包格子
+	equips  map[int]*BagItem //身上的装备, 装备位置做key
+	changed map[*BagItem]struct{}
+	removed []*model.HeroItem
+}
+
+// templates返回物品的配置, 配置不存在的物品忽略
+func NewBag(heroId int64, size int, items []model.HeroItem, templates func(itemId int) *model.Item) *Bag {
+	b := &Bag{
+		heroId:  heroId,
+		slots:   make([]*BagItem, size),
+		equips:  make(map[int]*BagItem),
+		changed: make(map[*BagItem]struct{}),
+	}
+	misplaced := make([]*BagItem, 0)
+	for _, hi := range items {
+		data := templates(hi.ItemId)
+		if data == nil || hi.Count <= 0 {
+			continue
+		}
+		item := &BagItem{HeroItem: hi, Data: data}
+		if item.EquipPos > 0 {
+			if item.EquipPos == data.EquipPos && b.equips[item.EquipPos] == nil {
+				b.equips[item.EquipPos] = item
+			} else {
+				misplaced = append(misplaced, item)
+			}
+			continue
+		}
+		if item.Slot >= 0 && item.Slot < size && b.slots[item.Slot] == nil {
+			b.slots[item.Slot] = item
+		} else {
+			misplaced = append(misplaced, item)
+		}
+	}
+	// 格子冲突或越界的放到空格子里, 放不下的丢弃
+	for _, item := range misplaced {
+		slot := b.freeSlot()
+		if slot < 0 {
+			b.removed = append(b.removed, &item.HeroItem)
+			continue
+		}
+		item.Slot = slot
+		item.EquipPos = constants.EQUIP_POS_NONE
+		b.slots[slot] = item
+		b.changed[item] = struct{}{}
+	}
+	return b
+}
+
+func (b *Bag) Size() int {
+	return len(b.slots)
+}
+
+func (b *Bag) Get(slot int) *BagItem {
+	if slot < 0 || slot >= len(b.slots) {
+		return nil
+	}
+	return b.slots[slot]
+}
+
+func (b *Bag) GetEquip(pos int) *BagItem {
+	return b.equips[pos]
+}
+
+// 背包内的物品和身上的装备
+func (b *Bag) Items() []*BagItem {
+	result := make([]*BagItem, 0)
+	for _, item := range b.slots {
+		if item != nil {
+			result = append(result, item)
+		}
+	}
+	for pos := 1; pos <= constants.EQUIP_POS_MAX; pos++ {
+		if item := b.equips[pos]; item != nil {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (b *Bag) freeSlot() int {
+	for i, item := range b.slots {
+		if item == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// 能放下多少个
+func (b *Bag) capacity(data *model.Item) int {
+	stack := max(data.MaxStack, 1)
+	total := 0
+	for _, item := range b.slots {
+		if item == nil {
+			total += stack
+		} else if item.ItemId == data.Id {
+			total += max(stack-item.Count, 0)
+		}
+	}
+	return total
+}
+
+// 先叠加到已有的格子, 再放到空格子, 放不下时不做任何修改
+func (b *Bag) Add(data *model.Item, count int) error {
+	if count <= 0 {
+		return ErrItemNotEnough
+	}
+	if b.capacity(data) < count {
+		return ErrBagFull
+	}
+	for _, item := range b.slots {
+		if count == 0 {
+			break
+		}
+		if item == nil || item.ItemId != data.Id || item.Count >= item.maxStack() {
+			continue
+		}
+		n := min(item.maxStack()-item.Count, count)
+		item.Count += n
+		count -= n
+		b.changed[item] = struct{}{}
+	}
+	for count > 0 {
+		slot := b.freeSlot()
+		item := &BagItem{
+			HeroItem: model.HeroItem{HeroId: b.heroId, ItemId: data.Id, Slot: slot},
+			Data:     data,
+		}
+		item.Count = min(item.maxStack(), count)
+		count -= item.Count
+		b.slots[slot] = item
+		b.changed[item] = struct{}{}
+	}
+	return nil
+}
+
+// 移动到另一个格子, 同种物品合并, 否则交换位置
+func (b *Bag) Move(from, to int) error {
+	if from < 0 || from >= len(b.slots) || to < 0 || to >= len(b.slots) || from == to {
+		return ErrBagInvalidSlot
+	}
+	src := b.slots[from]
+	if src == nil {
+		return ErrBagEmptySlot
+	}
+	dst := b.slots[to]
+	if dst != nil && dst.ItemId == src.ItemId && dst.Count < dst.maxStack() {
+		n := min(dst.maxStack()-dst.Count, src.Count)
+		dst.Count += n
+		b.changed[dst] = struct{}{}
+		b.take(src, n)
+		return nil
+	}
+	b.slots[from], b.slots[to] = dst, src
+	src.Slot = to
+	b.changed[src] = struct{}{}
+	if dst != nil {
+		dst.Slot = from
+		b.changed[dst] = struct{}{}
+	}
+	return nil
+}
+
+// 从格子里扣除物品
+func (b *Bag) Remove(slot int, count int) (*BagItem, error) {
+	item := b.Get(slot)
+	if item == nil {
+		return nil, ErrBagEmptySlot
+	}
+	if count <= 0 || item.Count < count {
+		return nil, ErrItemNotEnough
+	}
+	b.take(item, count)
+	return item, nil
+}
+
+func (b *Bag) take(item *BagItem, count int) {
+	item.Count -= count
+	if item.Count > 0 {
+		b.changed[item] = struct{}{}
+		return
+	}
+	b.slots[item.Slot] = nil
+	delete(b.changed, item)
+	if item.Id > 0 || item.taken {
+		b.removed = append(b.removed, &item.HeroItem)
+	}
+}
+
+// 穿上格子里的装备, 原来的装备放回这个格子
+func (b *Bag) Equip(slot int) error {
+	item := b.Get(slot)
+	if item == nil {
+		return ErrBagEmptySlot
+	}
+	pos := item.Data.EquipPos
+	if item.Data.ItemType != constants.ITEM_TYPE_EQUIPMENT || pos <= 0 || pos > constants.EQUIP_POS_MAX {
+		return ErrItemNotEquip
+	}
+	old := b.equips[pos]
+	b.slots[slot] = old
+	if old != nil {
+		old.EquipPos = constants.EQUIP_POS_NONE
+		old.Slot = slot
+		b.changed[old] = struct{}{}
+	}
+	item.EquipPos = pos
+	item.Slot = 0
+	b.equips[pos] = item
+	b.changed[item] = struct{}{}
+	return nil
+}
+
+// 脱下装备放到空格子
+func (b *Bag) Unequip(pos int) error {
+	item := b.equips[pos]
+	if item == nil {
+		return ErrEquipInvalidPos
+	}
+	slot := b.freeSlot()
+	if slot < 0 {
+		return ErrBagFull
+	}
+	delete(b.equips, pos)
+	item.EquipPos = constants.EQUIP_POS_NONE
+	item.Slot = slot
+	b.slots[slot] = item
+	b.changed[item] = struct{}{}
+	return nil
+}
+
+// 身上装备增加的属性
+func (b *Bag) EquipBonus() (attack, defense, life, mana int64) {
+	for _, item := range b.equips {
+		attack += item.Data.Attack
+		defense += item.Data.Defense
+		life += item.Data.Life
+		mana += item.Data.Mana
+	}
+	return
+}
+
+// 取出需要保存和删除的数据, 新增的数据保存后会回填id
+// 删除的物品可能还在保存中, id由调用方在保存完成后确定
+func (b *Bag) TakeChanges() (changed []*model.HeroItem, removed []*model.HeroItem) {
+	for item := range b.changed {
+		item.taken = true
+		changed = append(changed, &item.HeroItem)
+	}
+	removed = b.removed
+	b.changed = make(map[*BagItem]struct{})
+	b.removed = nil
+	return
+}
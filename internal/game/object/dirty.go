@@ -0,0 +1,47 @@
+package object
+
+import (
+	"reflect"
+
+	"github.com/nano/gameserver/db/model"
+)
+
+// hero表内不需要由游戏服务器更新的字段
+var heroIgnoreColumns = map[string]bool{
+	"id":        true,
+	"create_at": true,
+	"update_at": true,
+}
+
+type heroColumn struct {
+	index int
+	name  string
+}
+
+var heroColumns = parseHeroColumns()
+
+func parseHeroColumns() []heroColumn {
+	cols := make([]heroColumn, 0)
+	t := reflect.TypeOf(model.Hero{})
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("db")
+		if name == "" || heroIgnoreColumns[name] {
+			continue
+		}
+		cols = append(cols, heroColumn{index: i, name: name})
+	}
+	return cols
+}
+
+// 对比两份数据, 返回有变化的数据库字段名
+func diffHeroColumns(old, cur *model.Hero) []string {
+	result := make([]string, 0)
+	ov := reflect.ValueOf(old).Elem()
+	cv := reflect.ValueOf(cur).Elem()
+	for _, col := range heroColumns {
+		if !reflect.DeepEqual(ov.Field(col.index).Interface(), cv.Field(col.index).Interface()) {
+			result = append(result, col.name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package object
+
+// 等级配置由game节点启动时从数据库hero_level表加载, 之后只读
+import (
+	"github.com/nano/gameserver/db/model"
+)
+
+const (
+	// 没有配置时怪物每级提供的基础经验
+	DEFAULT_MONSTER_EXP_PER_LEVEL = 10
+)
+
+var (
+	monsterExpPerLevel int64 = DEFAULT_MONSTER_EXP_PER_LEVEL
+	// 怪物级别的经验倍数: 0普通怪,1小头目,2精英怪,3大BOSS,4变态怪,5变态怪
+	monsterGradeExpRate = []int64{1, 2, 4, 10, 20, 20}
+)
+
+// 怪物经验配置由game节点启动时从配置文件加载, 没有配置的保持默认值
+func SetMonsterExpConfig(perLevel int64, gradeRate []int64) {
+	if perLevel > 0 {
+		monsterExpPerLevel = perLevel
+	}
+	if len(gradeRate) > 0 {
+		monsterGradeExpRate = gradeRate
+	}
+}
+
+type levelKey struct {
+	attrType int
+	level    int
+}
+
+var levelTable = map[levelKey]*model.HeroLevel{}
+
+func SetLevelTable(levels []model.HeroLevel) {
+	table := make(map[levelKey]*model.HeroLevel, len(levels))
+	for i := range levels {
+		l := &levels[i]
+		table[levelKey{attrType: l.AttrType, level: l.Level}] = l
+	}
+	levelTable = table
+}
+
+func GetLevelConfig(attrType, level int) *model.HeroLevel {
+	return levelTable[levelKey{attrType: attrType, level: level}]
+}
+
+// 增加经验, 经验够了连续升级, 返回升了几级
+func (h *HeroObject) AddExperience(exp int64) int {
+	if exp <= 0 {
+		return 0
+	}
+	h.Experience += exp
+	up := 0
+	for {
+		cur := GetLevelConfig(h.AttrType, h.Level)
+		if cur == nil || cur.Experience <= 0 || h.Experience < cur.Experience {
+			break
+		}
+		next := GetLevelConfig(h.AttrType, h.Level+1)
+		if next == nil {
+			//满级了
+			break
+		}
+		h.Experience -= cur.Experience
+		h.Level++
+		h.Strength += next.Strength
+		h.Agility += next.Agility
+		h.Intelligence += next.Intelligence
+		up++
+	}
+	if up > 0 {
+		h.UpdateProperty()
+	}
+	return up
+}
+
+// 击杀怪物获得的总经验
+func (m *MonsterObject) KillExperience() int64 {
+	rate := monsterGradeExpRate[0]
+	if m.Grade >= 0 && m.Grade < len(monsterGradeExpRate) {
+		rate = monsterGradeExpRate[m.Grade]
+	}
+	level := m.Level
+	if level < 1 {
+		level = 1
+	}
+	return int64(level) * monsterExpPerLevel * rate
+}
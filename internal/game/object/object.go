@@ -20,31 +20,65 @@ type GameObject struct {
 type HeroObject struct {
 	model.Hero
 	GameObject
-	Life  int64 `json:"life" db:"life" ` //
-	Mana  int64 `json:"mana" db:"mana" ` //
 	State constants.ActionState
+	//身上装备增加的属性
+	EquipAttack  int64 `json:"equip_attack"`
+	EquipDefense int64 `json:"equip_defense"`
+	EquipLife    int64 `json:"equip_life"`
+	EquipMana    int64 `json:"equip_mana"`
+	//所在的公会, 由master通知, 不保存在hero表
+	GuildId   int64  `json:"guild_id"`
+	GuildName string `json:"guild_name"`
+	//上次写入数据库的数据, 用于计算需要保存的字段
+	persisted model.Hero
 }
 
 func NewHeroObject(data *model.Hero) *HeroObject {
 	o := &HeroObject{
 		Hero:       *data,
 		GameObject: GameObject{},
+		persisted:  *data,
 	}
 	o.Posx = coord.Coord(o.InitPosx)
 	o.Posy = coord.Coord(o.InitPosy)
 	o.Posz = coord.Coord(o.InitPosz)
 	o.UpdateProperty()
-	//初始化的时候满血满蓝
-	o.Life = o.MaxLife
-	o.Mana = o.MaxMana
+	//新建或者死亡下线的hero满血满蓝, 否则使用保存的数据
+	if o.Life <= 0 {
+		o.Life = o.MaxLife
+		o.Mana = o.MaxMana
+	}
+	o.Life = min(o.Life, o.MaxLife)
+	o.Mana = min(o.Mana, o.MaxMana)
 	return o
 }
 
 func (h *HeroObject) UpdateProperty() {
-	h.MaxLife = CaculateLife(h.BaseLife, h.Strength)
-	h.MaxMana = CaculateMana(h.BaseMana, h.Intelligence)
-	h.Attack = CaculateAttack(h.AttrType, h.BaseAttack, h.Strength, h.Agility, h.Intelligence)
-	h.Defense = CaculateDefense(h.Defense, h.Agility)
+	h.MaxLife = CaculateLife(h.BaseLife, h.Strength) + h.EquipLife
+	h.MaxMana = CaculateMana(h.BaseMana, h.Intelligence) + h.EquipMana
+	h.Attack = CaculateAttack(h.AttrType, h.BaseAttack, h.Strength, h.Agility, h.Intelligence) + h.EquipAttack
+	h.Defense = CaculateDefense(h.BaseDefense, h.Agility) + h.EquipDefense
+}
+
+// 更换装备后重新计算属性, 当前的生命和魔法不超过上限
+func (h *HeroObject) SetEquipBonus(attack, defense, life, mana int64) {
+	h.EquipAttack = attack
+	h.EquipDefense = defense
+	h.EquipLife = life
+	h.EquipMana = mana
+	h.UpdateProperty()
+	h.Life = min(h.Life, h.MaxLife)
+	h.Mana = min(h.Mana, h.MaxMana)
+}
+
+// 与上次保存时相比有变化的字段
+func (h *HeroObject) DirtyColumns() []string {
+	return diffHeroColumns(&h.persisted, &h.Hero)
+}
+
+// 数据已提交保存
+func (h *HeroObject) MarkPersisted() {
+	h.persisted = h.Hero
 }
 
 func (h *HeroObject) IsAlive() bool {
@@ -171,6 +205,35 @@ func (s *SpellObject) Update(elapsedTime int64) {
 	}
 }
 
+// 掉落在地上的物品
+type ItemObject struct {
+	GameObject
+	Id           int64  `json:"id"`
+	ItemId       int    `json:"item_id"` //0为金币
+	Name         string `json:"name"`
+	Icon         string `json:"icon"`
+	ItemType     int    `json:"item_type"`
+	Count        int    `json:"count"`
+	OwnerId      int64  `json:"owner_id"`       //保护期内只有owner可以拾取
+	OwnerPartyId int64  `json:"owner_party_id"` //不为0时保护期内owner的队友也可以拾取
+	ProtectUntil int64  `json:"protect_until"`  //保护截止时间(毫秒)
+	ExpireAt     int64  `json:"expire_at"`      //消失时间(毫秒)
+}
+
+func NewItemObject(data *model.Item, count int) *ItemObject {
+	o := &ItemObject{
+		GameObject: GameObject{},
+		Count:      count,
+	}
+	if data != nil {
+		o.ItemId = data.Id
+		o.Name = data.Name
+		o.Icon = data.Icon
+		o.ItemType = data.ItemType
+	}
+	return o
+}
+
 type BufferObject struct {
 	model.BufferState
 	CurCnt      int   `json:"cur_cnt"`      //当前第几次伤害
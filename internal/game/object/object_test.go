@@ -3,6 +3,9 @@ package object
 import (
 	"testing"
 	"time"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
 )
 
 func TestMonsterIdGen(t *testing.T) {
@@ -22,3 +25,147 @@ func TestMonsterIdGen(t *testing.T) {
 		t.Log("nnid:", oid, offsetId, offsetTs, id)
 	}
 }
+
+func TestHeroDirtyColumns(t *testing.T) {
+	h := NewHeroObject(&model.Hero{Id: 1, Level: 1, BaseLife: 100, Strength: 10})
+	h.MarkPersisted()
+	if cols := h.DirtyColumns(); len(cols) != 0 {
+		t.Fatalf("unexpected dirty columns: %v", cols)
+	}
+	h.Level = 2
+	h.InitPosx = 15
+	h.UpdateAt = time.Now()
+	cols := h.DirtyColumns()
+	if len(cols) != 2 || cols[0] != "level" || cols[1] != "init_posx" {
+		t.Fatalf("unexpected dirty columns: %v", cols)
+	}
+	h.MarkPersisted()
+	if cols := h.DirtyColumns(); len(cols) != 0 {
+		t.Fatalf("unexpected dirty columns after persisted: %v", cols)
+	}
+}
+
+func TestHeroLifePersisted(t *testing.T) {
+	h := NewHeroObject(&model.Hero{Id: 1, Level: 1, BaseLife: 100, BaseMana: 50, Strength: 10})
+	if h.Life != h.MaxLife || h.Life <= 0 {
+		t.Fatalf("new hero life: %d, max: %d", h.Life, h.MaxLife)
+	}
+	h.MarkPersisted()
+	h.Life -= 10
+	h.Mana = 0
+	if cols := h.DirtyColumns(); len(cols) != 2 || cols[0] != "life" || cols[1] != "mana" {
+		t.Fatalf("unexpected dirty columns: %v", cols)
+	}
+	//重新上线时使用保存的生命和魔法
+	loaded := NewHeroObject(&h.Hero)
+	if loaded.Life != h.Life || loaded.Mana != 0 {
+		t.Fatalf("loaded life: %d, mana: %d", loaded.Life, loaded.Mana)
+	}
+}
+
+func TestHeroAddExperience(t *testing.T) {
+	SetLevelTable([]model.HeroLevel{
+		{AttrType: 0, Level: 1, Experience: 100},
+		{AttrType: 0, Level: 2, Experience: 200, Strength: 3, Agility: 1, Intelligence: 1},
+		{AttrType: 0, Level: 3, Experience: 0, Strength: 3, Agility: 1, Intelligence: 1},
+	})
+	defer SetLevelTable(nil)
+
+	h := NewHeroObject(&model.Hero{Id: 1, Level: 1, BaseLife: 100, Strength: 10})
+	maxLife := h.MaxLife
+	if up := h.AddExperience(50); up != 0 || h.Experience != 50 {
+		t.Fatalf("unexpected level up: %d, exp: %d", up, h.Experience)
+	}
+	if up := h.AddExperience(300); up != 2 || h.Level != 3 || h.Experience != 50 {
+		t.Fatalf("unexpected level up: %d, level: %d, exp: %d", up, h.Level, h.Experience)
+	}
+	if h.Strength != 16 || h.MaxLife != maxLife+6*LIFE_STRENGTH_PERM {
+		t.Fatalf("unexpected property: strength %d, max_life %d", h.Strength, h.MaxLife)
+	}
+	//满级后只增加经验
+	if up := h.AddExperience(1000); up != 0 || h.Level != 3 {
+		t.Fatalf("unexpected level up at max level: %d", up)
+	}
+}
+
+func TestMonsterKillExperience(t *testing.T) {
+	m := &MonsterObject{Level: 3, Grade: 2}
+	if exp := m.KillExperience(); exp != 3*DEFAULT_MONSTER_EXP_PER_LEVEL*4 {
+		t.Fatalf("unexpected default exp: %d", exp)
+	}
+	perLevel, rates := monsterExpPerLevel, monsterGradeExpRate
+	defer SetMonsterExpConfig(perLevel, rates)
+	SetMonsterExpConfig(5, []int64{1, 3, 6})
+	if exp := m.KillExperience(); exp != 3*5*6 {
+		t.Fatalf("unexpected configured exp: %d", exp)
+	}
+	//超出配置的级别按普通怪计算
+	m.Grade = 5
+	if exp := m.KillExperience(); exp != 3*5 {
+		t.Fatalf("unexpected exp for unknown grade: %d", exp)
+	}
+}
+
+func TestBag(t *testing.T) {
+	items := map[int]*model.Item{
+		1: {Id: 1, Name: "兽皮", MaxStack: 10},
+		3: {Id: 3, Name: "铁剑", ItemType: constants.ITEM_TYPE_EQUIPMENT, MaxStack: 1, EquipPos: constants.EQUIP_POS_WEAPON, Attack: 10},
+	}
+	templates := func(itemId int) *model.Item {
+		return items[itemId]
+	}
+	b := NewBag(1, 3, []model.HeroItem{
+		{Id: 1, ItemId: 1, Count: 8, Slot: 0},
+		{Id: 2, ItemId: 1, Count: 5, Slot: 0},  //格子冲突
+		{Id: 3, ItemId: 99, Count: 1, Slot: 2}, //配置不存在
+	}, templates)
+	if b.Get(0).Count != 8 || b.Get(1).Id != 2 || b.Get(2) != nil {
+		t.Fatalf("unexpected bag: %v", b.Items())
+	}
+	changed, _ := b.TakeChanges()
+	if len(changed) != 1 || changed[0].Slot != 1 {
+		t.Fatalf("unexpected changes: %v", changed)
+	}
+
+	//先叠加再放到空格子
+	if err := b.Add(items[1], 10); err != nil {
+		t.Fatal(err)
+	}
+	if b.Get(0).Count != 10 || b.Get(1).Count != 10 || b.Get(2).Count != 3 {
+		t.Fatalf("unexpected stack: %d %d %d", b.Get(0).Count, b.Get(1).Count, b.Get(2).Count)
+	}
+	if err := b.Add(items[3], 1); err != ErrBagFull {
+		t.Fatalf("expect bag full, got %v", err)
+	}
+
+	//满的格子不合并, 交换位置
+	if err := b.Move(2, 1); err != nil || b.Get(1).Count != 3 || b.Get(2).Count != 10 {
+		t.Fatalf("unexpected move into full stack: %v", err)
+	}
+	if _, err := b.Remove(1, 3); err != nil || b.Get(1) != nil {
+		t.Fatalf("unexpected remove: %v", err)
+	}
+	_, removed := b.TakeChanges()
+	if len(removed) != 0 {
+		t.Fatalf("unsaved item should not be removed: %v", removed)
+	}
+
+	if err := b.Add(items[3], 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Equip(0); err != ErrItemNotEquip {
+		t.Fatalf("expect not equip, got %v", err)
+	}
+	if err := b.Equip(1); err != nil || b.Get(1) != nil || b.GetEquip(constants.EQUIP_POS_WEAPON) == nil {
+		t.Fatalf("unexpected equip: %v", err)
+	}
+	if attack, _, _, _ := b.EquipBonus(); attack != 10 {
+		t.Fatalf("unexpected equip attack: %d", attack)
+	}
+	if err := b.Unequip(constants.EQUIP_POS_WEAPON); err != nil || b.Get(1).ItemId != 3 {
+		t.Fatalf("unexpected unequip: %v", err)
+	}
+	if err := b.Unequip(constants.EQUIP_POS_WEAPON); err != ErrEquipInvalidPos {
+		t.Fatalf("expect invalid pos, got %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lonng/nano/scheduler"
@@ -20,9 +21,11 @@ import (
 	"github.com/nano/gameserver/pkg/coord"
 	"github.com/nano/gameserver/pkg/fileutil"
 	"github.com/nano/gameserver/pkg/path"
+	"github.com/nano/gameserver/pkg/sceneline"
 	"github.com/nano/gameserver/pkg/shape"
 	"github.com/nano/gameserver/protocol"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 const (
@@ -41,32 +44,66 @@ type Scene struct {
 	sceneData *SceneData
 	blockInfo *BlockInfo
 	//这里注意是用的heroId做key
-	heros           sync.Map //需要线程安全
-	monsters        sync.Map
-	spells          sync.Map
-	chTasks         chan scheduler.Task
-	chStop          chan struct{}
+	heros    sync.Map //需要线程安全
+	monsters sync.Map
+	spells   sync.Map
+	items    sync.Map //地上的掉落物品
+	chTasks  chan scheduler.Task
+	chStop   chan struct{}
+	//chTasks满了之后的任务, 之后的任务也排在后面, 保证执行顺序
+	overflowMu      sync.Mutex
+	overflow        []scheduler.Task
 	toBuildViewList sync.Map
 	aoiMgr          *aoiMgr
+	//视野格子, 只在场景携程内访问
+	viewGrid *viewGrid
 
 	rebornMonsters sync.Map
+	//快照恢复的hero数据, 等待hero重连进入场景时使用, heroId做key
+	pendingHeros sync.Map
+	//怪物掉落配置, monsterId做key
+	dropTables sync.Map
+	//队伍轮流分配掉落的计数, partyId做key
+	partyLoots sync.Map
+
+	//大地图当前节点负责的cell, 不是大地图时为空
+	cell atomic.Pointer[sceneCell]
+	//相邻cell同步过来的ghost, real的uuid做key
+	ghosts sync.Map
+	//已经在当前cell创建了monster的配置, 只在场景携程内访问
+	cellMonsterCfgs map[int]bool
+
+	//分线, 从1开始
+	lineId int
+	//副本id, 不是副本时为0
+	instanceId int64
+	instance   *sceneInstance
 
 	//基于大格子算法的AOI
 	//entityBlocks [][]sync.Map
 
-	updateTicker *time.Ticker
+	updateTicker  *time.Ticker
+	saveTimer     *scheduler.Timer
+	snapshotTimer *scheduler.Timer
 	//每次更新的时间戳
 	lastUpdateTimeStamp     int64
 	refreshViewListDelatime int64
 }
 
 func NewScene(sceneData *SceneData) *Scene {
+	return newScene(sceneData, 1, 0)
+}
+
+// instanceId不为0时按模板创建副本
+func newScene(sceneData *SceneData, lineId int, instanceId int64) *Scene {
 	s := &Scene{
-		sceneId:   sceneData.Scene.Id,
-		sceneData: sceneData,
-		logger:    log.WithField(fieldDesk, sceneData.Scene.Id),
-		chTasks:   make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE),
-		chStop:    make(chan struct{}),
+		sceneId:    sceneData.Scene.Id,
+		sceneData:  sceneData,
+		logger:     log.WithField(fieldDesk, sceneData.Scene.Id),
+		chTasks:    make(chan scheduler.Task, SCENE_CHAN_BUFFER_SIZE),
+		chStop:     make(chan struct{}),
+		lineId:     lineId,
+		instanceId: instanceId,
 	}
 	s.blockInfo = NewBlockInfo()
 	buf, err := fileutil.ReadFile(fileutil.FindResourcePth(fmt.Sprintf("blocks/%s.block", s.sceneData.MapFile)))
@@ -77,14 +114,25 @@ func NewScene(sceneData *SceneData) *Scene {
 	if err != nil {
 		panic(err)
 	}
-	w := s.blockInfo.GetWidth()
+	w, h := int(s.blockInfo.GetWidth()), int(s.blockInfo.GetHeight())
 
-	s.aoiMgr = newAoiMgr(int(w), int(w/constants.SCENE_AOI_GRID_SIZE))
+	s.aoiMgr = newSceneAoiMgr(s.sceneId, w, h)
+	s.viewGrid = newViewGrid(w, h)
 
 	s.updateTicker = time.NewTicker(100 * time.Millisecond)
 	go s._tasksFunc()
 	s.initTimer()
-	s.initMonsters()
+	if instanceId > 0 {
+		// 副本不使用快照, 每次都是新的monster
+		s.instance = newSceneInstance(sceneData, time.Now().UnixMilli())
+		s.initMonsters()
+	} else if isCellScene(s.sceneId) {
+		// 等master划分cell后再创建自己cell内的monster, 不使用快照
+		s.cell.Store(&sceneCell{index: -1})
+		s.cellMonsterCfgs = make(map[int]bool)
+	} else if !s.loadSnapshot() {
+		s.initMonsters()
+	}
 
 	s.lastUpdateTimeStamp = time.Now().UnixMilli()
 	return s
@@ -99,12 +147,28 @@ func (s *Scene) initTimer() {
 	//})
 
 	// 每5S保存一次用户数据
-	scheduler.NewTimer(5*time.Second, func() {
+	s.saveTimer = scheduler.NewTimer(5*time.Second, func() {
 		if err := s.save(); err != nil {
 			logger.Printf("scene:%d save error:%v\n", s.sceneId, err)
 		}
 	})
 
+	if s.instanceId > 0 {
+		return
+	}
+	// 定时保存场景快照
+	interval := viper.GetInt("game-server.snapshot_interval")
+	if interval <= 0 {
+		interval = SNAPSHOT_DEFAULT_INTERVAL
+	}
+	s.snapshotTimer = scheduler.NewTimer(time.Duration(interval)*time.Second, func() {
+		async.Run(func() {
+			if err := s.saveSnapshot(); err != nil {
+				logger.Errorf("scene:%d save snapshot error:%v\n", s.sceneId, err)
+			}
+		})
+	})
+
 }
 
 func (s *Scene) _tasksFunc() {
@@ -116,6 +180,7 @@ func (s *Scene) _tasksFunc() {
 			return
 		case task := <-s.chTasks:
 			s._doTask(task)
+			s._doOverflow()
 		case <-s.updateTicker.C:
 			if err := s.update(); err != nil {
 				logger.Printf("scene:%d update error:%v\n", s.sceneId, err)
@@ -133,15 +198,33 @@ func (s *Scene) _doTask(f func()) {
 	f()
 }
 
+// chTasks执行完之后再执行overflow, 先进入chTasks的任务先执行
+func (s *Scene) _doOverflow() {
+	if len(s.chTasks) > 0 {
+		return
+	}
+	s.overflowMu.Lock()
+	tasks := s.overflow
+	s.overflow = nil
+	s.overflowMu.Unlock()
+	for _, task := range tasks {
+		s._doTask(task)
+	}
+}
+
+// 不会阻塞, 场景携程内也可以调用
 func (s *Scene) PushTask(task scheduler.Task) {
-	if len(s.chTasks) >= SCENE_CHAN_BUFFER_SIZE {
-		logger.Errorln("Scene chTasks缓冲区已满, 开启携程执行 chTasks <- task 操作")
-		async.Run(func() {
-			s.chTasks <- task
-		})
-	} else {
-		s.chTasks <- task
+	s.overflowMu.Lock()
+	defer s.overflowMu.Unlock()
+	if len(s.overflow) == 0 {
+		select {
+		case s.chTasks <- task:
+			return
+		default:
+			logger.Errorf("scene:%d chTasks缓冲区已满, 任务暂存到overflow", s.sceneId)
+		}
 	}
+	s.overflow = append(s.overflow, task)
 }
 
 func (s *Scene) initMonsters() {
@@ -156,15 +239,62 @@ func (s *Scene) initMonsters() {
 	logger.Println("初始怪物数量:", s.totalMonsterCount())
 }
 
-func (s *Scene) initMonsterByConfig(cfg model.SceneMonsterConfig) error {
+// monster模板数据, 初始化和快照恢复时共用
+type monsterTemplate struct {
+	cfg    *model.SceneMonsterConfig
+	data   *model.Monster
+	aidata *model.Aiconfig
+	spells []*object.SpellObject
+	spaths []*path.SerialPaths
+}
+
+func loadSpell(spellId int) (*object.SpellObject, error) {
+	spell, err := db.QuerySpell(spellId)
+	if err != nil {
+		return nil, err
+	}
+	var buf *model.BufferState
+	if spell.BufId > 0 {
+		buf, err = db.QueryBufferState(spell.BufId)
+		if err != nil {
+			logger.Warningf("spell:%d 配置的buf:%d不存在", spellId, spell.BufId)
+		}
+	}
+	return object.NewSpellObject(spell, buf), nil
+}
+
+// 技能id用逗号分隔, 不存在的技能会被忽略
+func loadSpells(spellIds string) []*object.SpellObject {
+	spells := make([]*object.SpellObject, 0)
+	for _, str := range strings.Split(spellIds, ",") {
+		str = strings.TrimSpace(str)
+		if str == "" {
+			continue
+		}
+		spellId, err := strconv.Atoi(str)
+		if err != nil {
+			logger.Errorf("spellId:%s 格式错误", str)
+			continue
+		}
+		spell, err := loadSpell(spellId)
+		if err != nil {
+			logger.Errorf("spell:%d 加载失败: %v", spellId, err)
+			continue
+		}
+		spells = append(spells, spell)
+	}
+	return spells
+}
+
+func (s *Scene) loadMonsterTemplate(cfg model.SceneMonsterConfig) (*monsterTemplate, error) {
 	monsterData, err := db.QueryMonster(cfg.MonsterId)
 	if err != nil {
 		logger.Errorln("initMonsters err::" + err.Error())
-		return err
+		return nil, err
 	}
 	aidata, err := db.QueryAiConfig(cfg.MonsterId)
 	if err != nil {
-		logger.Warningln("monster:%d 没有配置aiconfig", cfg.MonsterId)
+		logger.Warningf("monster:%d 没有配置aiconfig", cfg.MonsterId)
 	}
 
 	spells := make([]*object.SpellObject, 0)
@@ -174,20 +304,45 @@ func (s *Scene) initMonsterByConfig(cfg model.SceneMonsterConfig) error {
 			spellId, err := strconv.ParseInt(spellIdsArr[0], 10, 64)
 			if err != nil {
 				logger.Errorln("initMonsters spellId.ParseInt err::" + err.Error())
-				return err
+				return nil, err
 			}
-			spell, err := db.QuerySpell(int(spellId))
+			spell, err := loadSpell(int(spellId))
 			if err != nil {
 				logger.Errorln("initMonsters aiconfig配置的spellId不存在:::", aidata.Id, spellId)
-				return err
-			}
-			var buf *model.BufferState
-			if spell.BufId > 0 {
-				buf, err = db.QueryBufferState(spell.BufId)
+				return nil, err
 			}
-			spells = append(spells, object.NewSpellObject(spell, buf))
+			spells = append(spells, spell)
+		}
+	}
+
+	s.loadDropTable(cfg.MonsterId)
+
+	fpath := fmt.Sprintf("blocks/%s_%d,%d,%d.paths", s.sceneData.MapFile, cfg.Bornx, cfg.Borny, cfg.ARange)
+	buf, err := fileutil.ReadFile(fileutil.FindResourcePth(fpath))
+	var spaths []*path.SerialPaths
+	if err != nil {
+		fmt.Printf("%s未配置:%v\n", fpath, err)
+	} else {
+		err = json.Unmarshal(buf, &spaths)
+		if err != nil {
+			logger.Warningln("Unmarshal paths file err:", err)
 		}
 	}
+	return &monsterTemplate{
+		cfg:    &cfg,
+		data:   monsterData,
+		aidata: aidata,
+		spells: spells,
+		spaths: spaths,
+	}, nil
+}
+
+func (s *Scene) initMonsterByConfig(cfg model.SceneMonsterConfig) error {
+	tpl, err := s.loadMonsterTemplate(cfg)
+	if err != nil {
+		return err
+	}
+	monsterData, aidata, spells, spaths := tpl.data, tpl.aidata, tpl.spells, tpl.spaths
 
 	rect := shape.Rect{
 		X:      int64(cfg.Bornx - cfg.ARange),
@@ -201,21 +356,10 @@ func (s *Scene) initMonsterByConfig(cfg model.SceneMonsterConfig) error {
 	if rect.Y < 0 {
 		rect.Y = 0
 	}
-	fpath := fmt.Sprintf("blocks/%s_%d,%d,%d.paths", s.sceneData.MapFile, cfg.Bornx, cfg.Borny, cfg.ARange)
-	buf, err := fileutil.ReadFile(fileutil.FindResourcePth(fpath))
-	var spaths []*path.SerialPaths
-	if err != nil {
-		fmt.Printf("%s未配置:%v\n", fpath, err)
-	} else {
-		err = json.Unmarshal(buf, &spaths)
-		if err != nil {
-			logger.Warningln("Unmarshal paths file err:", err)
-		}
-	}
 
 	for i := 0; i < cfg.Total; i++ {
 		m := NewMonster(monsterData, i+1)
-		m.SetSceneMonsterConfig(&cfg)
+		m.SetSceneMonsterConfig(tpl.cfg)
 		if spaths != nil && len(spaths) > 0 {
 			//预制路径
 			sindex := rand.Intn(len(spaths))
@@ -286,10 +430,50 @@ func (s *Scene) GetHeight() uint32 {
 	return s.blockInfo.GetHeight()
 }
 
+// 按顺序停止场景: 定时器 -> hero -> monster -> spell -> 场景携程
+// hero需要在调用前保存好数据
 func (s *Scene) Stop() {
+	if s.saveTimer != nil {
+		s.saveTimer.Stop()
+	}
+	if s.snapshotTimer != nil {
+		s.snapshotTimer.Stop()
+	}
+	s.heros.Range(func(key, value any) bool {
+		value.(*Hero).Destroy()
+		return true
+	})
+	s.monsters.Range(func(key, value any) bool {
+		value.(*Monster).Destroy()
+		return true
+	})
+	s.spells.Range(func(key, value any) bool {
+		value.(*SpellEntity).Destroy()
+		return true
+	})
+	s.items.Range(func(key, value any) bool {
+		value.(*GroundItem).Destroy()
+		return true
+	})
+	s.ghosts.Range(func(key, value any) bool {
+		value.(*GhostEntity).Destroy()
+		return true
+	})
+	s.rebornMonsters.Range(func(key, value any) bool {
+		s.rebornMonsters.Delete(key)
+		return true
+	})
 	s.chStop <- struct{}{}
 }
 
+// 发送给场景内所有的hero
+func (s *Scene) broadcastAll(route string, msg interface{}) {
+	s.heros.Range(func(key, value any) bool {
+		value.(*Hero).SendMsg(route, msg)
+		return true
+	})
+}
+
 func (s *Scene) totalPlayerCount() int {
 	l := 0
 	s.heros.Range(func(k, v interface{}) bool {
@@ -308,26 +492,48 @@ func (s *Scene) totalMonsterCount() int {
 	return l
 }
 
-func (s *Scene) addHero(h *Hero) {
+// destPos不为空时是从传送门进入的
+func (s *Scene) addHero(h *Hero, destPos *coord.Vector3) {
 	//这个要在前面执行，并发的update内可能会取到空的scene
-	if s.sceneData.Enterx > 0 && s.sceneData.Entery > 0 {
+	if hs := s.popPendingHero(h.GetID()); hs != nil {
+		//重启前在场景内，恢复到快照时的状态
+		h.restoreSnapshot(hs)
+	} else if destPos != nil {
+		h.SetPos(destPos.X, destPos.Y, destPos.Z)
+	} else if s.sceneData.Enterx > 0 && s.sceneData.Entery > 0 {
 		//使用场景的出生点
 		h.SetPos(coord.Coord(s.sceneData.Enterx), coord.Coord(s.sceneData.Entery), coord.Coord(s.sceneData.Enterz))
 	}
+	//出生在传送门范围内的不会马上触发
+	h.inDoorId = s.doorIdAt(h.GetPos().X, h.GetPos().Y)
 
 	h.onEnterScene(s)
 	s.heros.Store(h.GetID(), h)
 	s.aoiMgr.Enter(h)
+	if s.cell.Load() != nil {
+		s.PushTask(func() {
+			s.replaceGhostHero(h.GetID())
+		})
+	}
+}
 
+func (s *Scene) sendEnterScene(h *Hero) {
 	h.SendMsg(protocol.OnEnterScene, &protocol.EnterSceneResponse{
 		Scene:    s.sceneData.Scene,
 		Doors:    s.sceneData.DoorList,
 		HeroData: *h.GetData(),
+		Spells:   h.spells,
+
+		InstanceId: s.instanceId,
+		Deadline:   s.instance.getDeadline(),
+		LineId:     s.lineId,
+		LineCount:  sceneline.Count(s.sceneId),
 	})
 }
 
 func (s *Scene) removeHero(h *Hero) {
 	s.aoiMgr.Leave(h)
+	s.leaveViewGrid(h)
 	s.heros.Delete(h.GetID())
 	h.onExitScene(s)
 }
@@ -346,6 +552,7 @@ func (s *Scene) addMonster(m *Monster) {
 
 func (s *Scene) removeMonster(m *Monster) {
 	s.aoiMgr.Leave(m)
+	s.leaveViewGrid(m)
 
 	s.monsters.Delete(m.GetID())
 	m.onExitScene(s)
@@ -359,17 +566,34 @@ func (s *Scene) addSpell(m *SpellEntity) {
 	//这个要在前面执行，并发的update内可能会取到空的scene
 	m.onEnterScene(s)
 	s.spells.Store(m.GetUUID(), m)
-
-	//s.aoiMgr.Enter(m)
+	s.aoiMgr.Enter(m)
+	s.addToBuildViewList(m)
 }
 
 func (s *Scene) removeSpell(m *SpellEntity) {
-	//s.aoiMgr.Leave(m)
-
+	//技能每帧都在移动, 在场景携程内执行, 保证在还没执行的aoi移动之后
+	s.PushTask(func() {
+		s.aoiMgr.Leave(m)
+	})
+	s.leaveViewGrid(m)
 	s.spells.Delete(m.GetUUID())
 	m.onExitScene(s)
 }
 
+func (s *Scene) addItem(e *GroundItem) {
+	e.onEnterScene(s)
+	s.items.Store(e.GetID(), e)
+	s.aoiMgr.Enter(e)
+	s.addToBuildViewList(e)
+}
+
+func (s *Scene) removeItem(e *GroundItem) {
+	s.aoiMgr.Leave(e)
+	s.leaveViewGrid(e)
+	s.items.Delete(e.GetID())
+	e.onExitScene(s)
+}
+
 // 这里的x,y需要传递，防止e对象并发更新了新的坐标，导致aoi里部分存储没有删除掉
 func (s *Scene) entityMoved(e IMovableEntity, x, y, oldX, oldY coord.Coord) {
 	if oldX != x || oldY != y {
@@ -385,17 +609,15 @@ func (s *Scene) update() error {
 	//每帧的时间间隔
 	elapsedTime := ts - s.lastUpdateTimeStamp
 
+	//在场景携程内按hero、monster、技能的顺序更新, 不再投递到各对象
 	s.heros.Range(func(key, value any) bool {
 		h := value.(*Hero)
 		if h.session == nil {
 			logger.Errorln("hero.session is nil", h.GetID(), h._name)
 			s.removeHero(h)
 		} else {
-			h.PushTask(func() {
-				err := h.update(ts, elapsedTime)
-				if err != nil {
-					logger.Errorln("hero.update err:", err)
-				}
+			s.updateEntity(&h.Entity, func() error {
+				return h.update(ts, elapsedTime)
 			})
 		}
 
@@ -403,21 +625,15 @@ func (s *Scene) update() error {
 	})
 	s.monsters.Range(func(key, value any) bool {
 		m := value.(*Monster)
-		m.PushTask(func() {
-			err := m.update(ts, elapsedTime)
-			if err != nil {
-				logger.Errorln("monster.update err:", err)
-			}
+		s.updateEntity(&m.Entity, func() error {
+			return m.update(ts, elapsedTime)
 		})
 		return true
 	})
 	s.spells.Range(func(key, value any) bool {
 		e := value.(*SpellEntity)
-		e.PushTask(func() {
-			err := e.update(ts, elapsedTime)
-			if err != nil {
-				logger.Errorln("spellEntity.update err:", err)
-			}
+		s.updateEntity(&e.Entity, func() error {
+			return e.update(ts, elapsedTime)
 		})
 		return true
 	})
@@ -431,11 +647,35 @@ func (s *Scene) update() error {
 		s.rebornMonsters.Range(func(key, value any) bool {
 			m := value.(*rebornMonster)
 			if m.RebornTimestamp <= ts {
-				s.rebornOneMonster(m)
+				if s.ownsMonsterConfig(m.Cfg) {
+					s.rebornOneMonster(m)
+				}
 				s.rebornMonsters.Delete(key)
 			}
 			return true
 		})
+
+		// 清理超时的掉落物品
+		s.items.Range(func(key, value any) bool {
+			e := value.(*GroundItem)
+			if e.IsExpired(ts) && e.claim() {
+				e.Destroy()
+			}
+			return true
+		})
+
+		if s.cell.Load() != nil {
+			s.PushTask(func() {
+				s.syncGhosts(ts)
+				s.expireGhosts(ts)
+			})
+		}
+
+		if s.instance != nil {
+			s.PushTask(func() {
+				s.updateInstance(ts)
+			})
+		}
 	}
 
 	s.lastUpdateTimeStamp = ts
@@ -443,6 +683,18 @@ func (s *Scene) update() error {
 	return nil
 }
 
+// 单个对象出错不影响其他对象的更新
+func (s *Scene) updateEntity(e *Entity, update func() error) {
+	if e.IsDestroyed() || e.GetScene() != s {
+		return
+	}
+	e._doTask(func() {
+		if err := update(); err != nil {
+			logger.Errorf("entity:%d-%s update err: %v", e.GetID(), e._name, err)
+		}
+	})
+}
+
 func (s *Scene) save() error {
 	s.heros.Range(func(key, value any) bool {
 		h := value.(*Hero)
@@ -452,6 +704,32 @@ func (s *Scene) save() error {
 	return nil
 }
 
+// 提交场景内所有hero的数据, 等待各hero的task执行完或超时
+func (s *Scene) flushHeros(timeout time.Duration) {
+	heros := make([]*Hero, 0)
+	s.heros.Range(func(key, value any) bool {
+		heros = append(heros, value.(*Hero))
+		return true
+	})
+	done := make(chan struct{}, len(heros))
+	for _, h := range heros {
+		h.PushTask(func() {
+			h.syncData()
+			h.persist(true)
+			done <- struct{}{}
+		})
+	}
+	deadline := time.After(timeout)
+	for i := 0; i < len(heros); i++ {
+		select {
+		case <-done:
+		case <-deadline:
+			logger.Warningf("scene:%d 保存hero超时, 已保存:%d/%d", s.sceneId, i, len(heros))
+			return
+		}
+	}
+}
+
 func (s *Scene) addToBuildViewList(e IMovableEntity) {
 	s.PushTask(func() {
 		s.toBuildViewList.Store(e.GetUUID(), e)
@@ -463,6 +741,7 @@ func (s *Scene) refreshViewList() {
 	//s.blockmutx.RLock()
 	//defer s.blockmutx.RUnlock()
 	s.PushTask(func() {
+		s.addHiddenViewHeros()
 		s.toBuildViewList.Range(func(key, value any) bool {
 			s._refreshEntityViewList(value.(IMovableEntity))
 			s.toBuildViewList.Delete(key)
@@ -478,67 +757,77 @@ func (s *Scene) refreshEntityViewList(entity IMovableEntity) {
 }
 
 func (s *Scene) _refreshEntityViewList(entity IMovableEntity) {
-	if entity.GetEntityType() == constants.ENTITY_TYPE_SPELL {
+	if entity.GetScene() != s || entity.IsDestroyed() {
+		//已经离开了场景
+		s.dropEntityView(entity)
 		return
 	}
-	s.updateEntityViewList(entity)
+	s.updateEntityView(entity)
+}
 
-	entites := s.aoiMgr.Search(entity.GetPos().X, entity.GetPos().Y)
-	for _, e0 := range entites {
-		if e0 == nil {
-			continue
+// 只处理格子和视野范围的变化, 没有跨格子时不需要遍历视野列表
+func (s *Scene) updateEntityView(entity IMovableEntity) {
+	entered, left := s.viewGrid.move(entity)
+	for _, h := range left {
+		if h.IsInViewList(entity) {
+			//原来在视野内，现在看不见了
+			h.onExitView(entity)      //自己离开了h的视野
+			entity.onExitOtherView(h) //清除自己记录的h能看见我
 		}
-		e := e0.(IMovableEntity)
-		if e != entity {
-			if entity.GetEntityType() == constants.ENTITY_TYPE_HERO { //&&
-				//如果我是英雄， 判定我能不能看见对方
-				if entity.CanSee(e) && !entity.IsInViewList(e) {
-					//原来不在视野内，现在看见了
-					entity.onEnterView(e)      //进入他的视野
-					e.onEnterOtherView(entity) //记录我进入了谁的视野
-				}
-			}
-			if e.GetEntityType() == constants.ENTITY_TYPE_HERO {
-				//循环的是英雄, 检查这个英雄是否能看见我
-				if e.CanSee(entity) && !e.IsInViewList(entity) {
-					//原来不在视野内，现在看见了
-					e.onEnterView(entity)      //进入他的视野
-					entity.onEnterOtherView(e) //记录我进入了谁的视野
-				}
-			}
+	}
+	for _, h := range entered {
+		if !h.IsInViewList(entity) {
+			//原来不在视野内，现在看见了, 超过视野上限时按优先级替换
+			s.admitHeroView(h, entity)
+		}
+	}
+	h, ok := entity.(*Hero)
+	if !ok {
+		return
+	}
+	seen, lost := s.viewGrid.watch(h)
+	for _, e := range lost {
+		if h.IsInViewList(e) {
+			h.onExitView(e)
+			e.onExitOtherView(h)
+		}
+	}
+	if h.viewHidden > 0 {
+		//有对象没有推送时重新按优先级选择
+		s.refreshHeroView(h, s.viewGrid.visibleEntities(h))
+		return
+	}
+	for _, e := range seen {
+		if !h.IsInViewList(e) {
+			s.admitHeroView(h, e)
 		}
 	}
 }
 
-// todo 这里的刷新视野频度会跟随同屏数量增加而成倍数增加，比如同屏一万人，那么每个人都需要遍历2万次去判断是否离开视野，这里需要重新评估是否有更好的方案
-func (s *Scene) updateEntityViewList(entity IMovableEntity) {
-	var em *movableEntity = nil
-	switch val := entity.(type) {
-	case *Hero:
-		em = &val.movableEntity
-	case *Monster:
-		em = &val.movableEntity
-	}
-	//检查当前视野内的对象是否已离开
-	if em.GetEntityType() == constants.ENTITY_TYPE_HERO {
-		em.viewList.Range(func(key, value interface{}) bool {
-			target := value.(IMovableEntity)
-			if target.GetScene() != entity.GetScene() || !entity.CanSee(target) {
-				//原来在视野内，现在看不见了
-				entity.onExitView(target)      //target离开了m的视野
-				target.onExitOtherView(entity) //清除target记录的m能看见他
-			}
-			return true
-		})
+// 离开场景后清理格子和还没有清除的视野, 已经进入其他场景的视野不处理
+func (s *Scene) dropEntityView(entity IMovableEntity) {
+	s.viewGrid.remove(entity)
+	s.toBuildViewList.Delete(entity.GetUUID())
+	for _, value := range entity.GetCanSeeMeViewList() {
+		if value.GetScene() == s {
+			value.onExitView(entity)
+			entity.onExitOtherView(value)
+		}
 	}
-	em.canSeeMeViewList.Range(func(key, value interface{}) bool {
-		target := value.(IMovableEntity)
-		if target.GetScene() != entity.GetScene() || !target.CanSee(em) {
-			//原来在视野内，现在看不见了
-			target.onExitView(entity)      //自己离开了target的视野
-			entity.onExitOtherView(target) //自己记录的m能看见我
+	if entity.GetEntityType() == constants.ENTITY_TYPE_HERO {
+		for _, value := range entity.GetViewList() {
+			if value.GetScene() == s {
+				entity.onExitView(value)
+				value.onExitOtherView(entity)
+			}
 		}
-		return true
+	}
+}
+
+// 对象离开场景时调用, 可以在任意携程调用
+func (s *Scene) leaveViewGrid(entity IMovableEntity) {
+	s.PushTask(func() {
+		s.dropEntityView(entity)
 	})
 }
 
@@ -558,12 +847,14 @@ func (s *Scene) IsWalkable(x, y coord.Coord) bool {
 // 通过圆范围查找对象
 func (s *Scene) getEntitiesByRange(cx, cy, arange coord.Coord) map[string]IMovableEntity {
 	result := make(map[string]IMovableEntity)
-	entites := s.aoiMgr.Search(cx, cy)
+	//范围可能超过九宫格, 按范围查询
+	entites := s.aoiMgr.SearchRect(cx-arange, cy-arange, cx+arange, cy+arange)
 	for _, e0 := range entites {
 		if e0 == nil {
 			continue
 		}
 		e := e0.(IMovableEntity)
+		//aoi里的坐标可能比对象的坐标旧, 用对象当前的坐标再判断一次
 		//if shape.IsInsideCircle(float64(cx), float64(cy), float64(arange), float64(e.GetPos().X), float64(e.GetPos().Y)) {
 		if coord.Coord(math.Abs(float64(cx-e.GetPos().X))) <= arange && coord.Coord(math.Abs(float64(cy-e.GetPos().Y))) <= arange {
 			//判定是否在警戒范围内
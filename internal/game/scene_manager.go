@@ -2,24 +2,53 @@ package game
 
 import (
 	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/nano/gameserver/constants"
 	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/pkg/errutil"
 	"github.com/nano/gameserver/protocol"
 
 	"github.com/lonng/nano/component"
+	"github.com/lonng/nano/scheduler"
 	"github.com/lonng/nano/session"
+	"github.com/spf13/viper"
 )
 
 const (
 	fieldDesk = "desk"
+
+	// 进程退出时等待hero数据写入的超时时间
+	HERO_FLUSH_TIMEOUT = 10 * time.Second
 )
 
 type (
+	// 场景的一条分线
+	sceneLine struct {
+		sceneId int
+		lineId  int
+	}
+
 	SceneManager struct {
 		component.Base
+		// 第1条线
 		scenes   map[int]*Scene
 		sceneIds []int
+		// 第2条线开始的分线
+		lines   map[sceneLine]*Scene
+		lineIds map[int][]int
+		// 副本模板, 不常驻运行
+		templates map[int]*SceneData
+		// 运行中的副本, instanceId做key
+		instances sync.Map
+
+		// 下线中不再接受hero进入场景
+		draining  atomic.Bool
+		drainOnce sync.Once
 	}
 )
 
@@ -27,8 +56,11 @@ var defaultSceneManager = NewSceneManager()
 
 func NewSceneManager() *SceneManager {
 	return &SceneManager{
-		scenes:   make(map[int]*Scene),
-		sceneIds: make([]int, 0),
+		scenes:    make(map[int]*Scene),
+		sceneIds:  make([]int, 0),
+		templates: make(map[int]*SceneData),
+		lines:     make(map[sceneLine]*Scene),
+		lineIds:   make(map[int][]int),
 	}
 }
 
@@ -36,14 +68,35 @@ func (manager *SceneManager) setSceneIds(sceneIds []int) {
 	manager.sceneIds = append(manager.sceneIds, sceneIds...)
 }
 
+func (manager *SceneManager) setLineIds(lineIds map[int][]int) {
+	for sceneId, ids := range lineIds {
+		manager.lineIds[sceneId] = append(manager.lineIds[sceneId], ids...)
+	}
+}
+
 func (manager *SceneManager) AfterInit() {
 	session.Lifetime.OnClosed(func(s *session.Session) {
 		// Fixed: 玩家WIFI切换到4G网络不断开, 重连时，将UID设置为illegalSessionUid
 		if err := manager.onPlayerDisconnect(s); err != nil {
-			logger.Errorf("玩家退出: UID=%d, Error=%s \n", s.UID, err.Error())
+			logger.Errorf("玩家退出: UID=%d, Error=%s \n", s.UID(), err.Error())
 		}
 	})
 
+	levels, err := db.HeroLevelList()
+	if err != nil {
+		panic(err)
+	}
+	object.SetLevelTable(levels)
+	if err := loadMonsterConfig(); err != nil {
+		panic(err)
+	}
+
+	items, err := db.ItemList()
+	if err != nil {
+		panic(err)
+	}
+	object.SetItemTable(items)
+
 	scenes, err := db.SceneList(manager.sceneIds)
 	if err != nil {
 		panic(err)
@@ -57,27 +110,81 @@ func (manager *SceneManager) AfterInit() {
 		if err != nil {
 			panic(err)
 		}
-		scene := NewScene(&SceneData{
+		data := &SceneData{
 			Scene:             sceneData,
 			DoorList:          doorList,
 			MonsterConfigList: configList,
-		})
-		manager.scenes[sceneData.Id] = scene
+		}
+		if sceneData.SceneType == constants.SCENE_TYPE_INSTANCE {
+			manager.templates[sceneData.Id] = data
+			continue
+		}
+		for _, lineId := range manager.lineIds[sceneData.Id] {
+			if lineId == 1 {
+				if manager.scenes[sceneData.Id] == nil {
+					manager.scenes[sceneData.Id] = NewScene(data)
+				}
+				continue
+			}
+			key := sceneLine{sceneId: sceneData.Id, lineId: lineId}
+			if isCellScene(sceneData.Id) {
+				logger.Warningf("scene:%d 按cell运行, 不支持分线:%d", sceneData.Id, lineId)
+				continue
+			}
+			if manager.lines[key] == nil {
+				manager.lines[key] = newScene(data, lineId, 0)
+			}
+		}
+	}
+
+	for _, scene := range manager.scenes {
+		if scene.cell.Load() != nil {
+			// 大地图定时上报心跳, 读取master的cell划分
+			scheduler.NewTimer(CELL_HEARTBEAT_INTERVAL, func() {
+				async.Run(manager.cellHeartbeat)
+			})
+			break
+		}
 	}
 }
 
+// 进程退出时保存所有hero数据和场景的快照, 下次启动时恢复
+// 正常情况下BeforeShutdown已经执行过下线流程了，这里不会重复执行
+func (manager *SceneManager) Shutdown() {
+	manager.drain(0)
+	defaultNodeLink.close()
+}
+
 func (manager *SceneManager) GetScene(sceneId int) *Scene {
 	return manager.scenes[sceneId]
 }
 
+// lineId为0时是第1条线
+func (manager *SceneManager) getLine(sceneId int, lineId int) *Scene {
+	if lineId <= 1 {
+		return manager.scenes[sceneId]
+	}
+	return manager.lines[sceneLine{sceneId: sceneId, lineId: lineId}]
+}
+
 func (manager *SceneManager) onPlayerDisconnect(s *session.Session) error {
 	p, err := heroWithSession(s)
 	if err != nil {
 		return err
 	}
 	logger.Println("SceneManager.onPlayerDisconnect: 玩家网络断开", p.scene)
-	p.bindSession(nil)
-	p.Destroy()
+	leave := func() {
+		p.saveNow()
+		p.bindSession(nil)
+		p.Destroy()
+	}
+	if p.GetScene() == nil {
+		//不在场景内, 没有并发的update
+		leave()
+		return nil
+	}
+	//在场景携程内保存, 避免和update并发读写hero数据
+	p.PushTask(leave)
 	return nil
 }
 
@@ -86,37 +193,61 @@ func (manager *SceneManager) HeroEnterScene(s *session.Session, req *protocol.He
 		logger.Errorf("scene:%d HeroEnterScene err: req.HeroData == nil", req.SceneId)
 		return errors.New("hero_data is nil")
 	}
-	scene := manager.scenes[req.SceneId]
+	if manager.IsDraining() {
+		logger.Warningf("scene:%d Hero:%d EnterScene err: 节点下线中", req.SceneId, req.HeroData.Id)
+		return errutil.ErrServerDraining
+	}
+	scene := manager.getLine(req.SceneId, req.LineId)
+	if req.InstanceId > 0 {
+		var err error
+		if scene, err = manager.loadOrCreateInstance(req.SceneId, req.InstanceId); err != nil {
+			logger.Errorf("scene:%d Hero:%d EnterScene err: %v", req.SceneId, req.HeroData.Id, err)
+			return err
+		}
+	}
 	if scene == nil {
 		logger.Errorf("scene:%d Hero:%dEnterScene err: scene not found", req.SceneId, req.HeroData.Id)
 		return errors.New("scene not found")
 	}
+	if v, ok := scene.heros.Load(req.HeroData.Id); ok && v.(*Hero).session == s {
+		//切换场景失败后master重新进入原来的场景, hero还在场景内
+		hero := v.(*Hero)
+		hero.setParty(req.Party)
+		hero.setGuild(req.Guild)
+		logger.Warningf("scene:%d Hero:%d EnterScene: 已经在场景内", req.SceneId, req.HeroData.Id)
+		if !req.CellMigrate {
+			scene.sendEnterScene(hero)
+		}
+		if req.TransferId > 0 {
+			notifyTransferDone(s, hero, req.SceneId, req.TransferId)
+		}
+		return nil
+	}
 	hero := NewHero(s, req.HeroData)
+	hero.SetSpells(loadSpells(req.HeroData.Spells))
+	if err := hero.loadBag(); err != nil {
+		logger.Errorf("scene:%d Hero:%d EnterScene 加载背包失败: %v", req.SceneId, req.HeroData.Id, err)
+		return err
+	}
+	if req.State != nil {
+		//从其他场景切换过来的, 重启前的快照已经过期了
+		scene.popPendingHero(hero.GetID())
+		hero.restoreTransferState(req.State)
+	}
+	hero.setParty(req.Party)
+	if req.Guild != nil {
+		hero.GuildId, hero.GuildName = req.Guild.GuildId, req.Guild.Name
+	}
 	s.Bind(req.HeroData.Uid)
 	hero.bindSession(s)
-	scene.addHero(hero)
-	logger.Debugf("hero:%d_%s 进入场景:%d", hero.GetID(), hero._name, req.SceneId)
-	return nil
-}
-
-func (manager *SceneManager) HeroLeaveScene(s *session.Session, req *protocol.HeroLeaveSceneRequest) error {
-	if req.HeroId <= 0 {
-		logger.Errorf("scene:%d HeroLeaveScene err: req.HeroId == %d", req.SceneId, req.HeroId)
-		return errors.New("hero_id is 0")
+	scene.addHero(hero, req.DestPos)
+	if !req.CellMigrate {
+		scene.sendEnterScene(hero)
 	}
-	scene := manager.scenes[req.SceneId]
-	if scene == nil {
-		logger.Errorf("scene:%d Hero:%d HeroLeaveScene err: scene not found", req.SceneId, req.HeroId)
-		return errors.New("scene not found")
-	}
-	v, ok := scene.heros.Load(req.HeroId)
-	if !ok {
-		logger.Errorf("scene:%d Hero:%d HeroLeaveScene err: hero not found", req.SceneId, req.HeroId)
-		return errors.New("hero not found")
+	logger.Debugf("hero:%d_%s 进入场景:%d", hero.GetID(), hero._name, req.SceneId)
+	if req.TransferId > 0 {
+		notifyTransferDone(s, hero, req.SceneId, req.TransferId)
 	}
-	hero := v.(*Hero)
-	logger.Debugf("hero:%d_%s 离开场景:%d", hero.GetID(), hero._name, req.SceneId)
-	hero.DestroyWithoutSession()
 	return nil
 }
 
@@ -131,7 +262,86 @@ func (manager *SceneManager) HeroSetViewRange(s *session.Session, req *protocol.
 }
 
 func (manager *SceneManager) Attack(s *session.Session, req *protocol.AttackRequest) error {
-	return nil
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	if req.AttackerId != 0 && req.AttackerId != p.GetID() {
+		return ErrAttackInvalidTarget
+	}
+	return p.AttackTarget(req.TargetId, req.TargetType)
+}
+
+func (manager *SceneManager) ReleaseSpell(s *session.Session, req *protocol.HeroReleaseSpellRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.ReleaseSpell(req.SpellId, req.TargetId, req.TargetType)
+}
+
+func (manager *SceneManager) HeroRevive(s *session.Session, req *protocol.HeroReviveRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.Revive(req.Mode)
+}
+
+func (manager *SceneManager) PickupItem(s *session.Session, req *protocol.PickupItemRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.PickupItem(req.ID)
+}
+
+func (manager *SceneManager) ListItems(s *session.Session, req *protocol.EmptyRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.ListItems()
+}
+
+func (manager *SceneManager) MoveItem(s *session.Session, req *protocol.MoveItemRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.MoveItem(req.From, req.To)
+}
+
+func (manager *SceneManager) UseItem(s *session.Session, req *protocol.UseItemRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.UseItem(req.Slot)
+}
+
+func (manager *SceneManager) DropItem(s *session.Session, req *protocol.DropItemRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.DropItem(req.Slot, req.Count)
+}
+
+func (manager *SceneManager) EquipItem(s *session.Session, req *protocol.EquipItemRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.EquipItem(req.Slot)
+}
+
+func (manager *SceneManager) UnequipItem(s *session.Session, req *protocol.UnequipItemRequest) error {
+	p, err := heroWithSession(s)
+	if err != nil {
+		return err
+	}
+	return p.UnequipItem(req.Pos)
 }
 
 func (manager *SceneManager) HeroMove(s *session.Session, req *protocol.HeroMoveRequest) error {
@@ -139,6 +349,9 @@ func (manager *SceneManager) HeroMove(s *session.Session, req *protocol.HeroMove
 	if err != nil {
 		return err
 	}
+	if len(req.TracePaths) == 0 || len(req.TracePaths[len(req.TracePaths)-1]) < 2 {
+		return ErrMoveInvalidStep
+	}
 	lastPoint := req.TracePaths[len(req.TracePaths)-1]
 	targetX := lastPoint[1]
 	targety := lastPoint[0]
@@ -167,50 +380,49 @@ func (manager *SceneManager) SceneInfo(s *session.Session, req *protocol.SceneIn
 	return s.RPC("Manager.SceneInfoCallBack", &protocol.SceneInfoResponse{Scenes: items})
 }
 
-// 玩家文字消息
+// 玩家文字消息, 兼容旧的客户端, 转给master按附近频道处理
 func (manager *SceneManager) TextMessage(s *session.Session, msg *protocol.TextMessageRequest) error {
+	return s.RPC("Manager.ChatSend", &protocol.ChatSendRequest{
+		Channel: constants.CHAT_CHANNEL_NEARBY,
+		Msg:     msg.Msg,
+	})
+}
+
+// master检查过的附近和场景频道的消息, 文字和语音
+func (manager *SceneManager) ChatBroadcast(s *session.Session, req *protocol.ChatBroadcastRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) || (req.Message == nil && req.Voice == nil) {
+		logger.Warningf("hero:%d 聊天签名错误", req.HeroId)
+		return errutil.ErrPermissionDenied
+	}
 	p, err := heroWithSession(s)
 	if err != nil {
 		return err
 	}
+	if p.GetID() != req.HeroId {
+		return errutil.ErrHeroNotFound
+	}
+	route, channel, msg := protocol.OnChat, 0, interface{}(req.Message)
+	if req.Message != nil {
+		channel = req.Message.Channel
+	} else {
+		route, channel, msg = protocol.OnRecordingVoice, req.Voice.Channel, req.Voice
+	}
 	p.PushTask(func() {
-		p.Broadcast(protocol.OnTextMessage, msg, false)
+		switch channel {
+		case constants.CHAT_CHANNEL_NEARBY:
+			p.Broadcast(route, msg, true)
+		case constants.CHAT_CHANNEL_SCENE:
+			if p.scene != nil {
+				p.scene.broadcastAll(route, msg)
+			}
+		}
 	})
 	return nil
 }
 
-// 玩家语音消息
-func (manager *SceneManager) VoiceMessage(s *session.Session, msg []byte) error {
-	//p, err := heroWithSession(s)
-	//if err != nil {
-	//	return err
-	//}
-
-	//d := p.scene
-	//if d != nil && d.group != nil {
-	//	return d.group.Broadcast("onVoiceMessage", msg)
-	//}
-
-	return nil
-}
-
-// 玩家录制完语音
+// 玩家录制完语音, 文件已经通过web服务上传, 由master检查后按频道转发
 func (manager *SceneManager) RecordingVoice(s *session.Session, msg *protocol.RecordingVoice) error {
-	//p, err := heroWithSession(s)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//d := p.scene
-	//resp := &protocol.PlayRecordingVoice{
-	//	Uid:    s.UID(),
-	//	FileId: msg.FileId,
-	//}
-	//
-	//if d != nil && d.group != nil {
-	//	return d.group.Broadcast("onRecordingVoice", resp)
-	//}
-	return nil
+	return s.RPC("Manager.VoiceSend", msg)
 }
 
 // 动态重置怪物
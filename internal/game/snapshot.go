@@ -0,0 +1,353 @@
+package game
+
+// 场景快照, game服务器重启后通过快照恢复场景内的hero, monster, 复活队列和buffer
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/pkg/path"
+	"github.com/nano/gameserver/pkg/shape"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 快照格式版本号, 结构有不兼容的修改时需要+1, 旧版本的快照会被丢弃
+	SNAPSHOT_VERSION = 1
+
+	SNAPSHOT_DEFAULT_DIR = "snapshots"
+	// 默认每60秒保存一次快照
+	SNAPSHOT_DEFAULT_INTERVAL = 60
+	// 快照内的hero数据默认5分钟内重连有效
+	SNAPSHOT_DEFAULT_HERO_EXPIRE = 300
+	// 等待场景携程采集数据的超时时间
+	SNAPSHOT_COLLECT_TIMEOUT = 2 * time.Second
+)
+
+var (
+	ErrSnapshotVersion = errors.New("snapshot version mismatch")
+	ErrSnapshotScene   = errors.New("snapshot scene mismatch")
+)
+
+type sceneSnapshot struct {
+	Version        int                      `json:"version"`
+	SceneId        int                      `json:"scene_id"`
+	Timestamp      int64                    `json:"timestamp"`
+	Heros          []*heroSnapshot          `json:"heros"`
+	Monsters       []*monsterSnapshot       `json:"monsters"`
+	RebornMonsters []*rebornMonsterSnapshot `json:"reborn_monsters"`
+}
+
+// hero只保存运行时数据，持久化的属性以master下发的model.Hero为准
+type heroSnapshot struct {
+	Id      int64                  `json:"id"`
+	Uid     int64                  `json:"uid"`
+	Pos     coord.Vector3          `json:"pos"`
+	Life    int64                  `json:"life"`
+	Mana    int64                  `json:"mana"`
+	Buffers []*object.BufferObject `json:"buffers"`
+}
+
+type monsterSnapshot struct {
+	Id           int64                    `json:"id"`
+	Cfg          model.SceneMonsterConfig `json:"cfg"`
+	Pos          coord.Vector3            `json:"pos"`
+	BornPos      coord.Vector3            `json:"born_pos"`
+	MovableRect  shape.Rect               `json:"movable_rect"`
+	PreparePaths *path.SerialPaths        `json:"prepare_paths"`
+	Life         int64                    `json:"life"`
+	Mana         int64                    `json:"mana"`
+	SpellCdTimes []int                    `json:"spell_cd_times"`
+	Buffers      []*object.BufferObject   `json:"buffers"`
+	Ai           *monsterAiSnapshot       `json:"ai"`
+}
+
+type monsterAiSnapshot struct {
+	BehaviorState constants.BEHAVIOR `json:"behavior_state"`
+	OriginX       coord.Coord        `json:"origin_x"`
+	OriginY       coord.Coord        `json:"origin_y"`
+	PreparePathId int                `json:"prepare_path_id"`
+}
+
+type rebornMonsterSnapshot struct {
+	Cfg             model.SceneMonsterConfig `json:"cfg"`
+	PreparePaths    *path.SerialPaths        `json:"prepare_paths"`
+	MovableRect     shape.Rect               `json:"movable_rect"`
+	RebornTimestamp int64                    `json:"reborn_timestamp"`
+}
+
+func newSceneSnapshot(sceneId int) *sceneSnapshot {
+	return &sceneSnapshot{
+		Version:        SNAPSHOT_VERSION,
+		SceneId:        sceneId,
+		Timestamp:      time.Now().UnixMilli(),
+		Heros:          make([]*heroSnapshot, 0),
+		Monsters:       make([]*monsterSnapshot, 0),
+		RebornMonsters: make([]*rebornMonsterSnapshot, 0),
+	}
+}
+
+func encodeSnapshot(snap *sceneSnapshot) ([]byte, error) {
+	return json.Marshal(snap)
+}
+
+func decodeSnapshot(buf []byte, sceneId int) (*sceneSnapshot, error) {
+	snap := &sceneSnapshot{}
+	if err := json.Unmarshal(buf, snap); err != nil {
+		return nil, err
+	}
+	if snap.Version != SNAPSHOT_VERSION {
+		return nil, ErrSnapshotVersion
+	}
+	if snap.SceneId != sceneId {
+		return nil, ErrSnapshotScene
+	}
+	return snap, nil
+}
+
+// 第1条线沿用原来的文件名
+func snapshotPath(sceneId int, lineId int) string {
+	dir := viper.GetString("game-server.snapshot_dir")
+	if dir == "" {
+		dir = SNAPSHOT_DEFAULT_DIR
+	}
+	if lineId > 1 {
+		return filepath.Join(dir, fmt.Sprintf("scene_%d_%d.snapshot", sceneId, lineId))
+	}
+	return filepath.Join(dir, fmt.Sprintf("scene_%d.snapshot", sceneId))
+}
+
+// 先写临时文件再rename，防止写到一半进程退出导致快照损坏
+func writeSnapshotFile(pth string, snap *sceneSnapshot) error {
+	buf, err := encodeSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		return err
+	}
+	tmp := pth + ".tmp"
+	if err = os.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, pth)
+}
+
+func readSnapshotFile(pth string, sceneId int) (*sceneSnapshot, error) {
+	buf, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(buf, sceneId)
+}
+
+// 损坏的快照改名保留下来方便排查，同时不会在下次启动时再次读取
+func discardSnapshotFile(pth string) {
+	bak := fmt.Sprintf("%s.corrupt.%d", pth, time.Now().Unix())
+	if err := os.Rename(pth, bak); err != nil {
+		logger.Errorf("discard snapshot:%s err:%v", pth, err)
+	}
+}
+
+func snapshotHeroExpire() int64 {
+	expire := viper.GetInt64("game-server.snapshot_hero_expire")
+	if expire <= 0 {
+		expire = SNAPSHOT_DEFAULT_HERO_EXPIRE
+	}
+	return expire * 1000
+}
+
+func snapshotBuffers(buffers map[int]*Buffer) []*object.BufferObject {
+	result := make([]*object.BufferObject, 0, len(buffers))
+	for _, buf := range buffers {
+		o := *buf.BufferObject
+		result = append(result, &o)
+	}
+	return result
+}
+
+// 通过PushTask在场景携程内采集数据，避免与update并发读写buffers等数据
+func (s *Scene) takeSnapshot() *sceneSnapshot {
+	snap := newSceneSnapshot(s.sceneId)
+	entities := make([]IMovableEntity, 0)
+	s.heros.Range(func(key, value any) bool {
+		entities = append(entities, value.(*Hero))
+		return true
+	})
+	s.monsters.Range(func(key, value any) bool {
+		entities = append(entities, value.(*Monster))
+		return true
+	})
+	// 有缓冲，超时后才返回的数据不会阻塞场景携程
+	results := make(chan interface{}, len(entities))
+	for _, e := range entities {
+		switch val := e.(type) {
+		case *Hero:
+			val.PushTask(func() {
+				results <- val.snapshot()
+			})
+		case *Monster:
+			val.PushTask(func() {
+				results <- val.snapshot()
+			})
+		}
+	}
+	timeout := time.After(SNAPSHOT_COLLECT_TIMEOUT)
+collect:
+	for i := 0; i < len(entities); i++ {
+		select {
+		case r := <-results:
+			switch val := r.(type) {
+			case *heroSnapshot:
+				snap.Heros = append(snap.Heros, val)
+			case *monsterSnapshot:
+				if val != nil {
+					snap.Monsters = append(snap.Monsters, val)
+				}
+			}
+		case <-timeout:
+			logger.Warningf("scene:%d 快照采集超时, 已采集:%d/%d", s.sceneId, i, len(entities))
+			break collect
+		}
+	}
+	s.rebornMonsters.Range(func(key, value any) bool {
+		rm := value.(*rebornMonster)
+		snap.RebornMonsters = append(snap.RebornMonsters, &rebornMonsterSnapshot{
+			Cfg:             *rm.Cfg,
+			PreparePaths:    rm.PreparePaths,
+			MovableRect:     rm.MovableRect,
+			RebornTimestamp: rm.RebornTimestamp,
+		})
+		return true
+	})
+	return snap
+}
+
+func (s *Scene) saveSnapshot() error {
+	snap := s.takeSnapshot()
+	err := writeSnapshotFile(snapshotPath(s.sceneId, s.lineId), snap)
+	if err == nil {
+		logger.Debugf("scene:%d 保存快照 hero:%d, monster:%d, reborn:%d", s.sceneId,
+			len(snap.Heros), len(snap.Monsters), len(snap.RebornMonsters))
+	}
+	return err
+}
+
+// 载入快照, 返回false时需要按配置重新初始化monster
+func (s *Scene) loadSnapshot() bool {
+	pth := snapshotPath(s.sceneId, s.lineId)
+	snap, err := readSnapshotFile(pth, s.sceneId)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("scene:%d 快照:%s 无法载入, 按配置初始化场景: %v", s.sceneId, pth, err)
+			discardSnapshotFile(pth)
+		}
+		return false
+	}
+	// 过期的hero数据不再恢复，重连时使用数据库的数据
+	if time.Now().UnixMilli()-snap.Timestamp <= snapshotHeroExpire() {
+		for _, hs := range snap.Heros {
+			s.pendingHeros.Store(hs.Id, hs)
+		}
+	}
+	if len(snap.Monsters) == 0 && len(snap.RebornMonsters) == 0 && len(s.sceneData.MonsterConfigList) > 0 {
+		// 快照内没有任何monster，当作无效快照处理
+		logger.Warningf("scene:%d 快照内没有monster数据, 按配置初始化场景", s.sceneId)
+		return false
+	}
+
+	templates := make(map[string]*monsterTemplate)
+	getTemplate := func(cfg model.SceneMonsterConfig) (*monsterTemplate, error) {
+		key := fmt.Sprintf("%d_%d_%d_%d", cfg.MonsterId, cfg.Bornx, cfg.Borny, cfg.ARange)
+		if tpl, ok := templates[key]; ok {
+			return tpl, nil
+		}
+		tpl, err := s.loadMonsterTemplate(cfg)
+		if err != nil {
+			return nil, err
+		}
+		templates[key] = tpl
+		return tpl, nil
+	}
+	for _, ms := range snap.Monsters {
+		tpl, err := getTemplate(ms.Cfg)
+		if err != nil {
+			logger.Errorf("scene:%d 快照恢复monster:%d err:%v", s.sceneId, ms.Id, err)
+			continue
+		}
+		s.restoreMonster(ms, tpl)
+	}
+	for _, rs := range snap.RebornMonsters {
+		tpl, err := getTemplate(rs.Cfg)
+		if err != nil {
+			logger.Errorf("scene:%d 快照恢复复活monster:%d err:%v", s.sceneId, rs.Cfg.MonsterId, err)
+			continue
+		}
+		s.restoreRebornMonster(rs, tpl)
+	}
+
+	logger.Infof("scene:%d 从快照恢复 hero:%d, monster:%d, reborn:%d", s.sceneId,
+		len(snap.Heros), s.totalMonsterCount(), len(snap.RebornMonsters))
+	return true
+}
+
+func (s *Scene) popPendingHero(heroId int64) *heroSnapshot {
+	v, ok := s.pendingHeros.LoadAndDelete(heroId)
+	if !ok {
+		return nil
+	}
+	return v.(*heroSnapshot)
+}
+
+func (s *Scene) restoreMonster(ms *monsterSnapshot, tpl *monsterTemplate) {
+	//使用新生成的id, monster的id按时间生成, 沿用重启前的id可能和新进程内复活的monster重复
+	m := NewMonster(tpl.data, 0)
+	m.SetSceneMonsterConfig(tpl.cfg)
+	m.SetMovableRect(ms.MovableRect)
+	if ms.PreparePaths != nil {
+		m.SetPreparePaths(ms.PreparePaths)
+	}
+	m.SetPos(ms.Pos.X, ms.Pos.Y, ms.Pos.Z)
+	m.bornPos.Copy(ms.BornPos)
+	m.SetSpells(tpl.spells)
+	for i, cd := range ms.SpellCdTimes {
+		if i < len(m.spells) {
+			m.spells[i].CurCdTime = cd
+		}
+	}
+	m.Life = min(ms.Life, m.MaxLife)
+	m.Mana = min(ms.Mana, m.MaxMana)
+	if tpl.aidata != nil {
+		ai := newMonsterAi(m, tpl.aidata)
+		if ms.Ai != nil {
+			ai.restoreSnapshot(ms.Ai)
+		}
+		m.SetAiData(ai)
+	}
+	m.restoreBuffers(m, ms.Buffers)
+	s.addMonster(m)
+}
+
+func (s *Scene) restoreRebornMonster(rs *rebornMonsterSnapshot, tpl *monsterTemplate) {
+	rm := &rebornMonster{
+		Uid:             uuid.New().String(),
+		Data:            tpl.data,
+		PreparePaths:    rs.PreparePaths,
+		MovableRect:     rs.MovableRect,
+		Cfg:             tpl.cfg,
+		Spells:          tpl.spells,
+		RebornTimestamp: rs.RebornTimestamp,
+	}
+	if tpl.aidata != nil {
+		rm.Aidata = tpl.aidata
+	}
+	s.addRebornMonster(rm)
+}
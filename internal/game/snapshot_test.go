@@ -0,0 +1,86 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotEncodeDecode(t *testing.T) {
+	snap := newSceneSnapshot(1)
+	snap.Heros = append(snap.Heros, &heroSnapshot{
+		Id:   10,
+		Uid:  100,
+		Pos:  coord.Vector3{X: 15, Y: 140},
+		Life: 50,
+		Mana: 20,
+		Buffers: []*object.BufferObject{
+			{BufferState: model.BufferState{Id: 3, EffectCnt: 2}, CurCnt: 1, ElapsedTime: 300},
+		},
+	})
+	snap.Monsters = append(snap.Monsters, &monsterSnapshot{
+		Id:           20,
+		Cfg:          model.SceneMonsterConfig{Id: 1, MonsterId: 2, Bornx: 100, Borny: 150, ARange: 50},
+		Pos:          coord.Vector3{X: 101, Y: 151},
+		Life:         7,
+		SpellCdTimes: []int{500},
+		Ai:           &monsterAiSnapshot{OriginX: 100, OriginY: 150},
+	})
+	buf, err := encodeSnapshot(snap)
+	assert.Nil(t, err)
+
+	snap2, err := decodeSnapshot(buf, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, snap.Timestamp, snap2.Timestamp)
+	assert.Equal(t, snap.Heros[0].Pos, snap2.Heros[0].Pos)
+	assert.Equal(t, int64(300), snap2.Heros[0].Buffers[0].ElapsedTime)
+	assert.Equal(t, snap.Monsters[0].Cfg.MonsterId, snap2.Monsters[0].Cfg.MonsterId)
+	assert.Equal(t, []int{500}, snap2.Monsters[0].SpellCdTimes)
+	assert.Equal(t, coord.Coord(150), snap2.Monsters[0].Ai.OriginY)
+
+	_, err = decodeSnapshot(buf, 2)
+	assert.Equal(t, ErrSnapshotScene, err)
+
+	snap.Version = SNAPSHOT_VERSION + 1
+	buf, _ = encodeSnapshot(snap)
+	_, err = decodeSnapshot(buf, 1)
+	assert.Equal(t, ErrSnapshotVersion, err)
+}
+
+func TestSnapshotFile(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "snap", "scene_1.snapshot")
+	_, err := readSnapshotFile(pth, 1)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, writeSnapshotFile(pth, newSceneSnapshot(1)))
+	snap, err := readSnapshotFile(pth, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, snap.SceneId)
+
+	//损坏的快照
+	assert.Nil(t, os.WriteFile(pth, []byte(`{"version":1,"scene_id":1,"heros":[`), 0644))
+	_, err = readSnapshotFile(pth, 1)
+	assert.NotNil(t, err)
+	discardSnapshotFile(pth)
+	_, err = readSnapshotFile(pth, 1)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestResidentScenes(t *testing.T) {
+	manager := NewSceneManager()
+	scene := &Scene{sceneId: 1}
+	line := &Scene{sceneId: 1, lineId: 2}
+	instance := &Scene{sceneId: 2, instanceId: 100}
+	manager.scenes[1] = scene
+	manager.lines[sceneLine{sceneId: 1, lineId: 2}] = line
+	manager.instances.Store(int64(100), instance)
+
+	// 下线时分线也要保存快照, 副本不保存
+	assert.ElementsMatch(t, []*Scene{scene, line}, manager.residentScenes())
+	assert.ElementsMatch(t, []*Scene{scene, line, instance}, manager.allScenes())
+}
@@ -24,14 +24,14 @@ func NewSpellEntity(spellObject *object.SpellObject, caster IMovableEntity) *Spe
 		caster:      caster,
 		SpellObject: spellObject,
 	}
+	//先生成id, 进入和离开视野推送的id和OnReleaseSpell的一致
+	e.GenId()
 	e.initEntity(int64(e.SpellObject.Id), "spell"+spellObject.Name, constants.ENTITY_TYPE_SPELL, 64)
 	e.GameObject.Uuid = e.GetUUID()
-	e.GenId()
 	e.CasterId = e.caster.GetID()
 	e.CasterType = e.caster.GetEntityType()
 	e.SetPos(e.caster.GetPos().X, e.caster.GetPos().Y, e.caster.GetPos().Z)
 	e.SetViewRange(e.caster.GetViewRange())
-	e.casterManaCost()
 	return e
 }
 
@@ -49,6 +49,20 @@ func (e *SpellEntity) onEnterScene(scene *Scene) {
 			SpellObject: e.SpellObject,
 		})
 	}
+	//收到OnReleaseSpell的hero和释放技能的hero已经创建了技能, 直接记录到视野内, 不再推送OnEnterView
+	for _, v := range e.caster.GetCanSeeMeViewList() {
+		if h, ok := v.(*Hero); ok {
+			e.seenBy(h)
+		}
+	}
+	if h, ok := e.caster.(*Hero); ok {
+		e.seenBy(h)
+	}
+}
+
+func (e *SpellEntity) seenBy(h *Hero) {
+	h.movableEntity.onEnterView(e)
+	e.onEnterOtherView(h)
 }
 
 func (e *SpellEntity) onExitScene(scene *Scene) {
@@ -56,10 +70,15 @@ func (e *SpellEntity) onExitScene(scene *Scene) {
 }
 
 func (e *SpellEntity) SetPos(x, y, z coord.Coord) {
+	oldx, oldy := e.GetPos().X, e.GetPos().Y
 	e.Posx = x
 	e.Posy = y
 	e.Posz = z
 	e.movableEntity.SetPos(x, y, z)
+	if e.scene != nil && (oldx != x || oldy != y) {
+		//更新aoi和视野, 后进入视野的hero可以看见飞行中的技能
+		e.scene.entityMoved(e, x, y, oldx, oldy)
+	}
 }
 
 // 需要在添加到场景内之前执行
@@ -74,8 +93,8 @@ func (e *SpellEntity) SetTarget(target IMovableEntity) {
 func (e *SpellEntity) SetTargetPos(target coord.Vector3) {
 	e.TargetPos.Copy(target)
 
-	// 计算两点距离 再算出需要移动的总时间
-	dist := int(shape.CalculateDistance(float64(e.caster.GetPos().X), float64(e.caster.GetPos().Y), float64(e.TargetPos.X), float64(e.TargetPos.Y)))
+	// 计算当前位置到目标的距离 再算出需要移动的总时间
+	dist := int(shape.CalculateDistance(float64(e.GetPos().X), float64(e.GetPos().Y), float64(e.TargetPos.X), float64(e.TargetPos.Y)))
 	//重新计算距离的时候需要把已行走的时间叠加起来
 	e.totalTime = e.elapsedTime + int64(dist*e.FlyStepTime)
 }
@@ -89,31 +108,18 @@ func (e *SpellEntity) update(curMilliSecond int64, elapsedTime int64) error {
 		e.SetTargetPos(e.target.GetPos())
 	}
 	err := e.movableEntity.update(curMilliSecond, elapsedTime)
+	e.fly(elapsedTime)
 	e.elapsedTime += elapsedTime
 	if e.elapsedTime >= e.totalTime {
-		//到达消失时间
-		var err error
+		//到达消失时间, 出错也要销毁, 否则技能会一直留在场景里
+		defer e.Destroy()
 		if e.Data.IsRangeAttack != 0 {
-			entityes := e.scene.getEntitiesByRange(e.TargetPos.X, e.TargetPos.Y, coord.Coord(e.Data.AttackRange))
-			if entityes != nil && len(entityes) > 0 {
-				for _, entity := range entityes {
-					if entity == e.caster {
-						continue
-					}
-					canAttack := false
-					switch val := e.caster.(type) {
-					case *Hero:
-						canAttack = val.CanAttackTarget(entity)
-					case *Monster:
-						canAttack = val.CanAttackTarget(entity)
-					}
-					if !canAttack {
-						continue
-					}
-					err = e.processTargetHurt(entity)
-					if err != nil {
-						return err
-					}
+			for _, entity := range e.scene.getEntitiesByRange(e.TargetPos.X, e.TargetPos.Y, coord.Coord(e.Data.AttackRange)) {
+				if !e.canAffect(entity) {
+					continue
+				}
+				if err := e.processTargetHurt(entity); err != nil {
+					return err
 				}
 			}
 		} else {
@@ -122,11 +128,55 @@ func (e *SpellEntity) update(curMilliSecond int64, elapsedTime int64) error {
 			}
 			return e.processTargetHurt(e.target)
 		}
-		e.Destroy()
 	}
 	return err
 }
 
+// 加血和增益技能作用于友方, 其它作用于可攻击的对象
+func (e *SpellEntity) isFriendly() bool {
+	return e.Data.Damage < 0 || e.SpellType == constants.SPELL_TYPE_ALLY || e.SpellType == constants.SPELL_TYPE_SELF
+}
+
+// 范围技能是否作用于对象
+func (e *SpellEntity) canAffect(target IMovableEntity) bool {
+	if e.isFriendly() {
+		switch e.caster.(type) {
+		case *Hero:
+			h, ok := target.(*Hero)
+			return ok && h.IsAlive() && !h.IsDestroyed()
+		case *Monster:
+			m, ok := target.(*Monster)
+			return ok && m.IsAlive() && !m.IsDestroyed()
+		}
+		return false
+	}
+	if target == e.caster {
+		return false
+	}
+	switch val := e.caster.(type) {
+	case *Hero:
+		return val.CanAttackTarget(target)
+	case *Monster:
+		return val.CanAttackTarget(target)
+	}
+	return false
+}
+
+// 按剩余的飞行时间向目标位置移动
+func (e *SpellEntity) fly(elapsedTime int64) {
+	remain := e.totalTime - e.elapsedTime
+	if remain <= 0 {
+		return
+	}
+	if elapsedTime > remain {
+		elapsedTime = remain
+	}
+	pos := e.GetPos()
+	x := pos.X + coord.Coord(int64(e.TargetPos.X-pos.X)*elapsedTime/remain)
+	y := pos.Y + coord.Coord(int64(e.TargetPos.Y-pos.Y)*elapsedTime/remain)
+	e.SetPos(x, y, pos.Z)
+}
+
 func (e *SpellEntity) processTargetHurt(target IMovableEntity) error {
 	if e.Data.Damage != 0 {
 		var damage int64 = 0
@@ -150,7 +200,11 @@ func (e *SpellEntity) processTargetHurt(target IMovableEntity) error {
 		case *Hero:
 			val.onBeenHurt(damage)
 		case *Monster:
-			val.onBeenHurt(damage)
+			val.onBeenHurtBy(e.caster, damage)
+			// 被hero的技能打了需要触发怪物的反击
+			if damage > 0 && e.caster != nil {
+				val.onBeenAttacked(e.caster)
+			}
 		}
 	}
 	return e.processBufferState(target)
@@ -168,22 +222,16 @@ func (e *SpellEntity) processBufferState(target IMovableEntity) error {
 	return nil
 }
 
-func (e *SpellEntity) casterManaCost() {
-	e.PushTask(func() {
-		switch val := e.caster.(type) {
-		case *Hero:
-			val.manaCost(e.Data.Mana)
-		case *Monster:
-			val.manaCost(e.Data.Mana)
-		}
-	})
-}
-
+// 命中或者到达目标位置后销毁, 通知能看见的hero删除
 func (e *SpellEntity) Destroy() {
 	e.caster = nil
 	e.target = nil
 	if e.scene != nil {
 		e.scene.removeSpell(e)
 	}
+	e.canSeeMeViewList.Range(func(key, value interface{}) bool {
+		value.(IMovableEntity).onExitView(e)
+		return true
+	})
 	e.movableEntity.Destroy()
 }
@@ -0,0 +1,58 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpellEntityFly(t *testing.T) {
+	caster := NewHero(nil, &model.Hero{Id: 1})
+	caster.SetPos(10, 10, 0)
+	viewer := NewHero(nil, &model.Hero{Id: 2})
+	e := NewSpellEntity(object.NewSpellObject(&model.Spell{Id: 1, FlyStepTime: 10}, nil), caster)
+	e.SetTargetPos(coord.Vector3{X: 30, Y: 10})
+	assert.Equal(t, int64(200), e.totalTime)
+
+	// 按飞行时间移动到目标位置
+	e.fly(100)
+	e.elapsedTime += 100
+	assert.Equal(t, coord.Coord(20), e.GetPos().X)
+	assert.Equal(t, coord.Coord(20), e.Posx)
+
+	viewer.onEnterView(e)
+	e.onEnterOtherView(viewer)
+	e.fly(150)
+	assert.Equal(t, coord.Coord(30), e.GetPos().X)
+
+	// 销毁后离开视野
+	e.Destroy()
+	assert.False(t, viewer.IsInViewList(e))
+}
+
+func TestSpellEntityCanAffect(t *testing.T) {
+	caster := NewHero(nil, &model.Hero{Id: 1, BaseLife: 100})
+	ally := NewHero(nil, &model.Hero{Id: 2, BaseLife: 100})
+	monster := NewMonster(&model.Monster{Id: 1, BaseLife: 100}, 1)
+
+	// 伤害技能只打可攻击的对象, 不打自己
+	attack := NewSpellEntity(object.NewSpellObject(&model.Spell{Id: 1, Damage: 10}, nil), caster)
+	assert.True(t, attack.canAffect(monster))
+	assert.False(t, attack.canAffect(ally))
+	assert.False(t, attack.canAffect(caster))
+
+	// 加血技能作用于友方, 包括自己
+	heal := NewSpellEntity(object.NewSpellObject(&model.Spell{Id: 2, Damage: -10}, nil), caster)
+	assert.True(t, heal.canAffect(ally))
+	assert.True(t, heal.canAffect(caster))
+	assert.False(t, heal.canAffect(monster))
+
+	// 增益技能按技能类型判断
+	buff := NewSpellEntity(object.NewSpellObject(&model.Spell{Id: 3, SpellType: constants.SPELL_TYPE_ALLY}, nil), caster)
+	assert.True(t, buff.canAffect(ally))
+	assert.False(t, buff.canAffect(monster))
+}
@@ -0,0 +1,205 @@
+package game
+
+// 事件驱动的视野: 场景按VIEW_GRID_SIZE划分成小格子, 记录每个格子内的对象和视野覆盖了这个格子的hero(watcher)
+// 对象跨格子移动时只需要比较新旧两个格子的watcher, hero的视野范围变化时只处理增加和减少的格子
+// 在格子内移动不会改变视野, 刷新的开销跟随变化的数量而不是同屏的数量
+// 视野按格子计算, 对象所在的格子在hero视野覆盖的格子内就能看见, 比视野范围最多大一个格子
+// 只在场景携程内访问
+import (
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/pkg/shape"
+)
+
+const (
+	// 视野格子的大小, 要比视野范围小很多
+	VIEW_GRID_SIZE = 10
+)
+
+type viewCell struct {
+	entities map[string]IMovableEntity
+	watchers map[string]*Hero
+}
+
+// 格子坐标的范围, 包含两端
+type cellRect struct {
+	x0, y0, x1, y1 int
+}
+
+func (r cellRect) contains(cx, cy int) bool {
+	return cx >= r.x0 && cx <= r.x1 && cy >= r.y0 && cy <= r.y1
+}
+
+type viewGrid struct {
+	cols, rows int
+	cells      []viewCell
+	entityCell map[string]int      // uuid -> 所在的格子
+	watching   map[string]cellRect // hero的uuid -> 视野覆盖的格子
+}
+
+func newViewGrid(width, height int) *viewGrid {
+	g := &viewGrid{
+		cols:       width/VIEW_GRID_SIZE + 1,
+		rows:       height/VIEW_GRID_SIZE + 1,
+		entityCell: map[string]int{},
+		watching:   map[string]cellRect{},
+	}
+	g.cells = make([]viewCell, g.cols*g.rows)
+	for i := range g.cells {
+		g.cells[i] = viewCell{
+			entities: map[string]IMovableEntity{},
+			watchers: map[string]*Hero{},
+		}
+	}
+	return g
+}
+
+func clampCell(v, n int) int {
+	if v < 0 {
+		return 0
+	}
+	if v >= n {
+		return n - 1
+	}
+	return v
+}
+
+func (g *viewGrid) cellOf(x, y coord.Coord) (int, int) {
+	return clampCell(int(x)/VIEW_GRID_SIZE, g.cols), clampCell(int(y)/VIEW_GRID_SIZE, g.rows)
+}
+
+func (g *viewGrid) cellIndex(x, y coord.Coord) int {
+	cx, cy := g.cellOf(x, y)
+	return cy*g.cols + cx
+}
+
+func (g *viewGrid) rectOf(r shape.Rect) cellRect {
+	return cellRect{
+		x0: clampCell(int(r.X)/VIEW_GRID_SIZE, g.cols),
+		y0: clampCell(int(r.Y)/VIEW_GRID_SIZE, g.rows),
+		x1: clampCell(int(r.X+r.Width)/VIEW_GRID_SIZE, g.cols),
+		y1: clampCell(int(r.Y+r.Height)/VIEW_GRID_SIZE, g.rows),
+	}
+}
+
+func (g *viewGrid) watches(r cellRect, index int) bool {
+	return r.contains(index%g.cols, index/g.cols)
+}
+
+// 对象进入或者移动, 返回开始看见和看不见它的hero, 没有跨格子时都为空
+func (g *viewGrid) move(e IMovableEntity) (entered, left []*Hero) {
+	uuid := e.GetUUID()
+	index := g.cellIndex(e.GetPos().X, e.GetPos().Y)
+	old, ok := g.entityCell[uuid]
+	if ok && old == index {
+		return nil, nil
+	}
+	if ok {
+		delete(g.cells[old].entities, uuid)
+	}
+	g.cells[index].entities[uuid] = e
+	g.entityCell[uuid] = index
+	for key, w := range g.cells[index].watchers {
+		if key != uuid && (!ok || !g.watches(g.watching[key], old)) {
+			entered = append(entered, w)
+		}
+	}
+	if ok {
+		for key, w := range g.cells[old].watchers {
+			if key != uuid && !g.watches(g.watching[key], index) {
+				left = append(left, w)
+			}
+		}
+	}
+	return entered, left
+}
+
+// hero的视野范围变化, 返回开始看见和看不见的对象
+func (g *viewGrid) watch(h *Hero) (entered, left []IMovableEntity) {
+	uuid := h.GetUUID()
+	r := g.rectOf(h.GetViewRect())
+	old, ok := g.watching[uuid]
+	if ok && old == r {
+		return nil, nil
+	}
+	g.watching[uuid] = r
+	for cy := r.y0; cy <= r.y1; cy++ {
+		for cx := r.x0; cx <= r.x1; cx++ {
+			cell := &g.cells[cy*g.cols+cx]
+			cell.watchers[uuid] = h
+			if ok && old.contains(cx, cy) {
+				continue
+			}
+			for key, e := range cell.entities {
+				if key != uuid {
+					entered = append(entered, e)
+				}
+			}
+		}
+	}
+	if !ok {
+		return entered, nil
+	}
+	for cy := old.y0; cy <= old.y1; cy++ {
+		for cx := old.x0; cx <= old.x1; cx++ {
+			if r.contains(cx, cy) {
+				continue
+			}
+			cell := &g.cells[cy*g.cols+cx]
+			delete(cell.watchers, uuid)
+			for key, e := range cell.entities {
+				if key != uuid {
+					left = append(left, e)
+				}
+			}
+		}
+	}
+	return entered, left
+}
+
+// 对象离开场景, 是hero时同时取消视野
+func (g *viewGrid) remove(e IMovableEntity) {
+	uuid := e.GetUUID()
+	if index, ok := g.entityCell[uuid]; ok {
+		delete(g.cells[index].entities, uuid)
+		delete(g.entityCell, uuid)
+	}
+	r, ok := g.watching[uuid]
+	if !ok {
+		return
+	}
+	for cy := r.y0; cy <= r.y1; cy++ {
+		for cx := r.x0; cx <= r.x1; cx++ {
+			delete(g.cells[cy*g.cols+cx].watchers, uuid)
+		}
+	}
+	delete(g.watching, uuid)
+}
+
+func (g *viewGrid) canSee(h *Hero, e IMovableEntity) bool {
+	r, ok := g.watching[h.GetUUID()]
+	if !ok {
+		return false
+	}
+	index, ok := g.entityCell[e.GetUUID()]
+	return ok && g.watches(r, index)
+}
+
+// hero视野覆盖的格子内的所有对象
+func (g *viewGrid) visibleEntities(h *Hero) []IMovableEntity {
+	uuid := h.GetUUID()
+	r, ok := g.watching[uuid]
+	if !ok {
+		return nil
+	}
+	result := make([]IMovableEntity, 0)
+	for cy := r.y0; cy <= r.y1; cy++ {
+		for cx := r.x0; cx <= r.x1; cx++ {
+			for key, e := range g.cells[cy*g.cols+cx].entities {
+				if key != uuid {
+					result = append(result, e)
+				}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/coord"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewGrid(t *testing.T) {
+	g := newViewGrid(100, 100)
+	h := NewHero(nil, &model.Hero{Id: 1})
+	h.SetPos(50, 50, 0)
+	a := NewHero(nil, &model.Hero{Id: 2})
+	a.SetPos(55, 55, 0)
+	b := NewHero(nil, &model.Hero{Id: 3})
+	b.SetPos(5, 5, 0)
+
+	g.move(h)
+	entered, _ := g.move(a)
+	assert.Empty(t, entered)
+	g.move(b)
+	seen, lost := g.watch(h)
+	assert.ElementsMatch(t, []IMovableEntity{a}, seen)
+	assert.Empty(t, lost)
+	assert.True(t, g.canSee(h, a))
+	assert.False(t, g.canSee(h, b))
+
+	// 在格子内移动没有变化
+	a.SetPos(57, 57, 0)
+	entered, left := g.move(a)
+	assert.Empty(t, entered)
+	assert.Empty(t, left)
+
+	a.SetPos(5, 55, 0)
+	entered, left = g.move(a)
+	assert.Empty(t, entered)
+	assert.Equal(t, []*Hero{h}, left)
+
+	// 视野范围变化后重新计算增加的格子
+	h.SetPos(10, 10, 0)
+	seen, lost = g.watch(h)
+	assert.ElementsMatch(t, []IMovableEntity{a, b}, seen)
+	assert.Empty(t, lost)
+	assert.ElementsMatch(t, []IMovableEntity{a, b}, g.visibleEntities(h))
+
+	g.remove(h)
+	entered, _ = g.move(b)
+	assert.Empty(t, entered)
+	b.SetPos(15, 15, 0)
+	entered, _ = g.move(b)
+	assert.Empty(t, entered)
+}
+
+const benchSceneWidth = 1000
+
+func benchmarkHeros(b *testing.B, n int) (*Scene, []*Hero) {
+	log.SetLevel(log.ErrorLevel)
+	viper.Set("game-server.view_max_entities", n)
+	b.Cleanup(func() {
+		log.SetLevel(log.InfoLevel)
+		viper.Set("game-server.view_max_entities", 0)
+	})
+	grids := benchSceneWidth/constants.SCENE_AOI_GRID_SIZE + 1
+	s := &Scene{
+		aoiMgr:   newAoiMgr(AOI_TYPE_GRID, grids*constants.SCENE_AOI_GRID_SIZE, grids),
+		viewGrid: newViewGrid(benchSceneWidth, benchSceneWidth),
+	}
+	r := rand.New(rand.NewSource(1))
+	heros := make([]*Hero, n)
+	for i := range heros {
+		h := NewHero(nil, &model.Hero{Id: int64(i + 1)})
+		h.SetPos(coord.Coord(r.Intn(benchSceneWidth)), coord.Coord(r.Intn(benchSceneWidth)), 0)
+		s.aoiMgr.Enter(h)
+		heros[i] = h
+	}
+	b.Cleanup(func() {
+		for _, h := range heros {
+			h.Entity.Destroy()
+			close(h.destroyCh)
+		}
+	})
+	return s, heros
+}
+
+// 每次随机移动10%的hero并刷新视野
+func benchmarkViewRefresh(b *testing.B, n int, refresh func(s *Scene, h *Hero)) {
+	s, heros := benchmarkHeros(b, n)
+	for _, h := range heros {
+		refresh(s, h)
+	}
+	r := rand.New(rand.NewSource(2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < n/10; j++ {
+			h := heros[r.Intn(n)]
+			oldX, oldY := h.GetPos().X, h.GetPos().Y
+			x := clampCoord(oldX + coord.Coord(r.Intn(11)-5))
+			y := clampCoord(oldY + coord.Coord(r.Intn(11)-5))
+			h.SetPos(x, y, 0)
+			s.aoiMgr.Moved(h, x, y, oldX, oldY)
+			refresh(s, h)
+		}
+	}
+}
+
+func clampCoord(v coord.Coord) coord.Coord {
+	if v < 0 {
+		return 0
+	}
+	if v >= benchSceneWidth {
+		return benchSceneWidth - 1
+	}
+	return v
+}
+
+// 改为事件驱动之前的刷新方式: 遍历视野列表检查离开, 再遍历九宫格检查进入
+func rescanEntityViewList(s *Scene, entity IMovableEntity) {
+	em := &entity.(*Hero).movableEntity
+	em.viewList.Range(func(key, value interface{}) bool {
+		target := value.(IMovableEntity)
+		if !entity.CanSee(target) {
+			entity.onExitView(target)
+			target.onExitOtherView(entity)
+		}
+		return true
+	})
+	em.canSeeMeViewList.Range(func(key, value interface{}) bool {
+		target := value.(IMovableEntity)
+		if !target.CanSee(em) {
+			target.onExitView(entity)
+			entity.onExitOtherView(target)
+		}
+		return true
+	})
+	others := make([]IMovableEntity, 0)
+	for _, e0 := range s.aoiMgr.Search(entity.GetPos().X, entity.GetPos().Y) {
+		e := e0.(IMovableEntity)
+		if e == entity {
+			continue
+		}
+		if entity.CanSee(e) {
+			others = append(others, e)
+		}
+		if h := e.(*Hero); h.CanSee(entity) && !h.IsInViewList(entity) {
+			s.admitHeroView(h, entity)
+		}
+	}
+	s.refreshHeroView(entity.(*Hero), others)
+}
+
+func BenchmarkViewRefresh(b *testing.B) {
+	for _, n := range []int{1000, 5000, 10000} {
+		b.Run(fmt.Sprintf("rescan-%d", n), func(b *testing.B) {
+			benchmarkViewRefresh(b, n, func(s *Scene, h *Hero) {
+				rescanEntityViewList(s, h)
+			})
+		})
+		b.Run(fmt.Sprintf("event-%d", n), func(b *testing.B) {
+			benchmarkViewRefresh(b, n, func(s *Scene, h *Hero) {
+				s.updateEntityView(h)
+			})
+		})
+	}
+}
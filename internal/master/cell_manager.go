@@ -0,0 +1,169 @@
+package master
+
+// 大地图的cell划分, 流程见protocol/cell.go
+// game节点定时把心跳写入scene_cell表, master按节点加入的顺序把场景宽度平均分给存活的节点
+// 有节点加入或者心跳超时后重新划分, game节点读取新的划分后迁移不再属于自己的hero和monster
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lonng/nano/component"
+	"github.com/lonng/nano/scheduler"
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 检查cell划分的间隔
+	CELL_CHECK_INTERVAL = 2 * time.Second
+	// 超过这个时间没有心跳的节点移出划分(毫秒)
+	CELL_NODE_TIMEOUT = 10000
+)
+
+type CellManager struct {
+	component.Base
+	//路由时会在其他携程读取, 需要加锁
+	mu    sync.RWMutex
+	cells map[int][]model.SceneCell //sceneId -> 按cell_index排序的cell
+}
+
+var defaultCellManager = NewCellManager()
+
+func NewCellManager() *CellManager {
+	return &CellManager{
+		cells: map[int][]model.SceneCell{},
+	}
+}
+
+func (c *CellManager) AfterInit() {
+	scheduler.NewTimer(CELL_CHECK_INTERVAL, func() {
+		if err := c.check(time.Now().UnixMilli()); err != nil {
+			logger.Errorf("检查场景cell失败: %v", err)
+		}
+	})
+}
+
+// 移除心跳超时的节点, 节点有变化时重新划分
+func (c *CellManager) check(now int64) error {
+	list, err := db.SceneCellList()
+	if err != nil {
+		return err
+	}
+	groups := make(map[int][]model.SceneCell)
+	for _, cell := range list {
+		if now-cell.Heartbeat > CELL_NODE_TIMEOUT {
+			logger.Warningf("场景:%d cell节点:%s 心跳超时, 移出划分", cell.SceneId, cell.ServiceAddr)
+			if err := db.DeleteSceneCell(cell.Id); err != nil {
+				logger.Errorf("删除场景:%d cell节点:%s 失败: %v", cell.SceneId, cell.ServiceAddr, err)
+			}
+			continue
+		}
+		groups[cell.SceneId] = append(groups[cell.SceneId], cell)
+	}
+	c.mu.RLock()
+	old := c.cells
+	c.mu.RUnlock()
+	for sceneId, cells := range groups {
+		if !splitCells(cells) {
+			continue
+		}
+		if err := db.UpdateSceneCellLayout(cells); err != nil {
+			// 保存失败时继续使用原来的划分, 下次再重试
+			logger.Errorf("场景:%d 保存cell划分失败: %v", sceneId, err)
+			if prev, ok := old[sceneId]; ok {
+				groups[sceneId] = prev
+			} else {
+				delete(groups, sceneId)
+			}
+			continue
+		}
+		logger.Infof("场景:%d 重新划分cell, 版本:%d, 节点数:%d", sceneId, cells[0].Version, len(cells))
+	}
+	c.mu.Lock()
+	c.cells = groups
+	c.mu.Unlock()
+	return nil
+}
+
+// 按节点加入的顺序平均划分场景宽度, 划分有变化时版本加1
+// cells需要按id排序
+func splitCells(cells []model.SceneCell) bool {
+	n := len(cells)
+	if n == 0 {
+		return false
+	}
+	width, version := 0, 0
+	for _, cell := range cells {
+		width = max(width, cell.Width)
+		version = max(version, cell.Version)
+	}
+	changed := false
+	for i := range cells {
+		minX, maxX := i*width/n, (i+1)*width/n
+		cell := &cells[i]
+		if cell.CellIndex != i || cell.MinX != minX || cell.MaxX != maxX || cell.Version != version {
+			changed = true
+		}
+		cell.CellIndex, cell.MinX, cell.MaxX = i, minX, maxX
+	}
+	if changed {
+		for i := range cells {
+			cells[i].Version = version + 1
+		}
+	}
+	return changed
+}
+
+// 负责这个位置的节点, 场景没有划分cell时返回空
+func (c *CellManager) cellAddrAt(sceneId int, x int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cells := c.cells[sceneId]
+	if len(cells) == 0 {
+		return ""
+	}
+	for _, cell := range cells {
+		if x < cell.MaxX {
+			return cell.ServiceAddr
+		}
+	}
+	//超出宽度的算在最后一个cell
+	return cells[len(cells)-1].ServiceAddr
+}
+
+// 大地图按位置选择负责的节点, 其他场景按场景id路由
+func bindCell(s *session.Session, sceneId int, x int) {
+	if addr := defaultCellManager.cellAddrAt(sceneId, x); addr != "" {
+		s.Set("cellAddr", addr)
+	} else {
+		s.Remove("cellAddr")
+	}
+}
+
+// game节点检测到hero走出了自己的cell, 迁移到负责新位置的节点
+func (m *Manager) HeroCellMigrate(s *session.Session, req *protocol.HeroCellMigrateRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("玩家: %d迁移cell签名错误: %+v", req.Uid, req)
+		return errutil.ErrPermissionDenied
+	}
+	user, ok := m.player(req.Uid)
+	if !ok || user.heroData == nil || user.heroData.Id != req.HeroId {
+		return fmt.Errorf("玩家: %d不在线", req.Uid)
+	}
+	if user.heroData.SceneId != req.SceneId {
+		return errors.New("不在迁移的场景")
+	}
+	addr := defaultCellManager.cellAddrAt(req.SceneId, int(req.PosX))
+	if addr == "" || addr == s.String("cellAddr") {
+		// 划分还没有同步到game节点, 等待game节点重试
+		return errors.New("目标cell不存在")
+	}
+	logger.Infof("玩家: %d迁移cell, 场景:%d, 位置:%d,%d, %s -> %s", req.Uid, req.SceneId, req.PosX, req.PosY, s.String("cellAddr"), addr)
+	return m.startTransfer(s, user, req.SceneId, user.lineId, 0, nil, true)
+}
@@ -0,0 +1,35 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCells(t *testing.T) {
+	cells := []model.SceneCell{
+		{Id: 1, ServiceAddr: ":1", CellIndex: -1, Width: 100},
+	}
+	assert.True(t, splitCells(cells))
+	assert.Equal(t, 0, cells[0].CellIndex)
+	assert.Equal(t, 0, cells[0].MinX)
+	assert.Equal(t, 100, cells[0].MaxX)
+	assert.Equal(t, 1, cells[0].Version)
+	// 没有变化时不重新划分
+	assert.False(t, splitCells(cells))
+
+	// 新节点加入后平均划分
+	cells = append(cells, model.SceneCell{Id: 2, ServiceAddr: ":2", CellIndex: -1, Width: 100})
+	assert.True(t, splitCells(cells))
+	assert.Equal(t, []int{0, 50, 50, 100}, []int{cells[0].MinX, cells[0].MaxX, cells[1].MinX, cells[1].MaxX})
+	assert.Equal(t, 2, cells[0].Version)
+	assert.Equal(t, 2, cells[1].Version)
+
+	m := NewCellManager()
+	m.cells[1] = cells
+	assert.Equal(t, ":1", m.cellAddrAt(1, 10))
+	assert.Equal(t, ":2", m.cellAddrAt(1, 50))
+	assert.Equal(t, ":2", m.cellAddrAt(1, 200))
+	assert.Equal(t, "", m.cellAddrAt(2, 10))
+}
@@ -0,0 +1,334 @@
+package master
+
+// 聊天, 流程见protocol/chat.go
+// 禁言由GM后台写入数据库, master定时加载; 屏蔽词读取配置chat.banned_words
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 每个频道保留的最近消息数量
+	CHAT_HISTORY_SIZE = 50
+	// 重新加载禁言的间隔
+	CHAT_MUTE_RELOAD = 10 * time.Second
+	// 频率限制的统计时间(毫秒)
+	CHAT_LIMIT_WINDOW = 60 * 1000
+	// 清理过期发送记录的间隔, 下线不清理, 避免重新登录绕过限制
+	CHAT_LIMITER_EXPIRE = time.Minute
+)
+
+var (
+	errChatCooldown = errors.New("发言太快了")
+	errChatLimited  = errors.New("发言次数太多, 请稍后再试")
+)
+
+type chatRule struct {
+	maxLen   int   //消息的最大长度
+	cooldown int64 //两条消息的最小间隔(毫秒)
+	limit    int   //CHAT_LIMIT_WINDOW内最多发送的条数
+}
+
+var chatRules = map[int]chatRule{
+	constants.CHAT_CHANNEL_NEARBY:  {maxLen: 100, cooldown: 1000, limit: 20},
+	constants.CHAT_CHANNEL_SCENE:   {maxLen: 100, cooldown: 3000, limit: 10},
+	constants.CHAT_CHANNEL_WORLD:   {maxLen: 100, cooldown: 15000, limit: 4},
+	constants.CHAT_CHANNEL_PARTY:   {maxLen: 200, cooldown: 500, limit: 30},
+	constants.CHAT_CHANNEL_GUILD:   {maxLen: 200, cooldown: 1000, limit: 20},
+	constants.CHAT_CHANNEL_PRIVATE: {maxLen: 200, cooldown: 500, limit: 30},
+}
+
+// 一个玩家在一个频道最近的发送时间
+type chatLimiter struct {
+	sendAt []int64
+}
+
+func (l *chatLimiter) allow(rule chatRule, now int64) error {
+	if n := len(l.sendAt); n > 0 && now-l.sendAt[n-1] < rule.cooldown {
+		return errChatCooldown
+	}
+	i := 0
+	for i < len(l.sendAt) && now-l.sendAt[i] >= CHAT_LIMIT_WINDOW {
+		i++
+	}
+	l.sendAt = l.sendAt[i:]
+	if len(l.sendAt) >= rule.limit {
+		return errChatLimited
+	}
+	l.sendAt = append(l.sendAt, now)
+	return nil
+}
+
+// 历史消息的频道, 私聊的targetId是玩家自己的uid
+type chatKey struct {
+	channel  int
+	targetId int64
+}
+
+func (m *Manager) reloadChatMutes() {
+	mutes, err := db.ActiveChatMutes(time.Now().UnixMilli())
+	if err != nil {
+		logger.Errorf("加载禁言失败: %v", err)
+		return
+	}
+	m.chatMutes = mutes
+}
+
+func (m *Manager) reloadChatFilter() {
+	m.chatFilter.Reset(viper.GetStringSlice("chat.banned_words"))
+}
+
+// 删除统计时间内没有发送过的记录
+func (m *Manager) expireChatLimiters(now int64) {
+	for uid, limiters := range m.chatLimiters {
+		for channel, l := range limiters {
+			if n := len(l.sendAt); n == 0 || now-l.sendAt[n-1] >= CHAT_LIMIT_WINDOW {
+				delete(limiters, channel)
+			}
+		}
+		if len(limiters) == 0 {
+			delete(m.chatLimiters, uid)
+		}
+	}
+}
+
+func (m *Manager) chatLimiterOf(uid int64, channel int) *chatLimiter {
+	limiters := m.chatLimiters[uid]
+	if limiters == nil {
+		limiters = map[int]*chatLimiter{}
+		m.chatLimiters[uid] = limiters
+	}
+	l := limiters[channel]
+	if l == nil {
+		l = &chatLimiter{}
+		limiters[channel] = l
+	}
+	return l
+}
+
+// 频道内的消息发送给谁, 场景频道在副本内时是副本id
+func (m *Manager) chatTargetId(user *User, channel int, targetUid int64) (int64, error) {
+	switch channel {
+	case constants.CHAT_CHANNEL_SCENE:
+		if user.instanceId > 0 {
+			return user.instanceId, nil
+		}
+		return int64(user.heroData.SceneId), nil
+	case constants.CHAT_CHANNEL_PARTY:
+		p := m.partyOf(user.Uid)
+		if p == nil {
+			return 0, errNotInParty
+		}
+		return p.id, nil
+	case constants.CHAT_CHANNEL_GUILD:
+		if user.guild == nil {
+			return 0, errNotInGuild
+		}
+		return user.guild.GuildId, nil
+	case constants.CHAT_CHANNEL_PRIVATE:
+		if targetUid == user.Uid {
+			return 0, errors.New("不能和自己私聊")
+		}
+		if _, ok := m.player(targetUid); !ok {
+			if _, err := db.QueryUser(targetUid); err != nil {
+				return 0, fmt.Errorf("玩家: %d不存在", targetUid)
+			}
+		}
+		if m.isBlocked(targetUid, user.Uid) {
+			return 0, errors.New("对方拒绝接收你的消息")
+		}
+		return targetUid, nil
+	}
+	return 0, nil
+}
+
+// 文字和语音共用的检查: 在线、频道、禁言
+func (m *Manager) chatUser(s *session.Session, channel int) (*User, chatRule, error) {
+	user, ok := m.onlineUser(s.UID())
+	if !ok {
+		return nil, chatRule{}, fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	rule, ok := chatRules[channel]
+	if !ok {
+		return nil, chatRule{}, errors.New("频道不存在")
+	}
+	if until := m.chatMutes[user.Uid]; time.Now().UnixMilli() < until {
+		return nil, chatRule{}, fmt.Errorf("禁言中, 截止: %s", time.UnixMilli(until).Format("2006-01-02 15:04:05"))
+	}
+	return user, rule, nil
+}
+
+func (m *Manager) ChatSend(s *session.Session, req *protocol.ChatSendRequest) error {
+	user, rule, err := m.chatUser(s, req.Channel)
+	if err != nil {
+		return err
+	}
+	if req.Msg == "" || utf8.RuneCountInString(req.Msg) > rule.maxLen {
+		return errors.New("消息长度错误")
+	}
+	targetId, err := m.chatTargetId(user, req.Channel, req.TargetUid)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	if err := m.chatLimiterOf(user.Uid, req.Channel).allow(rule, now); err != nil {
+		return err
+	}
+	text, _ := m.chatFilter.Replace(req.Msg)
+	msg := &protocol.ChatMessage{
+		Channel:    req.Channel,
+		TargetId:   targetId,
+		FromUid:    user.Uid,
+		FromHeroId: user.heroData.Id,
+		FromName:   user.heroData.Name,
+		Msg:        text,
+		SendAt:     now,
+	}
+	m.deliverChat(user, msg)
+	if req.Channel == constants.CHAT_CHANNEL_NEARBY {
+		return nil
+	}
+	if req.Channel == constants.CHAT_CHANNEL_PRIVATE {
+		m.appendChatHistory(chatKey{req.Channel, user.Uid}, *msg)
+		// 私聊记录只缓存在线玩家的, 对方登录时从数据库加载
+		if _, ok := m.player(targetId); ok {
+			m.appendChatHistory(chatKey{req.Channel, targetId}, *msg)
+		}
+	} else {
+		m.appendChatHistory(chatKey{req.Channel, targetId}, *msg)
+	}
+	record := &model.ChatMessage{
+		Channel:    msg.Channel,
+		TargetId:   msg.TargetId,
+		FromUid:    msg.FromUid,
+		FromHeroId: msg.FromHeroId,
+		FromName:   msg.FromName,
+		Msg:        msg.Msg,
+		SendAt:     msg.SendAt,
+	}
+	async.Run(func() {
+		if err := db.InsertChatMessage(record); err != nil {
+			logger.Errorf("玩家: %d保存聊天失败: %v", record.FromUid, err)
+		}
+	})
+	return nil
+}
+
+func (m *Manager) deliverChat(user *User, msg *protocol.ChatMessage) {
+	if msg.Channel == constants.CHAT_CHANNEL_PRIVATE {
+		if _, ok := m.onlineUser(msg.TargetId); !ok {
+			self := *msg
+			self.Offline = true
+			user.session.Push(protocol.OnChat, &self)
+			return
+		}
+	}
+	m.deliverChannel(user, msg.Channel, msg.TargetId, protocol.OnChat,
+		&protocol.ChatBroadcastRequest{HeroId: user.heroData.Id, Message: msg}, msg)
+}
+
+// 按频道推送, 附近和场景频道由hero所在的game节点广播
+func (m *Manager) deliverChannel(user *User, channel int, targetId int64, route string, broadcast *protocol.ChatBroadcastRequest, msg interface{}) {
+	switch channel {
+	case constants.CHAT_CHANNEL_NEARBY, constants.CHAT_CHANNEL_SCENE:
+		broadcast.Sign = broadcast.SignWith(viper.GetString("cluster.secret"))
+		if err := user.session.RPC("SceneManager.ChatBroadcast", broadcast); err != nil {
+			logger.Errorf("rpc.Call(SceneManager.ChatBroadcast) err: %v", err)
+		}
+	case constants.CHAT_CHANNEL_WORLD:
+		m.group.Broadcast(route, msg)
+	case constants.CHAT_CHANNEL_PARTY:
+		for _, uid := range m.partyOf(user.Uid).uids() {
+			if member, ok := m.onlineUser(uid); ok {
+				member.session.Push(route, msg)
+			}
+		}
+	case constants.CHAT_CHANNEL_GUILD:
+		for _, member := range m.guildOnlineUsers(targetId) {
+			member.session.Push(route, msg)
+		}
+	case constants.CHAT_CHANNEL_PRIVATE:
+		if target, ok := m.onlineUser(targetId); ok {
+			target.session.Push(route, msg)
+		}
+		user.session.Push(route, msg)
+	}
+}
+
+// 第一次访问时从数据库加载, 之后以内存为准
+func (m *Manager) chatHistoryOf(key chatKey) []protocol.ChatMessage {
+	if list, ok := m.chatHistory[key]; ok {
+		return list
+	}
+	var records []model.ChatMessage
+	var err error
+	if key.channel == constants.CHAT_CHANNEL_PRIVATE {
+		records, err = db.RecentPrivateMessages(key.targetId, CHAT_HISTORY_SIZE)
+	} else {
+		records, err = db.RecentChatMessages(key.channel, key.targetId, CHAT_HISTORY_SIZE)
+	}
+	if err != nil {
+		logger.Errorf("加载聊天记录:%+v失败: %v", key, err)
+		return nil
+	}
+	list := make([]protocol.ChatMessage, 0, len(records))
+	for _, r := range records {
+		list = append(list, protocol.ChatMessage{
+			Channel:    r.Channel,
+			TargetId:   r.TargetId,
+			FromUid:    r.FromUid,
+			FromHeroId: r.FromHeroId,
+			FromName:   r.FromName,
+			Msg:        r.Msg,
+			SendAt:     r.SendAt,
+		})
+	}
+	m.chatHistory[key] = list
+	return list
+}
+
+func (m *Manager) appendChatHistory(key chatKey, msg protocol.ChatMessage) {
+	list := append(m.chatHistoryOf(key), msg)
+	if len(list) > CHAT_HISTORY_SIZE {
+		list = list[len(list)-CHAT_HISTORY_SIZE:]
+	}
+	m.chatHistory[key] = list
+}
+
+// 进入游戏时推送世界、场景、队伍、公会和私聊的最近消息
+func (m *Manager) pushChatHistory(user *User) {
+	keys := []chatKey{
+		{constants.CHAT_CHANNEL_WORLD, 0},
+		{constants.CHAT_CHANNEL_PRIVATE, user.Uid},
+	}
+	if user.instanceId > 0 {
+		keys = append(keys, chatKey{constants.CHAT_CHANNEL_SCENE, user.instanceId})
+	} else {
+		keys = append(keys, chatKey{constants.CHAT_CHANNEL_SCENE, int64(user.heroData.SceneId)})
+	}
+	if p := m.partyOf(user.Uid); p != nil {
+		keys = append(keys, chatKey{constants.CHAT_CHANNEL_PARTY, p.id})
+	}
+	if user.guild != nil {
+		keys = append(keys, chatKey{constants.CHAT_CHANNEL_GUILD, user.guild.GuildId})
+	}
+	res := &protocol.ChatHistoryResponse{Messages: make([]protocol.ChatMessage, 0)}
+	for _, key := range keys {
+		res.Messages = append(res.Messages, m.chatHistoryOf(key)...)
+	}
+	sort.SliceStable(res.Messages, func(i, j int) bool {
+		return res.Messages[i].SendAt < res.Messages[j].SendAt
+	})
+	user.session.Push(protocol.OnChatHistory, res)
+}
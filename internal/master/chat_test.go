@@ -0,0 +1,93 @@
+package master
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/pkg/voicestore"
+	"github.com/nano/gameserver/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatLimiter(t *testing.T) {
+	rule := chatRule{maxLen: 10, cooldown: 1000, limit: 3}
+	l := &chatLimiter{}
+	assert.Nil(t, l.allow(rule, 0))
+	assert.Equal(t, errChatCooldown, l.allow(rule, 500))
+	assert.Nil(t, l.allow(rule, 1000))
+	assert.Nil(t, l.allow(rule, 2000))
+	// 一分钟内最多3条
+	assert.Equal(t, errChatLimited, l.allow(rule, 3000))
+	// 第一条已经超出统计时间
+	assert.Nil(t, l.allow(rule, CHAT_LIMIT_WINDOW))
+	assert.Len(t, l.sendAt, 3)
+}
+
+func TestAppendChatHistory(t *testing.T) {
+	m := NewManager()
+	key := chatKey{channel: constants.CHAT_CHANNEL_WORLD}
+	// 已经加载过, 不再读取数据库
+	m.chatHistory[key] = nil
+	for i := 0; i < CHAT_HISTORY_SIZE+5; i++ {
+		m.appendChatHistory(key, protocol.ChatMessage{SendAt: int64(i)})
+	}
+	list := m.chatHistoryOf(key)
+	assert.Len(t, list, CHAT_HISTORY_SIZE)
+	assert.Equal(t, int64(5), list[0].SendAt)
+}
+
+func TestExpireChatLimiters(t *testing.T) {
+	m := NewManager()
+	rule := chatRules[constants.CHAT_CHANNEL_WORLD]
+	assert.Nil(t, m.chatLimiterOf(1, constants.CHAT_CHANNEL_WORLD).allow(rule, 0))
+	assert.Nil(t, m.chatLimiterOf(1, constants.CHAT_CHANNEL_GUILD).allow(rule, CHAT_LIMIT_WINDOW/2))
+	m.expireChatLimiters(CHAT_LIMIT_WINDOW)
+	assert.Len(t, m.chatLimiters[1], 1)
+	assert.NotNil(t, m.chatLimiters[1][constants.CHAT_CHANNEL_GUILD])
+	m.expireChatLimiters(CHAT_LIMIT_WINDOW * 2)
+	assert.Empty(t, m.chatLimiters)
+}
+
+func TestChatLogoutKeepLimiter(t *testing.T) {
+	m := NewManager()
+	old := defaultManager
+	defaultManager = m
+	defer func() { defaultManager = old }()
+
+	u := addTestUser(m, 1)
+	key := chatKey{constants.CHAT_CHANNEL_PRIVATE, 1}
+	m.chatHistory[key] = []protocol.ChatMessage{{FromUid: 2, Msg: "hello"}}
+	assert.Nil(t, m.chatLimiterOf(1, constants.CHAT_CHANNEL_WORLD).allow(chatRules[constants.CHAT_CHANNEL_WORLD], time.Now().UnixMilli()))
+
+	m.removePlayer(u.Uid)
+	// 私聊记录下线时清理, 发送记录保留, 重新登录不能绕过限制
+	_, ok := m.chatHistory[key]
+	assert.False(t, ok)
+	u = addTestUser(m, 1)
+	assert.Equal(t, errChatCooldown, m.ChatSend(u.session, &protocol.ChatSendRequest{Channel: constants.CHAT_CHANNEL_WORLD, Msg: "hello"}))
+}
+
+func TestChatBroadcastSign(t *testing.T) {
+	secret := "test-secret"
+	req := &protocol.ChatBroadcastRequest{
+		HeroId:  1,
+		Message: &protocol.ChatMessage{Channel: constants.CHAT_CHANNEL_WORLD, FromUid: 1, FromName: "a", Msg: "hello", SendAt: 1000},
+	}
+	req.Sign = req.SignWith(secret)
+	assert.Equal(t, req.Sign, req.SignWith(secret))
+	// 发送者也在签名内
+	req.Message.FromName = "b"
+	assert.NotEqual(t, req.Sign, req.SignWith(secret))
+}
+
+func TestVoiceUploadTokenLimit(t *testing.T) {
+	m := NewManager()
+	u := addTestUser(m, 1)
+	assert.Nil(t, m.VoiceUploadToken(u.session, &protocol.EmptyRequest{}))
+	token := testEntityOf(u).responses[0].(*protocol.VoiceUploadToken)
+	assert.Equal(t, token.SignWith(voicestore.TokenKey()), token.Token)
+	// 间隔太短不再发放
+	assert.Equal(t, errChatCooldown, m.VoiceUploadToken(u.session, &protocol.EmptyRequest{}))
+	assert.Len(t, testEntityOf(u).responses, 1)
+}
@@ -0,0 +1,75 @@
+package master
+
+// 测试共用的在线玩家和数据库
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/db/dbtest"
+	"github.com/nano/gameserver/db/model"
+)
+
+// 记录推送给客户端和发给game节点的消息
+type testEntity struct {
+	pushes    []string
+	rpcs      []string
+	responses []interface{}
+}
+
+func (e *testEntity) Push(route string, v interface{}) error {
+	e.pushes = append(e.pushes, route)
+	return nil
+}
+
+func (e *testEntity) RPC(route string, v interface{}) error {
+	e.rpcs = append(e.rpcs, route)
+	return nil
+}
+
+func (e *testEntity) LastMid() uint64 { return 0 }
+
+func (e *testEntity) Response(v interface{}) error {
+	e.responses = append(e.responses, v)
+	return nil
+}
+
+func (e *testEntity) ResponseMid(mid uint64, v interface{}) error { return e.Response(v) }
+
+func (e *testEntity) Close() error { return nil }
+
+func (e *testEntity) RemoteAddr() net.Addr { return nil }
+
+// 添加一个已经选择了英雄的在线玩家, hero id为uid*10
+func addTestUser(m *Manager, uid int64) *User {
+	s := session.New(&testEntity{})
+	s.Bind(uid)
+	user := &User{
+		Uid:      uid,
+		session:  s,
+		heroData: &model.Hero{Id: uid * 10, Uid: uid, Name: fmt.Sprintf("hero%d", uid), SceneId: 1},
+		friends:  map[int64]bool{},
+		blocks:   map[int64]bool{},
+	}
+	m.players[uid] = user
+	return user
+}
+
+func testEntityOf(user *User) *testEntity {
+	return user.session.NetworkEntity().(*testEntity)
+}
+
+// 清空记录的消息, 只检查之后的操作
+func resetTestEntity(users ...*User) {
+	for _, user := range users {
+		*testEntityOf(user) = testEntity{}
+	}
+}
+
+// 使用sqlite数据库, 每个测试开始时清空数据
+func startTestDB(t *testing.T) {
+	dbtest.Start(t, new(model.User), new(model.Hero),
+		new(model.Guild), new(model.GuildMember), new(model.GuildApply),
+		new(model.Friend), new(model.FriendRequest), new(model.FriendBlock))
+}
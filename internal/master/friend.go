@@ -0,0 +1,336 @@
+package master
+
+// 好友, 流程见protocol/friend.go
+// 好友、申请和屏蔽保存在数据库, 在线玩家的好友和屏蔽列表缓存在User内
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+)
+
+const (
+	// 好友数量上限
+	FRIEND_MAX = 100
+)
+
+var (
+	errNotFriend     = errors.New("不是好友")
+	errAlreadyFriend = errors.New("已经是好友")
+	errFriendFull    = errors.New("好友数量已满")
+)
+
+// 进入游戏时加载好友和屏蔽列表
+func (m *Manager) loadFriends(user *User) {
+	user.friends, user.blocks = map[int64]bool{}, map[int64]bool{}
+	friends, err := db.FriendUids(user.Uid)
+	if err != nil {
+		logger.Errorf("玩家: %d加载好友失败: %v", user.Uid, err)
+	}
+	for _, uid := range friends {
+		user.friends[uid] = true
+	}
+	blocks, err := db.BlockedUids(user.Uid)
+	if err != nil {
+		logger.Errorf("玩家: %d加载屏蔽列表失败: %v", user.Uid, err)
+	}
+	for _, uid := range blocks {
+		user.blocks[uid] = true
+	}
+}
+
+func onlineFriendInfo(user *User) protocol.FriendInfo {
+	return protocol.FriendInfo{
+		Uid:     user.Uid,
+		HeroId:  user.heroData.Id,
+		Name:    user.heroData.Name,
+		Level:   user.heroData.Level,
+		Online:  true,
+		SceneId: user.heroData.SceneId,
+	}
+}
+
+func offlineFriendInfo(uid int64, hero model.Hero) protocol.FriendInfo {
+	return protocol.FriendInfo{Uid: uid, HeroId: hero.Id, Name: hero.Name, Level: hero.Level}
+}
+
+func (m *Manager) friendInfos(uids []int64) []protocol.FriendInfo {
+	infos := make([]protocol.FriendInfo, 0, len(uids))
+	offline := make([]int64, 0)
+	for _, uid := range uids {
+		if user, ok := m.onlineUser(uid); ok {
+			infos = append(infos, onlineFriendInfo(user))
+		} else {
+			offline = append(offline, uid)
+		}
+	}
+	heroes, err := db.LastHeroes(offline)
+	if err != nil {
+		logger.Errorf("加载好友信息失败: %v", err)
+	}
+	for _, uid := range offline {
+		infos = append(infos, offlineFriendInfo(uid, heroes[uid]))
+	}
+	return infos
+}
+
+func (m *Manager) onlineFriends(user *User) []*User {
+	result := make([]*User, 0)
+	for uid := range user.friends {
+		if friend, ok := m.onlineUser(uid); ok {
+			result = append(result, friend)
+		}
+	}
+	return result
+}
+
+// 上线和切换场景时推送给在线的好友
+func (m *Manager) notifyPresence(user *User) {
+	if user.heroData == nil {
+		return
+	}
+	info := onlineFriendInfo(user)
+	for _, friend := range m.onlineFriends(user) {
+		friend.session.Push(protocol.OnFriendPresence, &info)
+	}
+}
+
+func (m *Manager) notifyOffline(user *User) {
+	if user.heroData == nil {
+		return
+	}
+	info := onlineFriendInfo(user)
+	info.Online, info.SceneId = false, 0
+	for _, friend := range m.onlineFriends(user) {
+		friend.session.Push(protocol.OnFriendPresence, &info)
+	}
+}
+
+// uid是否屏蔽了blockUid, 不在线时查询数据库
+func (m *Manager) isBlocked(uid, blockUid int64) bool {
+	if user, ok := m.player(uid); ok && user.blocks != nil {
+		return user.blocks[blockUid]
+	}
+	blocked, err := db.IsBlocked(uid, blockUid)
+	if err != nil {
+		logger.Errorf("查询屏蔽失败: %v", err)
+	}
+	return blocked
+}
+
+func (m *Manager) friendUser(s *session.Session) (*User, error) {
+	user, ok := m.onlineUser(s.UID())
+	if !ok || user.friends == nil {
+		return nil, fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	return user, nil
+}
+
+func (m *Manager) checkFriendCount(uid int64) error {
+	n, err := db.FriendCount(uid)
+	if err != nil {
+		return errutil.ErrDBOperation
+	}
+	if n >= FRIEND_MAX {
+		return errFriendFull
+	}
+	return nil
+}
+
+func (m *Manager) addFriend(uid, friendUid int64) error {
+	if err := m.checkFriendCount(uid); err != nil {
+		return err
+	}
+	if err := m.checkFriendCount(friendUid); err != nil {
+		return errors.New("对方好友数量已满")
+	}
+	if err := db.InsertFriend(uid, friendUid); err != nil {
+		return err
+	}
+	logger.Infof("玩家: %d和玩家: %d成为好友", uid, friendUid)
+	infos := m.friendInfos([]int64{uid, friendUid})
+	for _, pair := range [][2]int64{{uid, friendUid}, {friendUid, uid}} {
+		user, ok := m.onlineUser(pair[0])
+		if !ok || user.friends == nil {
+			continue
+		}
+		user.friends[pair[1]] = true
+		user.session.Push(protocol.OnFriendChanged, &protocol.FriendChangedResponse{Friend: friendInfoOf(infos, pair[1])})
+	}
+	return nil
+}
+
+func friendInfoOf(infos []protocol.FriendInfo, uid int64) protocol.FriendInfo {
+	for _, info := range infos {
+		if info.Uid == uid {
+			return info
+		}
+	}
+	return protocol.FriendInfo{Uid: uid}
+}
+
+// 只更新内存和客户端, 数据库由调用方删除
+func (m *Manager) removeFriend(uid, friendUid int64) {
+	for _, pair := range [][2]int64{{uid, friendUid}, {friendUid, uid}} {
+		user, ok := m.onlineUser(pair[0])
+		if !ok || user.friends == nil || !user.friends[pair[1]] {
+			continue
+		}
+		delete(user.friends, pair[1])
+		user.session.Push(protocol.OnFriendChanged, &protocol.FriendChangedResponse{
+			Removed: true,
+			Friend:  protocol.FriendInfo{Uid: pair[1]},
+		})
+	}
+}
+
+func (m *Manager) FriendRequest(s *session.Session, req *protocol.FriendUidRequest) error {
+	user, err := m.friendUser(s)
+	if err != nil {
+		return err
+	}
+	if req.Uid == user.Uid {
+		return errors.New("不能添加自己")
+	}
+	if user.friends[req.Uid] {
+		return errAlreadyFriend
+	}
+	if user.blocks[req.Uid] {
+		return errors.New("已经屏蔽了对方")
+	}
+	if _, ok := m.player(req.Uid); !ok {
+		if _, err := db.QueryUser(req.Uid); err != nil {
+			return fmt.Errorf("玩家: %d不存在", req.Uid)
+		}
+	}
+	if m.isBlocked(req.Uid, user.Uid) {
+		return errors.New("对方拒绝添加好友")
+	}
+	// 对方也申请过时直接成为好友
+	if _, err := db.QueryFriendRequest(req.Uid, user.Uid); err == nil {
+		return m.addFriend(user.Uid, req.Uid)
+	}
+	if err := m.checkFriendCount(user.Uid); err != nil {
+		return err
+	}
+	r := &model.FriendRequest{
+		Uid:       user.Uid,
+		TargetUid: req.Uid,
+		Name:      user.heroData.Name,
+		RequestAt: time.Now().UnixMilli(),
+	}
+	if err := db.InsertFriendRequest(r); err != nil {
+		return err
+	}
+	if target, ok := m.onlineUser(req.Uid); ok {
+		target.session.Push(protocol.OnFriendRequest, &protocol.FriendRequestInfo{
+			Uid:       r.Uid,
+			Name:      r.Name,
+			RequestAt: r.RequestAt,
+		})
+	}
+	return nil
+}
+
+func (m *Manager) FriendAccept(s *session.Session, req *protocol.FriendUidRequest) error {
+	user, err := m.friendUser(s)
+	if err != nil {
+		return err
+	}
+	if _, err := db.QueryFriendRequest(req.Uid, user.Uid); err != nil {
+		return errors.New("申请不存在")
+	}
+	if user.friends[req.Uid] {
+		db.DeleteFriendRequest(req.Uid, user.Uid)
+		return errAlreadyFriend
+	}
+	return m.addFriend(user.Uid, req.Uid)
+}
+
+func (m *Manager) FriendReject(s *session.Session, req *protocol.FriendUidRequest) error {
+	user, err := m.friendUser(s)
+	if err != nil {
+		return err
+	}
+	return db.DeleteFriendRequest(req.Uid, user.Uid)
+}
+
+func (m *Manager) FriendRemove(s *session.Session, req *protocol.FriendUidRequest) error {
+	user, err := m.friendUser(s)
+	if err != nil {
+		return err
+	}
+	if !user.friends[req.Uid] {
+		return errNotFriend
+	}
+	if err := db.DeleteFriend(user.Uid, req.Uid); err != nil {
+		return err
+	}
+	logger.Infof("玩家: %d删除好友: %d", user.Uid, req.Uid)
+	m.removeFriend(user.Uid, req.Uid)
+	return nil
+}
+
+// 屏蔽后同时删除好友关系
+func (m *Manager) FriendBlock(s *session.Session, req *protocol.FriendUidRequest) error {
+	user, err := m.friendUser(s)
+	if err != nil {
+		return err
+	}
+	if req.Uid == user.Uid {
+		return errors.New("不能屏蔽自己")
+	}
+	if user.blocks[req.Uid] {
+		return nil
+	}
+	if err := db.InsertFriendBlock(user.Uid, req.Uid); err != nil {
+		return err
+	}
+	user.blocks[req.Uid] = true
+	m.removeFriend(user.Uid, req.Uid)
+	return nil
+}
+
+func (m *Manager) FriendUnblock(s *session.Session, req *protocol.FriendUidRequest) error {
+	user, err := m.friendUser(s)
+	if err != nil {
+		return err
+	}
+	if err := db.DeleteFriendBlock(user.Uid, req.Uid); err != nil {
+		return err
+	}
+	delete(user.blocks, req.Uid)
+	return nil
+}
+
+func (m *Manager) FriendList(s *session.Session, req *protocol.EmptyRequest) error {
+	user, err := m.friendUser(s)
+	if err != nil {
+		return err
+	}
+	uids := make([]int64, 0, len(user.friends))
+	for uid := range user.friends {
+		uids = append(uids, uid)
+	}
+	res := &protocol.FriendListResponse{
+		Friends:  m.friendInfos(uids),
+		Requests: make([]protocol.FriendRequestInfo, 0),
+		Blocks:   make([]int64, 0, len(user.blocks)),
+	}
+	requests, err := db.FriendRequests(user.Uid)
+	if err != nil {
+		return err
+	}
+	for _, r := range requests {
+		res.Requests = append(res.Requests, protocol.FriendRequestInfo{Uid: r.Uid, Name: r.Name, RequestAt: r.RequestAt})
+	}
+	for uid := range user.blocks {
+		res.Blocks = append(res.Blocks, uid)
+	}
+	return s.Response(res)
+}
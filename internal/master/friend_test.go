@@ -0,0 +1,91 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnlineFriends(t *testing.T) {
+	m := NewManager()
+	u1, u2, u3 := addTestUser(m, 1), addTestUser(m, 2), addTestUser(m, 3)
+	for _, uid := range []int64{2, 3, 4} {
+		u1.friends[uid] = true
+	}
+	u2.friends[1] = true
+	// 4不在线
+	assert.ElementsMatch(t, []*User{u2, u3}, m.onlineFriends(u1))
+
+	info := onlineFriendInfo(u2)
+	assert.Equal(t, protocol.FriendInfo{Uid: 2, HeroId: 20, Name: "hero2", Online: true, SceneId: 1}, info)
+	assert.Equal(t, info, friendInfoOf([]protocol.FriendInfo{info}, 2))
+	assert.Equal(t, protocol.FriendInfo{Uid: 5}, friendInfoOf([]protocol.FriendInfo{info}, 5))
+
+	u2.blocks[1] = true
+	assert.True(t, m.isBlocked(2, 1))
+	assert.False(t, m.isBlocked(1, 2))
+}
+
+func TestFriendRequestAccept(t *testing.T) {
+	startTestDB(t)
+	m := NewManager()
+	u1, u2, u3 := addTestUser(m, 1), addTestUser(m, 2), addTestUser(m, 3)
+
+	assert.NotNil(t, m.FriendRequest(u1.session, &protocol.FriendUidRequest{Uid: 1}))
+	// 玩家不存在
+	assert.NotNil(t, m.FriendRequest(u1.session, &protocol.FriendUidRequest{Uid: 9}))
+
+	assert.Nil(t, m.FriendRequest(u1.session, &protocol.FriendUidRequest{Uid: 2}))
+	assert.Equal(t, []string{protocol.OnFriendRequest}, testEntityOf(u2).pushes)
+	// 对方也申请时直接成为好友
+	assert.Nil(t, m.FriendRequest(u2.session, &protocol.FriendUidRequest{Uid: 1}))
+	assert.True(t, u1.friends[2])
+	assert.True(t, u2.friends[1])
+	assert.Contains(t, testEntityOf(u1).pushes, protocol.OnFriendChanged)
+	_, err := db.QueryFriendRequest(1, 2)
+	assert.NotNil(t, err)
+	assert.Equal(t, errAlreadyFriend, m.FriendRequest(u1.session, &protocol.FriendUidRequest{Uid: 2}))
+
+	// 拒绝后申请删除, 不能再接受
+	assert.Nil(t, m.FriendRequest(u3.session, &protocol.FriendUidRequest{Uid: 1}))
+	assert.Nil(t, m.FriendReject(u1.session, &protocol.FriendUidRequest{Uid: 3}))
+	assert.NotNil(t, m.FriendAccept(u1.session, &protocol.FriendUidRequest{Uid: 3}))
+	assert.Nil(t, m.FriendRequest(u3.session, &protocol.FriendUidRequest{Uid: 1}))
+	assert.Nil(t, m.FriendAccept(u1.session, &protocol.FriendUidRequest{Uid: 3}))
+	assert.True(t, u3.friends[1])
+
+	assert.Nil(t, m.FriendRemove(u1.session, &protocol.FriendUidRequest{Uid: 3}))
+	assert.False(t, u1.friends[3])
+	assert.False(t, u3.friends[1])
+	assert.Equal(t, errNotFriend, m.FriendRemove(u1.session, &protocol.FriendUidRequest{Uid: 3}))
+}
+
+func TestFriendBlock(t *testing.T) {
+	startTestDB(t)
+	m := NewManager()
+	u1, u2 := addTestUser(m, 1), addTestUser(m, 2)
+	assert.Nil(t, m.FriendRequest(u1.session, &protocol.FriendUidRequest{Uid: 2}))
+	assert.Nil(t, m.FriendAccept(u2.session, &protocol.FriendUidRequest{Uid: 1}))
+
+	// 屏蔽后删除双方的好友关系, 对方不能再申请
+	assert.Nil(t, m.FriendBlock(u1.session, &protocol.FriendUidRequest{Uid: 2}))
+	assert.True(t, u1.blocks[2])
+	assert.False(t, u1.friends[2])
+	assert.False(t, u2.friends[1])
+	assert.NotNil(t, m.FriendRequest(u2.session, &protocol.FriendUidRequest{Uid: 1}))
+	assert.NotNil(t, m.FriendRequest(u1.session, &protocol.FriendUidRequest{Uid: 2}))
+	uids, err := db.FriendUids(1)
+	assert.Nil(t, err)
+	assert.Empty(t, uids)
+
+	// 下线后从数据库判断
+	delete(m.players, 1)
+	assert.True(t, m.isBlocked(1, 2))
+	m.players[1] = u1
+
+	assert.Nil(t, m.FriendUnblock(u1.session, &protocol.FriendUidRequest{Uid: 2}))
+	assert.False(t, m.isBlocked(1, 2))
+	assert.Nil(t, m.FriendRequest(u2.session, &protocol.FriendUidRequest{Uid: 1}))
+}
@@ -42,6 +42,7 @@ func createRandomHero(uid int64, sceneId int, name, avatar string, attrType int)
 		StepTime:    300,
 		SceneId:     sceneId,
 		AttackRange: 3,
+		Spells:      constants.DEFAULT_HERO_SPELLS,
 	}
 	if attrType == constants.ATTR_TYPE_STRENGTH {
 		h.BaseDefense = 5
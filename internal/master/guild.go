@@ -0,0 +1,372 @@
+package master
+
+// 公会, 流程见protocol/guild.go
+// 公会和成员保存在数据库, 在线的hero所在的公会缓存在User内
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 公会的人数上限
+	GUILD_MAX_MEMBERS = 50
+	// 公会名字的长度
+	GUILD_NAME_MIN_LEN = 2
+	GUILD_NAME_MAX_LEN = 12
+	// 公告的最大长度
+	GUILD_NOTICE_MAX_LEN = 200
+)
+
+var (
+	errNotInGuild      = errors.New("不在公会内")
+	errAlreadyGuild    = errors.New("已经在公会内")
+	errGuildFull       = errors.New("公会人数已满")
+	errGuildPermission = errors.New("公会职位不足")
+)
+
+// 选择英雄进入游戏时加载所在的公会
+func (m *Manager) loadGuild(user *User) {
+	user.guild, user.guildName = nil, ""
+	if user.heroData == nil {
+		return
+	}
+	gm, err := db.QueryGuildMember(user.heroData.Id)
+	if err != nil {
+		if err != errutil.ErrNotFound {
+			logger.Errorf("玩家: %d加载公会失败: %v", user.Uid, err)
+		}
+		return
+	}
+	g, err := db.QueryGuild(gm.GuildId)
+	if err != nil {
+		logger.Errorf("玩家: %d加载公会:%d失败: %v", user.Uid, gm.GuildId, err)
+		return
+	}
+	user.guild, user.guildName = gm, g.Name
+}
+
+func heroGuild(user *User) *protocol.HeroGuild {
+	if user.guild == nil {
+		return nil
+	}
+	return &protocol.HeroGuild{GuildId: user.guild.GuildId, Name: user.guildName}
+}
+
+// 更新在线hero的公会, 通知客户端和所在的game节点
+func (m *Manager) setUserGuild(user *User, gm *model.GuildMember, name string) {
+	user.guild, user.guildName = gm, name
+	res := &protocol.GuildChangedResponse{}
+	if gm != nil {
+		res.GuildId, res.Name, res.Rank = gm.GuildId, name, gm.Rank
+	}
+	user.session.Push(protocol.OnGuildChanged, res)
+	req := &protocol.HeroGuildChangedRequest{
+		HeroId: user.heroData.Id,
+		Guild:  heroGuild(user),
+	}
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+	if err := user.session.RPC("SceneManager.HeroGuildChanged", req); err != nil {
+		logger.Errorf("rpc.Call(SceneManager.HeroGuildChanged) err: %v", err)
+	}
+}
+
+// 在线的公会成员
+func (m *Manager) guildOnlineUsers(guildId int64) []*User {
+	users := make([]*User, 0)
+	for _, user := range m.players {
+		if user.session != nil && user.heroData != nil && user.guild != nil && user.guild.GuildId == guildId {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func (m *Manager) onlineHero(heroId int64) (*User, bool) {
+	for _, user := range m.players {
+		if user.session != nil && user.heroData != nil && user.heroData.Id == heroId {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+// 在公会内并且职位不低于rank
+func (m *Manager) guildUser(s *session.Session, rank int) (*User, error) {
+	user, ok := m.onlineUser(s.UID())
+	if !ok {
+		return nil, fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	if user.guild == nil {
+		return nil, errNotInGuild
+	}
+	if user.guild.Rank < rank {
+		return nil, errGuildPermission
+	}
+	return user, nil
+}
+
+// 同一个公会内的其他成员
+func (m *Manager) guildTarget(user *User, heroId int64) (*model.GuildMember, error) {
+	if heroId == user.heroData.Id {
+		return nil, errors.New("不能操作自己")
+	}
+	target, err := db.QueryGuildMember(heroId)
+	if err != nil || target.GuildId != user.guild.GuildId {
+		return nil, errors.New("成员不存在")
+	}
+	return target, nil
+}
+
+func (m *Manager) GuildCreate(s *session.Session, req *protocol.GuildCreateRequest) error {
+	user, ok := m.onlineUser(s.UID())
+	if !ok {
+		return fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	if user.guild != nil {
+		return errAlreadyGuild
+	}
+	if n := utf8.RuneCountInString(req.Name); n < GUILD_NAME_MIN_LEN || n > GUILD_NAME_MAX_LEN {
+		return errors.New("公会名字长度错误")
+	}
+	if exists, err := db.GuildNameExists(req.Name); err != nil || exists {
+		return errors.New("公会名字已存在")
+	}
+	g := &model.Guild{Name: req.Name, LeaderHeroId: user.heroData.Id}
+	leader := &model.GuildMember{HeroId: user.heroData.Id, Uid: user.Uid, Name: user.heroData.Name}
+	if err := db.InsertGuild(g, leader); err != nil {
+		logger.Errorf("玩家: %d创建公会失败: %v", user.Uid, err)
+		return errutil.ErrDBOperation
+	}
+	logger.Infof("玩家: %d创建公会:%d-%s", user.Uid, g.Id, g.Name)
+	m.setUserGuild(user, leader, g.Name)
+	return nil
+}
+
+func (m *Manager) GuildApply(s *session.Session, req *protocol.GuildApplyRequest) error {
+	user, ok := m.onlineUser(s.UID())
+	if !ok {
+		return fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	if user.guild != nil {
+		return errAlreadyGuild
+	}
+	if _, err := db.QueryGuild(req.GuildId); err != nil {
+		return errors.New("公会不存在")
+	}
+	if cnt, err := db.GuildMemberCount(req.GuildId); err != nil || cnt >= GUILD_MAX_MEMBERS {
+		return errGuildFull
+	}
+	apply := &model.GuildApply{
+		GuildId: req.GuildId,
+		HeroId:  user.heroData.Id,
+		Uid:     user.Uid,
+		Name:    user.heroData.Name,
+	}
+	if err := db.InsertGuildApply(apply); err != nil {
+		logger.Errorf("玩家: %d申请加入公会:%d失败: %v", user.Uid, req.GuildId, err)
+		return errutil.ErrDBOperation
+	}
+	res := &protocol.GuildApplyResponse{HeroId: apply.HeroId, Name: apply.Name}
+	for _, member := range m.guildOnlineUsers(req.GuildId) {
+		if member.guild.Rank >= constants.GUILD_RANK_OFFICER {
+			member.session.Push(protocol.OnGuildApply, res)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) GuildApprove(s *session.Session, req *protocol.GuildApproveRequest) error {
+	user, err := m.guildUser(s, constants.GUILD_RANK_OFFICER)
+	if err != nil {
+		return err
+	}
+	guildId := user.guild.GuildId
+	apply, err := db.QueryGuildApply(guildId, req.HeroId)
+	if err != nil {
+		return errors.New("申请不存在")
+	}
+	if !req.Accept {
+		return db.DeleteGuildApply(guildId, req.HeroId)
+	}
+	if _, err := db.QueryGuildMember(req.HeroId); err == nil {
+		db.DeleteGuildApply(guildId, req.HeroId)
+		return errAlreadyGuild
+	}
+	if cnt, err := db.GuildMemberCount(guildId); err != nil || cnt >= GUILD_MAX_MEMBERS {
+		return errGuildFull
+	}
+	member := &model.GuildMember{
+		GuildId: guildId,
+		HeroId:  apply.HeroId,
+		Uid:     apply.Uid,
+		Name:    apply.Name,
+		Rank:    constants.GUILD_RANK_MEMBER,
+	}
+	if err := db.InsertGuildMember(member); err != nil {
+		logger.Errorf("公会:%d 添加成员: %d失败: %v", guildId, req.HeroId, err)
+		return errutil.ErrDBOperation
+	}
+	logger.Infof("公会:%d 成员: %d同意hero:%d加入", guildId, user.heroData.Id, req.HeroId)
+	if target, ok := m.onlineHero(req.HeroId); ok {
+		m.setUserGuild(target, member, user.guildName)
+	}
+	return nil
+}
+
+func (m *Manager) GuildKick(s *session.Session, req *protocol.GuildKickRequest) error {
+	user, err := m.guildUser(s, constants.GUILD_RANK_OFFICER)
+	if err != nil {
+		return err
+	}
+	target, err := m.guildTarget(user, req.HeroId)
+	if err != nil {
+		return err
+	}
+	if target.Rank >= user.guild.Rank {
+		return errGuildPermission
+	}
+	if err := db.DeleteGuildMember(req.HeroId); err != nil {
+		return errutil.ErrDBOperation
+	}
+	logger.Infof("公会:%d 成员: %d踢出hero:%d", user.guild.GuildId, user.heroData.Id, req.HeroId)
+	if u, ok := m.onlineHero(req.HeroId); ok {
+		m.setUserGuild(u, nil, "")
+	}
+	return nil
+}
+
+func (m *Manager) GuildLeave(s *session.Session, req *protocol.EmptyRequest) error {
+	user, err := m.guildUser(s, constants.GUILD_RANK_MEMBER)
+	if err != nil {
+		return err
+	}
+	if user.guild.Rank == constants.GUILD_RANK_LEADER {
+		return errors.New("会长需要先转让会长或者解散公会")
+	}
+	if err := db.DeleteGuildMember(user.heroData.Id); err != nil {
+		return errutil.ErrDBOperation
+	}
+	logger.Infof("hero:%d 离开公会:%d", user.heroData.Id, user.guild.GuildId)
+	m.setUserGuild(user, nil, "")
+	return nil
+}
+
+func (m *Manager) GuildPromote(s *session.Session, req *protocol.GuildPromoteRequest) error {
+	user, err := m.guildUser(s, constants.GUILD_RANK_LEADER)
+	if err != nil {
+		return err
+	}
+	target, err := m.guildTarget(user, req.HeroId)
+	if err != nil {
+		return err
+	}
+	switch req.Rank {
+	case constants.GUILD_RANK_MEMBER, constants.GUILD_RANK_OFFICER:
+		err = db.UpdateGuildMemberRank(req.HeroId, req.Rank)
+	case constants.GUILD_RANK_LEADER:
+		err = db.TransferGuildLeader(user.guild.GuildId, user.heroData.Id, req.HeroId)
+	default:
+		return errors.New("职位错误")
+	}
+	if err != nil {
+		logger.Errorf("公会:%d 修改hero:%d职位失败: %v", user.guild.GuildId, req.HeroId, err)
+		return errutil.ErrDBOperation
+	}
+	logger.Infof("公会:%d hero:%d 职位: %d -> %d", user.guild.GuildId, req.HeroId, target.Rank, req.Rank)
+	target.Rank = req.Rank
+	if u, ok := m.onlineHero(req.HeroId); ok {
+		m.setUserGuild(u, target, user.guildName)
+	}
+	if req.Rank == constants.GUILD_RANK_LEADER {
+		self := *user.guild
+		self.Rank = constants.GUILD_RANK_OFFICER
+		m.setUserGuild(user, &self, user.guildName)
+	}
+	return nil
+}
+
+func (m *Manager) GuildDisband(s *session.Session, req *protocol.EmptyRequest) error {
+	user, err := m.guildUser(s, constants.GUILD_RANK_LEADER)
+	if err != nil {
+		return err
+	}
+	guildId := user.guild.GuildId
+	if err := db.DeleteGuild(guildId); err != nil {
+		logger.Errorf("公会:%d 解散失败: %v", guildId, err)
+		return errutil.ErrDBOperation
+	}
+	logger.Infof("公会:%d-%s 解散", guildId, user.guildName)
+	delete(m.chatHistory, chatKey{constants.CHAT_CHANNEL_GUILD, guildId})
+	for _, member := range m.guildOnlineUsers(guildId) {
+		m.setUserGuild(member, nil, "")
+	}
+	return nil
+}
+
+func (m *Manager) GuildSetNotice(s *session.Session, req *protocol.GuildNoticeRequest) error {
+	user, err := m.guildUser(s, constants.GUILD_RANK_OFFICER)
+	if err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(req.Notice) > GUILD_NOTICE_MAX_LEN {
+		return errors.New("公告长度错误")
+	}
+	if err := db.UpdateGuildNotice(user.guild.GuildId, req.Notice); err != nil {
+		return errutil.ErrDBOperation
+	}
+	return nil
+}
+
+func (m *Manager) GuildInfo(s *session.Session, req *protocol.EmptyRequest) error {
+	user, err := m.guildUser(s, constants.GUILD_RANK_MEMBER)
+	if err != nil {
+		return err
+	}
+	g, err := db.QueryGuild(user.guild.GuildId)
+	if err != nil {
+		return err
+	}
+	members, err := db.GuildMembers(g.Id)
+	if err != nil {
+		return err
+	}
+	online := make(map[int64]bool)
+	for _, u := range m.guildOnlineUsers(g.Id) {
+		online[u.heroData.Id] = true
+	}
+	res := &protocol.GuildInfoResponse{
+		GuildId:      g.Id,
+		Name:         g.Name,
+		LeaderHeroId: g.LeaderHeroId,
+		Notice:       g.Notice,
+		Members:      make([]protocol.GuildMemberItem, 0, len(members)),
+		Applies:      make([]protocol.GuildApplyItem, 0),
+	}
+	for _, gm := range members {
+		res.Members = append(res.Members, protocol.GuildMemberItem{
+			HeroId: gm.HeroId,
+			Name:   gm.Name,
+			Rank:   gm.Rank,
+			Online: online[gm.HeroId],
+		})
+	}
+	if user.guild.Rank >= constants.GUILD_RANK_OFFICER {
+		applies, err := db.GuildApplies(g.Id)
+		if err != nil {
+			return err
+		}
+		for _, apply := range applies {
+			res.Applies = append(res.Applies, protocol.GuildApplyItem{HeroId: apply.HeroId, Name: apply.Name})
+		}
+	}
+	return s.Response(res)
+}
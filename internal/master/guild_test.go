@@ -0,0 +1,98 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuildOnlineUsers(t *testing.T) {
+	m := NewManager()
+	u1, u2, u3 := addTestUser(m, 1), addTestUser(m, 2), addTestUser(m, 3)
+	u1.guild, u1.guildName = &model.GuildMember{GuildId: 1}, "g"
+	u2.guild, u2.guildName = &model.GuildMember{GuildId: 2}, "g"
+	m.players[4] = &User{Uid: 4, guild: &model.GuildMember{GuildId: 1}}
+
+	assert.Equal(t, []*User{u1}, m.guildOnlineUsers(1))
+	assert.Equal(t, &protocol.HeroGuild{GuildId: 1, Name: "g"}, heroGuild(u1))
+	assert.Nil(t, heroGuild(u3))
+
+	u, ok := m.onlineHero(30)
+	assert.True(t, ok)
+	assert.Equal(t, u3, u)
+	_, ok = m.onlineHero(40)
+	assert.False(t, ok)
+}
+
+func TestGuildApplyApprove(t *testing.T) {
+	startTestDB(t)
+	m := NewManager()
+	u1, u2, u3 := addTestUser(m, 1), addTestUser(m, 2), addTestUser(m, 3)
+
+	assert.NotNil(t, m.GuildCreate(u1.session, &protocol.GuildCreateRequest{Name: "a"}))
+	assert.Nil(t, m.GuildCreate(u1.session, &protocol.GuildCreateRequest{Name: "公会一"}))
+	assert.Equal(t, constants.GUILD_RANK_LEADER, u1.guild.Rank)
+	assert.Contains(t, testEntityOf(u1).rpcs, "SceneManager.HeroGuildChanged")
+	assert.NotNil(t, m.GuildCreate(u2.session, &protocol.GuildCreateRequest{Name: "公会一"}))
+	guildId := u1.guild.GuildId
+
+	// 申请推送给官员以上的在线成员
+	assert.Nil(t, m.GuildApply(u2.session, &protocol.GuildApplyRequest{GuildId: guildId}))
+	assert.Nil(t, m.GuildApply(u3.session, &protocol.GuildApplyRequest{GuildId: guildId}))
+	assert.Contains(t, testEntityOf(u1).pushes, protocol.OnGuildApply)
+	assert.Equal(t, errNotInGuild, m.GuildApprove(u2.session, &protocol.GuildApproveRequest{HeroId: 30, Accept: true}))
+
+	assert.Nil(t, m.GuildApprove(u1.session, &protocol.GuildApproveRequest{HeroId: 20, Accept: true}))
+	assert.Equal(t, constants.GUILD_RANK_MEMBER, u2.guild.Rank)
+	assert.Equal(t, "公会一", u2.guildName)
+	// 拒绝后申请删除
+	assert.Nil(t, m.GuildApprove(u1.session, &protocol.GuildApproveRequest{HeroId: 30}))
+	assert.Nil(t, u3.guild)
+	_, err := db.QueryGuildApply(guildId, 30)
+	assert.NotNil(t, err)
+	assert.Equal(t, errAlreadyGuild, m.GuildApply(u2.session, &protocol.GuildApplyRequest{GuildId: guildId}))
+}
+
+func TestGuildRanks(t *testing.T) {
+	startTestDB(t)
+	m := NewManager()
+	u1, u2, u3 := addTestUser(m, 1), addTestUser(m, 2), addTestUser(m, 3)
+	assert.Nil(t, m.GuildCreate(u1.session, &protocol.GuildCreateRequest{Name: "公会一"}))
+	guildId := u1.guild.GuildId
+	for _, u := range []*User{u2, u3} {
+		assert.Nil(t, m.GuildApply(u.session, &protocol.GuildApplyRequest{GuildId: guildId}))
+		assert.Nil(t, m.GuildApprove(u1.session, &protocol.GuildApproveRequest{HeroId: u.heroData.Id, Accept: true}))
+	}
+
+	// 成员不能踢人, 只有会长可以任命
+	assert.Equal(t, errGuildPermission, m.GuildKick(u2.session, &protocol.GuildKickRequest{HeroId: 30}))
+	assert.Equal(t, errGuildPermission, m.GuildPromote(u2.session, &protocol.GuildPromoteRequest{HeroId: 30, Rank: constants.GUILD_RANK_OFFICER}))
+	assert.Nil(t, m.GuildPromote(u1.session, &protocol.GuildPromoteRequest{HeroId: 20, Rank: constants.GUILD_RANK_OFFICER}))
+	assert.Equal(t, constants.GUILD_RANK_OFFICER, u2.guild.Rank)
+
+	// 官员可以踢出职位比自己低的成员
+	assert.Equal(t, errGuildPermission, m.GuildKick(u2.session, &protocol.GuildKickRequest{HeroId: 10}))
+	assert.Nil(t, m.GuildKick(u2.session, &protocol.GuildKickRequest{HeroId: 30}))
+	assert.Nil(t, u3.guild)
+	assert.Equal(t, errNotInGuild, m.GuildLeave(u3.session, &protocol.EmptyRequest{}))
+
+	// 转让会长后原来的会长降为官员
+	assert.Nil(t, m.GuildPromote(u1.session, &protocol.GuildPromoteRequest{HeroId: 20, Rank: constants.GUILD_RANK_LEADER}))
+	assert.Equal(t, constants.GUILD_RANK_LEADER, u2.guild.Rank)
+	assert.Equal(t, constants.GUILD_RANK_OFFICER, u1.guild.Rank)
+	g, err := db.QueryGuild(guildId)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), g.LeaderHeroId)
+
+	// 会长不能直接离开
+	assert.NotNil(t, m.GuildLeave(u2.session, &protocol.EmptyRequest{}))
+	assert.Nil(t, m.GuildLeave(u1.session, &protocol.EmptyRequest{}))
+	assert.Nil(t, u1.guild)
+	members, err := db.GuildMembers(guildId)
+	assert.Nil(t, err)
+	assert.Len(t, members, 1)
+}
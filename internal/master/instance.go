@@ -0,0 +1,213 @@
+package master
+
+// 副本的分配和结果记录, 流程见protocol/instance.go
+// 副本id在master内递增, 第一次路由到副本时记录所在的game节点, 之后同一个副本的hero都路由到这个节点
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lonng/nano/cluster/clusterpb"
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+// instanceId -> game节点的ServiceAddr, 路由时会在其他携程读取
+var instanceNodes sync.Map
+
+type sceneInstance struct {
+	id      int64
+	sceneId int
+	members map[int64]bool //可以进入的玩家
+	result  int
+}
+
+// 副本按创建时分配的节点路由, 节点已经不在集群内时返回空
+func instanceMember(instanceId int64, members []*clusterpb.MemberInfo) *clusterpb.MemberInfo {
+	addr, ok := instanceNodes.Load(instanceId)
+	if !ok {
+		return nil
+	}
+	for _, m := range members {
+		if m.ServiceAddr == addr.(string) {
+			return m
+		}
+	}
+	return nil
+}
+
+func bindInstance(s *session.Session, instanceId int64) {
+	if instanceId > 0 {
+		s.Set("instanceId", instanceId)
+	} else {
+		s.Remove("instanceId")
+	}
+}
+
+func isInstanceScene(sceneId int) bool {
+	scene, err := db.QueryScene(sceneId)
+	return err == nil && scene.SceneType == constants.SCENE_TYPE_INSTANCE
+}
+
+// 可以一起进入副本的玩家, 在队伍内时是所有队员
+func (m *Manager) instanceMembers(user *User) []int64 {
+	if p := m.partyOf(user.Uid); p != nil {
+		return p.uids()
+	}
+	return []int64{user.Uid}
+}
+
+// 队友已经开启并且还没有结束的副本
+func (m *Manager) memberInstance(user *User, sceneId int) *sceneInstance {
+	for _, inst := range m.instances {
+		if inst.sceneId == sceneId && inst.result == 0 && inst.members[user.Uid] {
+			return inst
+		}
+	}
+	return nil
+}
+
+// 创建副本并把hero送进去
+func (m *Manager) enterInstance(s *session.Session, user *User, sceneId int) error {
+	if user.instanceId > 0 {
+		return errors.New("已在副本内")
+	}
+	if m.isSceneDraining(sceneId) {
+		return errSceneDraining
+	}
+	if inst := m.memberInstance(user, sceneId); inst != nil {
+		logger.Infof("玩家: %d进入队友的副本:%d-%d", user.Uid, sceneId, inst.id)
+		return m.startTransfer(s, user, sceneId, 0, inst.id, nil, false)
+	}
+	m.instanceSeq++
+	inst := &sceneInstance{
+		id:      m.instanceSeq,
+		sceneId: sceneId,
+		members: map[int64]bool{},
+	}
+	for _, uid := range m.instanceMembers(user) {
+		inst.members[uid] = true
+	}
+	m.instances[inst.id] = inst
+	if err := m.startTransfer(s, user, sceneId, 0, inst.id, nil, false); err != nil {
+		delete(m.instances, inst.id)
+		return err
+	}
+	logger.Infof("玩家: %d进入副本:%d-%d", user.Uid, sceneId, inst.id)
+	return nil
+}
+
+// 离开副本后回到进入前的场景, 没有记录时回到默认场景的出生点
+func (m *Manager) returnScene(user *User) (int, *coord.Vector3) {
+	if user.returnSceneId > 0 {
+		return user.returnSceneId, user.returnPos
+	}
+	return constants.DEFAULT_SCENE, nil
+}
+
+func (m *Manager) leaveInstance(s *session.Session, user *User) error {
+	sceneId, pos := m.returnScene(user)
+	return m.startTransfer(s, user, sceneId, 0, 0, pos, false)
+}
+
+// 重新上线时副本还在并且是成员的回到副本内, 否则回到默认场景
+func (m *Manager) resumeInstance(user *User, sceneId int) (int, int64) {
+	if !isInstanceScene(sceneId) {
+		return sceneId, 0
+	}
+	for _, inst := range m.instances {
+		if inst.sceneId == sceneId && inst.members[user.Uid] {
+			return sceneId, inst.id
+		}
+	}
+	return constants.DEFAULT_SCENE, 0
+}
+
+// game节点检测到hero在npc旁边请求进入副本
+func (m *Manager) HeroEnterInstance(s *session.Session, req *protocol.HeroEnterInstanceRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("玩家: %d进入副本签名错误: %+v", req.Uid, req)
+		return errutil.ErrPermissionDenied
+	}
+	user, ok := m.player(req.Uid)
+	if !ok || user.heroData == nil || user.heroData.Id != req.HeroId {
+		return fmt.Errorf("玩家: %d不在线", req.Uid)
+	}
+	if user.heroData.SceneId != req.SceneId {
+		return errors.New("不在npc所在的场景")
+	}
+	if !isInstanceScene(req.InstanceSceneId) {
+		return errors.New("副本配置错误")
+	}
+	return m.enterInstance(s, user, req.InstanceSceneId)
+}
+
+// 客户端请求提前离开副本
+func (m *Manager) LeaveInstance(s *session.Session, req *protocol.EmptyRequest) error {
+	user, ok := m.player(s.UID())
+	if !ok || user.session == nil {
+		return fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	if user.instanceId == 0 {
+		return errors.New("不在副本内")
+	}
+	return m.leaveInstance(user.session, user)
+}
+
+// game节点通知副本结束, 同一个副本可能会通知多次
+func (m *Manager) InstanceFinished(s *session.Session, req *protocol.InstanceFinishedRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("副本:%d结束签名错误: %+v", req.InstanceId, req)
+		return errutil.ErrPermissionDenied
+	}
+	inst := m.instances[req.InstanceId]
+	if req.Result > 0 && (inst == nil || inst.result == 0) {
+		if inst != nil {
+			inst.result = req.Result
+		}
+		logger.Infof("副本:%d-%d 结束, 结果:%d, 用时:%d秒, 玩家:%v", req.SceneId, req.InstanceId, req.Result, req.UsedTime, req.Uids)
+		uids := make([]string, 0, len(req.Uids))
+		for _, uid := range req.Uids {
+			uids = append(uids, strconv.FormatInt(uid, 10))
+		}
+		record := &model.InstanceRecord{
+			InstanceId: req.InstanceId,
+			SceneId:    req.SceneId,
+			Uids:       strings.Join(uids, ","),
+			Result:     req.Result,
+			UsedTime:   req.UsedTime,
+		}
+		async.Run(func() {
+			if err := db.InsertInstanceRecord(record); err != nil {
+				logger.Errorf("副本:%d 保存结果失败: %v", req.InstanceId, err)
+			}
+		})
+	}
+	if req.Closed {
+		delete(m.instances, req.InstanceId)
+		instanceNodes.Delete(req.InstanceId)
+	}
+	if !req.Expired && !req.Closed {
+		return nil
+	}
+	// 超时或者已经销毁, 还在副本内的hero送回原来的场景
+	for _, uid := range req.Uids {
+		user, ok := m.player(uid)
+		if !ok || user.session == nil || user.instanceId != req.InstanceId {
+			continue
+		}
+		if err := m.leaveInstance(user.session, user); err != nil && err != errTransferring {
+			logger.Errorf("玩家: %d离开副本:%d失败: %v", uid, req.InstanceId, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/lonng/nano/cluster/clusterpb"
+	"github.com/nano/gameserver/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceMember(t *testing.T) {
+	members := []*clusterpb.MemberInfo{
+		{ServiceAddr: ":1"},
+		{ServiceAddr: ":2"},
+	}
+	assert.Nil(t, instanceMember(100, members))
+
+	instanceNodes.Store(int64(100), ":2")
+	defer instanceNodes.Delete(int64(100))
+	assert.Equal(t, ":2", instanceMember(100, members).ServiceAddr)
+	// 节点已经不在集群内
+	assert.Nil(t, instanceMember(100, members[:1]))
+}
+
+func TestInstanceFinishedSign(t *testing.T) {
+	secret := "test-secret"
+	req := &protocol.InstanceFinishedRequest{InstanceId: 100, SceneId: 2, Result: 1, Uids: []int64{1, 2}, UsedTime: 60}
+	req.Sign = req.SignWith(secret)
+	assert.Equal(t, req.Sign, req.SignWith(secret))
+	// 进入过副本的玩家和用时都在签名内
+	req.Uids = append(req.Uids, 3)
+	assert.NotEqual(t, req.Sign, req.SignWith(secret))
+	req.Uids = req.Uids[:2]
+	req.UsedTime = 1
+	assert.NotEqual(t, req.Sign, req.SignWith(secret))
+}
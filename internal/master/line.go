@@ -0,0 +1,118 @@
+package master
+
+// 场景分线, 流程见protocol/line.go
+// 各条线的人数按在线玩家所在的线统计, 不需要game节点上报
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lonng/nano/cluster/clusterpb"
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/pkg/sceneline"
+	"github.com/nano/gameserver/protocol"
+)
+
+func bindLine(s *session.Session, lineId int) {
+	if lineId > 1 {
+		s.Set("lineId", lineId)
+	} else {
+		s.Remove("lineId")
+	}
+}
+
+// 节点的label内"1#2"只运行场景1的第2条线, "1"运行场景1的所有线, 优先路由到只运行这条线的节点
+func lineMember(sceneId int, lineId int, members []*clusterpb.MemberInfo) *clusterpb.MemberInfo {
+	var found *clusterpb.MemberInfo
+	line := fmt.Sprintf("%d#%d", sceneId, lineId)
+	scene := fmt.Sprintf("%d", sceneId)
+	for _, m := range members {
+		if m.Label == "" {
+			continue
+		}
+		for _, tmp := range strings.Split(strings.ReplaceAll(m.Label, "scene:", ""), ",") {
+			if lineId > 1 && tmp == line {
+				return m
+			}
+			if tmp == scene && found == nil {
+				found = m
+			}
+		}
+	}
+	return found
+}
+
+// 各条线的在线人数, 下标0是第1条线
+func (m *Manager) lineLoads(sceneId int) []int {
+	loads := make([]int, sceneline.Count(sceneId))
+	for _, user := range m.players {
+		if user.session == nil || user.heroData == nil || user.instanceId > 0 || user.heroData.SceneId != sceneId {
+			continue
+		}
+		if i := max(user.lineId, 1) - 1; i < len(loads) {
+			loads[i]++
+		}
+	}
+	return loads
+}
+
+// 人数最少的线, 只有1条线时直接返回
+func (m *Manager) chooseLine(sceneId int) int {
+	loads := m.lineLoads(sceneId)
+	lineId := 1
+	for i, cnt := range loads {
+		if cnt < loads[lineId-1] {
+			lineId = i + 1
+		}
+	}
+	return lineId
+}
+
+// 客户端查看当前场景各条线的人数
+func (m *Manager) SceneLines(s *session.Session, req *protocol.EmptyRequest) error {
+	user, ok := m.player(s.UID())
+	if !ok || user.heroData == nil {
+		return fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	capacity := sceneline.Capacity()
+	res := &protocol.SceneLinesResponse{
+		SceneId: user.heroData.SceneId,
+		LineId:  max(user.lineId, 1),
+		Lines:   make([]protocol.SceneLineItem, 0),
+	}
+	for i, cnt := range m.lineLoads(user.heroData.SceneId) {
+		res.Lines = append(res.Lines, protocol.SceneLineItem{
+			LineId:  i + 1,
+			HeroCnt: cnt,
+			Full:    cnt >= capacity,
+		})
+	}
+	return s.Response(res)
+}
+
+// 客户端请求切换到同一个场景的其他线, 保持当前的位置
+func (m *Manager) SwitchLine(s *session.Session, req *protocol.SwitchLineRequest) error {
+	user, ok := m.player(s.UID())
+	if !ok || user.session == nil || user.heroData == nil {
+		return fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	if user.instanceId > 0 {
+		return errors.New("副本内不能切换分线")
+	}
+	sceneId := user.heroData.SceneId
+	loads := m.lineLoads(sceneId)
+	if req.LineId < 1 || req.LineId > len(loads) {
+		return errors.New("分线不存在")
+	}
+	if req.LineId == max(user.lineId, 1) {
+		return errors.New("已在当前分线")
+	}
+	if loads[req.LineId-1] >= sceneline.Capacity() {
+		return errors.New("分线人数已满")
+	}
+	if m.isSceneDraining(sceneId) {
+		return errSceneDraining
+	}
+	logger.Infof("玩家: %d切换分线: %d, %d -> %d", user.Uid, sceneId, user.lineId, req.LineId)
+	return m.startTransfer(user.session, user, sceneId, req.LineId, 0, nil, false)
+}
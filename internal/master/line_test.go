@@ -0,0 +1,40 @@
+package master
+
+import (
+	"testing"
+
+	"github.com/lonng/nano/cluster/clusterpb"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineMember(t *testing.T) {
+	members := []*clusterpb.MemberInfo{
+		{ServiceAddr: ":1", Label: "scene:1,2"},
+		{ServiceAddr: ":2", Label: "scene:1#2"},
+	}
+	assert.Equal(t, ":1", lineMember(1, 0, members).ServiceAddr)
+	assert.Equal(t, ":1", lineMember(1, 3, members).ServiceAddr)
+	assert.Equal(t, ":2", lineMember(1, 2, members).ServiceAddr)
+	assert.Nil(t, lineMember(3, 1, members))
+}
+
+func TestChooseLine(t *testing.T) {
+	viper.Set("scene-line.lines", "1:3")
+	defer viper.Set("scene-line.lines", "")
+
+	m := NewManager()
+	add := func(uid int64, sceneId int, lineId int) {
+		user := addTestUser(m, uid)
+		user.heroData.SceneId, user.lineId = sceneId, lineId
+	}
+	assert.Equal(t, 1, m.chooseLine(1))
+	add(1, 1, 1)
+	add(2, 1, 0)
+	add(3, 1, 2)
+	add(4, 2, 3)
+	assert.Equal(t, []int{2, 1, 0}, m.lineLoads(1))
+	assert.Equal(t, 3, m.chooseLine(1))
+	// 没有配置分线的场景只有1条线
+	assert.Equal(t, 1, m.chooseLine(2))
+}
@@ -9,20 +9,29 @@ import (
 	"github.com/lonng/nano/scheduler"
 	"github.com/nano/gameserver/constants"
 	"github.com/nano/gameserver/db"
-	"github.com/nano/gameserver/db/model"
 	"github.com/nano/gameserver/pkg/async"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/pkg/wordfilter"
 	"github.com/nano/gameserver/protocol"
 
 	"github.com/lonng/nano"
 	"github.com/lonng/nano/component"
 	"github.com/lonng/nano/session"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
-const kickResetBacklog = 8
+const (
+	kickResetBacklog = 8
+	// game节点下线倒计时结束后还需要保存数据, 多等待一段时间
+	drainGraceSeconds = 60
+)
 
 var defaultManager = NewManager()
 
+var errSceneDraining = errors.New("场景维护中")
+
 type (
 	Manager struct {
 		component.Base
@@ -36,6 +45,24 @@ type (
 		chScene    chan int
 
 		scenesCount sync.Map
+		// 下线中的场景, sceneId -> 截止时间(毫秒), 只在handler线程访问
+		drainingScenes map[int]int64
+		// 切换场景中的玩家, uid做key, 只在handler线程访问
+		transfers   map[int64]*heroTransfer
+		transferSeq int64
+		// 运行中的副本, 只在handler线程访问
+		instances   map[int64]*sceneInstance
+		instanceSeq int64
+		// 队伍, 只在handler线程访问
+		parties      map[int64]*party
+		partySeq     int64
+		memberParty  map[int64]int64           // uid -> partyId
+		partyInvites map[int64]map[int64]int64 // 被邀请的uid -> 邀请人uid -> 截止时间(毫秒)
+		// 聊天, 只在handler线程访问
+		chatLimiters map[int64]map[int]*chatLimiter // uid -> 频道 -> 发送记录
+		chatHistory  map[chatKey][]protocol.ChatMessage
+		chatMutes    map[int64]int64 // uid -> 禁言截止时间(毫秒)
+		chatFilter   *wordfilter.Filter
 	}
 
 	RechargeInfo struct {
@@ -52,6 +79,21 @@ func NewManager() *Manager {
 		chReset:    make(chan int64, kickResetBacklog),
 		chRecharge: make(chan RechargeInfo, 32),
 		chScene:    make(chan int, 32),
+
+		drainingScenes: map[int]int64{},
+		transfers:      map[int64]*heroTransfer{},
+		instances:      map[int64]*sceneInstance{},
+		// 重启后副本id不会和之前的重复
+		instanceSeq: time.Now().UnixMilli(),
+		parties:     map[int64]*party{},
+		// 重启后队伍id不会和之前的重复, 避免读到之前队伍的聊天记录
+		partySeq:     time.Now().UnixMilli(),
+		memberParty:  map[int64]int64{},
+		partyInvites: map[int64]map[int64]int64{},
+		chatLimiters: map[int64]map[int]*chatLimiter{},
+		chatHistory:  map[chatKey][]protocol.ChatMessage{},
+		chatMutes:    map[int64]int64{},
+		chatFilter:   wordfilter.New(nil),
 	}
 }
 
@@ -93,6 +135,13 @@ func (m *Manager) AfterInit() {
 				break ctrl
 			}
 		}
+		m.checkTransfers()
+	})
+	m.reloadChatFilter()
+	m.reloadChatMutes()
+	scheduler.NewTimer(CHAT_MUTE_RELOAD, m.reloadChatMutes)
+	scheduler.NewTimer(CHAT_LIMITER_EXPIRE, func() {
+		m.expireChatLimiters(time.Now().UnixMilli())
 	})
 	// 每60S更新一次场景统计
 	scheduler.NewTimer(60*time.Second, func() {
@@ -135,6 +184,9 @@ func (m *Manager) ChooseHero(s *session.Session, req *protocol.ChooseHeroRequest
 	if err != nil {
 		return errors.New("英雄不存在")
 	}
+	if m.isSceneDraining(heroData.SceneId) {
+		return errSceneDraining
+	}
 	// 绑定新session
 	user.session = s
 	user.heroData = heroData
@@ -146,8 +198,20 @@ func (m *Manager) ChooseHero(s *session.Session, req *protocol.ChooseHeroRequest
 	if sceneId == 0 {
 		sceneId = constants.DEFAULT_SCENE
 	}
+	// 下线时在副本内的, 副本还在时回到副本
+	sceneId, instanceId := m.resumeInstance(user, sceneId)
+	heroData.SceneId = sceneId
+	user.instanceId = instanceId
+	lineId := 0
+	if instanceId == 0 {
+		lineId = m.chooseLine(sceneId)
+	}
+	user.lineId = lineId
 	// todo 切换场景时需要记录这个值
 	s.Set("sceneId", sceneId)
+	bindLine(s, lineId)
+	bindInstance(s, instanceId)
+	bindCell(s, sceneId, heroData.InitPosx)
 	err = s.RPC("GateService.RecordScene", &protocol.UserSceneId{
 		Uid:     uid,
 		SceneId: sceneId,
@@ -156,13 +220,22 @@ func (m *Manager) ChooseHero(s *session.Session, req *protocol.ChooseHeroRequest
 		logger.Errorf("rpc.Call(GateService.RecordScene) err: %v \n", err)
 	}
 
+	m.refreshPartyMember(user)
+	m.loadGuild(user)
+	m.loadFriends(user)
+	m.notifyPresence(user)
 	err = s.RPC("SceneManager.HeroEnterScene", &protocol.HeroEnterSceneRequest{
-		SceneId:  sceneId,
-		HeroData: heroData,
+		SceneId:    sceneId,
+		HeroData:   heroData,
+		InstanceId: instanceId,
+		LineId:     lineId,
+		Party:      m.partyInfo(m.partyOf(uid)),
+		Guild:      heroGuild(user),
 	})
 	if err != nil {
 		logger.Errorf("rpc.Call(SceneManager.HeroEnterScene) err: %v \n", err)
 	}
+	m.pushChatHistory(user)
 	return err
 }
 
@@ -201,6 +274,9 @@ func (m *Manager) CreateHero(s *session.Session, req *protocol.CreateHeroRequest
 	//} else {
 	//	sceneId = constants.DEFAULT_SCENE2
 	//}
+	if m.isSceneDraining(sceneId) {
+		return errSceneDraining
+	}
 	heroData := createRandomHero(uid, sceneId, req.Name, req.Avatar, req.AttrType)
 	id, err := db.InsertHero(heroData)
 	if err != nil {
@@ -211,6 +287,7 @@ func (m *Manager) CreateHero(s *session.Session, req *protocol.CreateHeroRequest
 	// 绑定新session
 	user.session = s
 	user.heroData = heroData
+	user.instanceId = 0
 	// 添加到广播频道
 	m.group.Add(s)
 
@@ -224,12 +301,15 @@ func (m *Manager) CreateHero(s *session.Session, req *protocol.CreateHeroRequest
 		//	sceneId = constants.DEFAULT_SCENE2
 		//}
 	}
+	user.lineId = m.chooseLine(sceneId)
 	res := &protocol.ChooseHeroResponse{
 		Hero: *heroData,
 	}
 	s.Response(res)
 	// todo 切换场景时需要记录这个值
 	s.Set("sceneId", sceneId)
+	bindLine(s, user.lineId)
+	bindCell(s, sceneId, heroData.InitPosx)
 	err = s.RPC("GateService.RecordScene", &protocol.UserSceneId{
 		Uid:     uid,
 		SceneId: sceneId,
@@ -238,59 +318,94 @@ func (m *Manager) CreateHero(s *session.Session, req *protocol.CreateHeroRequest
 		logger.Errorf("rpc.Call(GateService.RecordScene) err: %v \n", err)
 	}
 
+	m.refreshPartyMember(user)
+	m.loadGuild(user)
+	m.loadFriends(user)
+	m.notifyPresence(user)
 	err = s.RPC("SceneManager.HeroEnterScene", &protocol.HeroEnterSceneRequest{
 		SceneId:  sceneId,
 		HeroData: heroData,
+		LineId:   user.lineId,
+		Party:    m.partyInfo(m.partyOf(uid)),
+		Guild:    heroGuild(user),
 	})
 	if err != nil {
 		logger.Errorf("rpc.Call(SceneManager.HeroEnterScene) err: %v \n", err)
 	}
+	m.pushChatHistory(user)
 	return err
 }
 
+// 客户端直接指定场景切换, 只在调试模式下开放, 正式环境通过传送门切换
 func (m *Manager) HeroChangeScene(s *session.Session, req *protocol.HeroChangeSceneRequest) error {
+	if !viper.GetBool("core.debug") {
+		return errutil.ErrPermissionDenied
+	}
 	uid := req.Uid
 	log.Infof("玩家: %d切换场景: %+v", req.Uid, req)
-	sceneId := req.SceneId
 	user, ok := m.player(uid)
 	if !ok {
 		str := fmt.Sprintf("玩家: %d不在线", uid)
 		return errors.New(str)
 	}
-	oldSceneId := user.heroData.SceneId
-	if sceneId == oldSceneId {
-		return errors.New("已在当前场景")
+	return m.changeScene(s, user, req.SceneId, nil)
+}
+
+// game节点检测到hero走进了传送门
+func (m *Manager) HeroEnterDoor(s *session.Session, req *protocol.HeroEnterDoorRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("玩家: %d进入传送门签名错误: %+v", req.Uid, req)
+		return errutil.ErrPermissionDenied
 	}
-	// 离开上一个场景
-	err := s.RPC("SceneManager.HeroLeaveScene", &protocol.HeroLeaveSceneRequest{
-		SceneId: oldSceneId,
-		HeroId:  user.heroData.Id,
-	})
+	user, ok := m.player(req.Uid)
+	if !ok || user.heroData == nil || user.heroData.Id != req.HeroId {
+		return fmt.Errorf("玩家: %d不在线", req.Uid)
+	}
+	if user.heroData.SceneId != req.SceneId {
+		return errors.New("不在传送门所在的场景")
+	}
+	door, err := db.QuerySceneDoor(req.DoorId)
 	if err != nil {
 		return err
 	}
-	//进入新场景
-	user.heroData.SceneId = sceneId
-	s.Router().Delete("SceneManager")
-	// todo 切换场景时需要记录这个值
-	s.Set("sceneId", sceneId)
-	err = s.RPC("GateService.RecordScene", &protocol.UserSceneId{
-		Uid:     uid,
-		SceneId: sceneId,
+	if door.SceneId != req.SceneId || door.TargetSceneId <= 0 {
+		return errors.New("传送门配置错误")
+	}
+	log.Infof("玩家: %d通过传送门:%d-%s进入场景:%d", req.Uid, door.Id, door.Name, door.TargetSceneId)
+	return m.changeScene(s, user, door.TargetSceneId, &coord.Vector3{
+		X: coord.Coord(door.DestPosx),
+		Y: coord.Coord(door.DestPosy),
+		Z: coord.Coord(door.DestPosz),
 	})
-	if err != nil {
-		logger.Errorf("rpc.Call(GateService.RecordScene) err: %v \n", err)
+}
+
+// game节点下线前通知, 截止时间之前不再分配hero到这些场景
+func (m *Manager) GameNodeDraining(s *session.Session, req *protocol.GameNodeDrainingRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("game节点下线通知签名错误: %+v", req)
+		return errutil.ErrPermissionDenied
+	}
+	deadline := time.Now().Add(time.Duration(req.Seconds+drainGraceSeconds) * time.Second).UnixMilli()
+	for _, sceneId := range req.SceneIds {
+		m.drainingScenes[sceneId] = deadline
 	}
+	logger.Infof("game节点下线中, 场景:%v, 倒计时:%d秒", req.SceneIds, req.Seconds)
+	return nil
+}
 
-	err = s.RPC("SceneManager.HeroEnterScene", &protocol.HeroEnterSceneRequest{
-		SceneId:  sceneId,
-		HeroData: user.heroData,
-	})
-	if err != nil {
-		logger.Errorf("rpc.Call(SceneManager.HeroEnterScene) err: %v \n", err)
+func (m *Manager) isSceneDraining(sceneId int) bool {
+	if sceneId == 0 {
+		sceneId = constants.DEFAULT_SCENE
 	}
-	err = db.UpdateHero(&model.Hero{Id: user.heroData.Id, SceneId: user.heroData.SceneId})
-	return err
+	deadline, ok := m.drainingScenes[sceneId]
+	if !ok {
+		return false
+	}
+	if time.Now().UnixMilli() >= deadline {
+		delete(m.drainingScenes, sceneId)
+		return false
+	}
+	return true
 }
 
 func (m *Manager) player(uid int64) (*User, bool) {
@@ -310,11 +425,18 @@ func (m *Manager) addPlayer(user *User) {
 }
 
 func (m *Manager) removePlayer(uid int64) {
-	_, ok := defaultManager.player(uid)
+	user, ok := defaultManager.player(uid)
 	if !ok {
 		return
 	}
+	if user.session != nil {
+		m.notifyOffline(user)
+	}
 	delete(m.players, uid)
+	delete(m.transfers, uid)
+	delete(m.partyInvites, uid)
+	delete(m.chatHistory, chatKey{constants.CHAT_CHANNEL_PRIVATE, uid})
+	m.partyMemberOffline(uid)
 	log.Infof("玩家: %d从在线列表中删除, 剩余：%d", uid, len(m.players))
 	logger.Infof("删除玩家, UID=%d", uid)
 }
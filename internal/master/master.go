@@ -6,6 +6,8 @@ import (
 	"github.com/lonng/nano/cluster/clusterpb"
 	"github.com/lonng/nano/serialize/json"
 	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/pkg/algoutil"
+	"github.com/nano/gameserver/pkg/voicestore"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"strings"
@@ -17,6 +19,14 @@ var (
 
 // Startup 初始化master服务器
 func Startup() {
+	// 签名密钥没有配置时任何客户端都可以伪造节点之间的请求
+	if err := algoutil.CheckClusterSecret(viper.GetString("cluster.secret")); err != nil {
+		panic(err)
+	}
+	// 语音上传的token由master签发
+	if err := voicestore.CheckTokenKey(); err != nil {
+		panic(err)
+	}
 
 	version := viper.GetString("update.version")
 	heartbeat := viper.GetInt("core.heartbeat")
@@ -43,20 +53,29 @@ func Startup() {
 // 集群模式下，需要获取用户所在的game node调用rpc
 func customerRemoteServiceRoute(service string, session *session.Session, members []*clusterpb.MemberInfo) *clusterpb.MemberInfo {
 	if strings.Contains(service, "SceneManager") {
-		//根据用户id获取用户在哪个node上
+		//副本按创建时分配的节点路由
+		instanceId := session.Int64("instanceId")
+		if instanceId > 0 {
+			if m := instanceMember(instanceId, members); m != nil {
+				return m
+			}
+		}
+		//大地图按hero所在的cell路由
+		if addr := session.String("cellAddr"); addr != "" {
+			for _, m := range members {
+				if m.ServiceAddr == addr {
+					return m
+				}
+			}
+		}
+		//根据用户所在的场景和分线获取在哪个node上
 		curSceneId := session.Int("sceneId")
 		if curSceneId > 0 {
-			for _, m := range members {
-				label := m.Label
-				if label != "" {
-					label = strings.ReplaceAll(label, "scene:", "")
-					tmpArr := strings.Split(label, ",")
-					for _, tmp := range tmpArr {
-						if tmp == fmt.Sprintf("%d", curSceneId) {
-							return m
-						}
-					}
+			if m := lineMember(curSceneId, session.Int("lineId"), members); m != nil {
+				if instanceId > 0 {
+					instanceNodes.Store(instanceId, m.ServiceAddr)
 				}
+				return m
 			}
 		}
 	}
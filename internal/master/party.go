@@ -0,0 +1,364 @@
+package master
+
+// 组队, 流程见protocol/party.go
+// 队伍只保存在master内存中, 队员下线后保留在队伍内, 所有队员都下线后解散
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 队伍的人数上限
+	PARTY_MAX_SIZE = 5
+	// 邀请的有效时间(毫秒)
+	PARTY_INVITE_TIMEOUT = 60 * 1000
+)
+
+var (
+	errNotInParty   = errors.New("不在队伍内")
+	errNotLeader    = errors.New("不是队长")
+	errPartyFull    = errors.New("队伍人数已满")
+	errAlreadyParty = errors.New("已经在队伍内")
+)
+
+type partyMember struct {
+	uid    int64
+	heroId int64
+	name   string
+}
+
+type party struct {
+	id        int64
+	leaderUid int64
+	lootMode  int
+	members   []*partyMember //按加入的顺序, 队长离开后由下一个接任
+}
+
+func (p *party) member(uid int64) *partyMember {
+	for _, pm := range p.members {
+		if pm.uid == uid {
+			return pm
+		}
+	}
+	return nil
+}
+
+func (p *party) uids() []int64 {
+	uids := make([]int64, 0, len(p.members))
+	for _, pm := range p.members {
+		uids = append(uids, pm.uid)
+	}
+	return uids
+}
+
+func newPartyMember(user *User) *partyMember {
+	pm := &partyMember{uid: user.Uid}
+	if user.heroData != nil {
+		pm.heroId, pm.name = user.heroData.Id, user.heroData.Name
+	}
+	return pm
+}
+
+func (m *Manager) partyOf(uid int64) *party {
+	return m.parties[m.memberParty[uid]]
+}
+
+func (m *Manager) onlineUser(uid int64) (*User, bool) {
+	user, ok := m.player(uid)
+	if !ok || user.session == nil || user.heroData == nil {
+		return nil, false
+	}
+	return user, true
+}
+
+func (m *Manager) partyInfo(p *party) *protocol.PartyInfo {
+	if p == nil {
+		return nil
+	}
+	info := &protocol.PartyInfo{
+		PartyId:   p.id,
+		LeaderUid: p.leaderUid,
+		LootMode:  p.lootMode,
+		Members:   make([]protocol.PartyMember, 0, len(p.members)),
+	}
+	for _, pm := range p.members {
+		item := protocol.PartyMember{Uid: pm.uid, HeroId: pm.heroId, Name: pm.name}
+		if user, ok := m.onlineUser(pm.uid); ok {
+			item.Online = true
+			item.Level = user.heroData.Level
+			item.SceneId = user.heroData.SceneId
+		}
+		info.Members = append(info.Members, item)
+	}
+	return info
+}
+
+// 推送给在线的队员, 队员切换场景和上下线时只需要更新客户端
+func (m *Manager) pushParty(p *party) {
+	res := &protocol.PartyChangedResponse{Party: m.partyInfo(p)}
+	for _, pm := range p.members {
+		if user, ok := m.onlineUser(pm.uid); ok {
+			user.session.Push(protocol.OnPartyChanged, res)
+		}
+	}
+}
+
+// 队员和分配方式变化时还需要通知队员所在的game节点
+func (m *Manager) notifyParty(p *party) {
+	m.pushParty(p)
+	info := m.partyInfo(p)
+	for _, pm := range p.members {
+		if user, ok := m.onlineUser(pm.uid); ok {
+			m.sendHeroParty(user, info)
+		}
+	}
+}
+
+func (m *Manager) notifyLeftParty(uid int64) {
+	user, ok := m.onlineUser(uid)
+	if !ok {
+		return
+	}
+	user.session.Push(protocol.OnPartyChanged, &protocol.PartyChangedResponse{})
+	m.sendHeroParty(user, nil)
+}
+
+func (m *Manager) sendHeroParty(user *User, info *protocol.PartyInfo) {
+	req := &protocol.HeroPartyChangedRequest{
+		HeroId: user.heroData.Id,
+		Party:  info,
+	}
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+	if err := user.session.RPC("SceneManager.HeroPartyChanged", req); err != nil {
+		logger.Errorf("rpc.Call(SceneManager.HeroPartyChanged) err: %v", err)
+	}
+}
+
+func (m *Manager) removeFromParty(p *party, uid int64) {
+	for i, pm := range p.members {
+		if pm.uid == uid {
+			p.members = append(p.members[:i], p.members[i+1:]...)
+			break
+		}
+	}
+	delete(m.memberParty, uid)
+	m.notifyLeftParty(uid)
+	if len(p.members) < 2 {
+		m.disbandParty(p)
+		return
+	}
+	if p.leaderUid == uid {
+		p.leaderUid = p.members[0].uid
+	}
+	m.notifyParty(p)
+}
+
+func (m *Manager) disbandParty(p *party) {
+	delete(m.parties, p.id)
+	delete(m.chatHistory, chatKey{constants.CHAT_CHANNEL_PARTY, p.id})
+	for _, pm := range p.members {
+		delete(m.memberParty, pm.uid)
+		m.notifyLeftParty(pm.uid)
+	}
+	logger.Infof("队伍:%d解散", p.id)
+}
+
+// 选择英雄进入游戏时更新队伍内的英雄
+func (m *Manager) refreshPartyMember(user *User) {
+	p := m.partyOf(user.Uid)
+	if p == nil {
+		return
+	}
+	if pm := p.member(user.Uid); pm != nil && user.heroData != nil && pm.heroId != user.heroData.Id {
+		pm.heroId, pm.name = user.heroData.Id, user.heroData.Name
+		m.notifyParty(p)
+		return
+	}
+	m.pushParty(p)
+}
+
+// 下线后保留在队伍内, 所有人都下线后解散
+func (m *Manager) partyMemberOffline(uid int64) {
+	p := m.partyOf(uid)
+	if p == nil {
+		return
+	}
+	for _, pm := range p.members {
+		if _, ok := m.onlineUser(pm.uid); ok {
+			m.pushParty(p)
+			return
+		}
+	}
+	m.disbandParty(p)
+}
+
+func (m *Manager) partyUser(s *session.Session) (*User, error) {
+	user, ok := m.onlineUser(s.UID())
+	if !ok {
+		return nil, fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	return user, nil
+}
+
+func (m *Manager) PartyInvite(s *session.Session, req *protocol.PartyInviteRequest) error {
+	user, err := m.partyUser(s)
+	if err != nil {
+		return err
+	}
+	if req.Uid == user.Uid {
+		return errors.New("不能邀请自己")
+	}
+	target, ok := m.onlineUser(req.Uid)
+	if !ok {
+		return fmt.Errorf("玩家: %d不在线", req.Uid)
+	}
+	if m.partyOf(target.Uid) != nil {
+		return errAlreadyParty
+	}
+	if p := m.partyOf(user.Uid); p != nil {
+		if p.leaderUid != user.Uid {
+			return errNotLeader
+		}
+		if len(p.members) >= PARTY_MAX_SIZE {
+			return errPartyFull
+		}
+	}
+	expireAt := time.Now().UnixMilli() + PARTY_INVITE_TIMEOUT
+	if m.partyInvites[target.Uid] == nil {
+		m.partyInvites[target.Uid] = map[int64]int64{}
+	}
+	m.partyInvites[target.Uid][user.Uid] = expireAt
+	target.session.Push(protocol.OnPartyInvite, &protocol.PartyInviteResponse{
+		InviterUid:  user.Uid,
+		InviterName: user.heroData.Name,
+		ExpireAt:    expireAt,
+	})
+	return nil
+}
+
+func (m *Manager) PartyAccept(s *session.Session, req *protocol.PartyAcceptRequest) error {
+	user, err := m.partyUser(s)
+	if err != nil {
+		return err
+	}
+	expireAt, ok := m.partyInvites[user.Uid][req.InviterUid]
+	delete(m.partyInvites[user.Uid], req.InviterUid)
+	if len(m.partyInvites[user.Uid]) == 0 {
+		delete(m.partyInvites, user.Uid)
+	}
+	if !ok || time.Now().UnixMilli() > expireAt {
+		return errors.New("邀请已过期")
+	}
+	if m.partyOf(user.Uid) != nil {
+		return errAlreadyParty
+	}
+	inviter, ok := m.onlineUser(req.InviterUid)
+	if !ok {
+		return fmt.Errorf("玩家: %d不在线", req.InviterUid)
+	}
+	p := m.partyOf(inviter.Uid)
+	if p == nil {
+		m.partySeq++
+		p = &party{
+			id:        m.partySeq,
+			leaderUid: inviter.Uid,
+			lootMode:  constants.PARTY_LOOT_FREE,
+			members:   []*partyMember{newPartyMember(inviter)},
+		}
+		m.parties[p.id] = p
+		m.memberParty[inviter.Uid] = p.id
+		logger.Infof("玩家: %d创建队伍:%d", inviter.Uid, p.id)
+	}
+	if len(p.members) >= PARTY_MAX_SIZE {
+		return errPartyFull
+	}
+	p.members = append(p.members, newPartyMember(user))
+	m.memberParty[user.Uid] = p.id
+	logger.Infof("玩家: %d加入队伍:%d", user.Uid, p.id)
+	m.notifyParty(p)
+	return nil
+}
+
+func (m *Manager) PartyLeave(s *session.Session, req *protocol.EmptyRequest) error {
+	user, err := m.partyUser(s)
+	if err != nil {
+		return err
+	}
+	p := m.partyOf(user.Uid)
+	if p == nil {
+		return errNotInParty
+	}
+	logger.Infof("玩家: %d离开队伍:%d", user.Uid, p.id)
+	m.removeFromParty(p, user.Uid)
+	return nil
+}
+
+func (m *Manager) PartyKick(s *session.Session, req *protocol.PartyKickRequest) error {
+	user, err := m.partyUser(s)
+	if err != nil {
+		return err
+	}
+	p := m.partyOf(user.Uid)
+	if p == nil {
+		return errNotInParty
+	}
+	if p.leaderUid != user.Uid {
+		return errNotLeader
+	}
+	if req.Uid == user.Uid || p.member(req.Uid) == nil {
+		return errors.New("队员不存在")
+	}
+	logger.Infof("队伍:%d 队长: %d踢出玩家: %d", p.id, user.Uid, req.Uid)
+	m.removeFromParty(p, req.Uid)
+	return nil
+}
+
+func (m *Manager) PartyTransferLeader(s *session.Session, req *protocol.PartyTransferLeaderRequest) error {
+	user, err := m.partyUser(s)
+	if err != nil {
+		return err
+	}
+	p := m.partyOf(user.Uid)
+	if p == nil {
+		return errNotInParty
+	}
+	if p.leaderUid != user.Uid {
+		return errNotLeader
+	}
+	if req.Uid == user.Uid || p.member(req.Uid) == nil {
+		return errors.New("队员不存在")
+	}
+	p.leaderUid = req.Uid
+	m.notifyParty(p)
+	return nil
+}
+
+func (m *Manager) PartySetLootMode(s *session.Session, req *protocol.PartySetLootModeRequest) error {
+	user, err := m.partyUser(s)
+	if err != nil {
+		return err
+	}
+	p := m.partyOf(user.Uid)
+	if p == nil {
+		return errNotInParty
+	}
+	if p.leaderUid != user.Uid {
+		return errNotLeader
+	}
+	if req.LootMode != constants.PARTY_LOOT_FREE && req.LootMode != constants.PARTY_LOOT_ROUND {
+		return errors.New("分配方式错误")
+	}
+	p.lootMode = req.LootMode
+	m.notifyParty(p)
+	return nil
+}
+
+func (m *Manager) PartyInfo(s *session.Session, req *protocol.EmptyRequest) error {
+	return s.Response(&protocol.PartyChangedResponse{Party: m.partyInfo(m.partyOf(s.UID()))})
+}
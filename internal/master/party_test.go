@@ -0,0 +1,113 @@
+package master
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nano/gameserver/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartyInviteAcceptKick(t *testing.T) {
+	m := NewManager()
+	u1, u2, u3 := addTestUser(m, 1), addTestUser(m, 2), addTestUser(m, 3)
+
+	assert.NotNil(t, m.PartyInvite(u1.session, &protocol.PartyInviteRequest{Uid: 1}))
+	assert.Nil(t, m.PartyInvite(u1.session, &protocol.PartyInviteRequest{Uid: 2}))
+	assert.Equal(t, []string{protocol.OnPartyInvite}, testEntityOf(u2).pushes)
+	// 没有被邀请
+	assert.NotNil(t, m.PartyAccept(u2.session, &protocol.PartyAcceptRequest{InviterUid: 3}))
+
+	// 接受邀请时创建队伍, 通知客户端和game节点
+	assert.Nil(t, m.PartyAccept(u2.session, &protocol.PartyAcceptRequest{InviterUid: 1}))
+	p := m.partyOf(1)
+	assert.NotNil(t, p)
+	assert.Equal(t, int64(1), p.leaderUid)
+	assert.Equal(t, []int64{1, 2}, p.uids())
+	assert.Contains(t, testEntityOf(u1).pushes, protocol.OnPartyChanged)
+	assert.Contains(t, testEntityOf(u2).rpcs, "SceneManager.HeroPartyChanged")
+	// 已经在队伍内的不能再被邀请, 邀请只能用一次
+	assert.Equal(t, errAlreadyParty, m.PartyInvite(u3.session, &protocol.PartyInviteRequest{Uid: 2}))
+	assert.NotNil(t, m.PartyAccept(u2.session, &protocol.PartyAcceptRequest{InviterUid: 1}))
+
+	// 只有队长可以邀请和踢人
+	assert.Equal(t, errNotLeader, m.PartyInvite(u2.session, &protocol.PartyInviteRequest{Uid: 3}))
+	assert.Nil(t, m.PartyInvite(u1.session, &protocol.PartyInviteRequest{Uid: 3}))
+	assert.Nil(t, m.PartyAccept(u3.session, &protocol.PartyAcceptRequest{InviterUid: 1}))
+	assert.Equal(t, []int64{1, 2, 3}, p.uids())
+	assert.Equal(t, errNotLeader, m.PartyKick(u2.session, &protocol.PartyKickRequest{Uid: 3}))
+
+	resetTestEntity(u1, u2, u3)
+	assert.Nil(t, m.PartyKick(u1.session, &protocol.PartyKickRequest{Uid: 3}))
+	assert.Equal(t, []int64{1, 2}, p.uids())
+	assert.Nil(t, m.partyOf(3))
+	assert.Equal(t, []string{protocol.OnPartyChanged}, testEntityOf(u3).pushes)
+	assert.Equal(t, []string{"SceneManager.HeroPartyChanged"}, testEntityOf(u3).rpcs)
+
+	// 少于2人时解散
+	assert.Nil(t, m.PartyKick(u1.session, &protocol.PartyKickRequest{Uid: 2}))
+	assert.Nil(t, m.partyOf(1))
+	assert.Empty(t, m.parties)
+}
+
+func TestRemoveFromParty(t *testing.T) {
+	m := NewManager()
+	p := &party{
+		id:        1,
+		leaderUid: 1,
+		members:   []*partyMember{{uid: 1}, {uid: 2}, {uid: 3}},
+	}
+	m.parties[p.id] = p
+	for _, pm := range p.members {
+		m.memberParty[pm.uid] = p.id
+	}
+	assert.Equal(t, []int64{1, 2, 3}, m.instanceMembers(&User{Uid: 2}))
+
+	// 队长离开后由下一个接任
+	m.removeFromParty(p, 1)
+	assert.Equal(t, int64(2), p.leaderUid)
+	assert.Nil(t, m.partyOf(1))
+	assert.Equal(t, []int64{2, 3}, p.uids())
+
+	// 少于2人时解散
+	m.removeFromParty(p, 3)
+	assert.Nil(t, m.partyOf(2))
+	assert.Empty(t, m.parties)
+	assert.Empty(t, m.memberParty)
+	assert.Equal(t, []int64{2}, m.instanceMembers(&User{Uid: 2}))
+}
+
+func TestHeroPartyChangedSign(t *testing.T) {
+	secret := "test-secret"
+	req := &protocol.HeroPartyChangedRequest{
+		HeroId: 1,
+		Party: &protocol.PartyInfo{
+			PartyId:   1,
+			LeaderUid: 1,
+			Members:   []protocol.PartyMember{{Uid: 1}, {Uid: 2}},
+		},
+	}
+	req.Sign = req.SignWith(secret)
+	data, err := json.Marshal(req)
+	assert.Nil(t, err)
+	received := &protocol.HeroPartyChangedRequest{}
+	assert.Nil(t, json.Unmarshal(data, received))
+	assert.Equal(t, received.Sign, received.SignWith(secret))
+
+	// 队员和分配方式都在签名内
+	received.Party.LootMode = 1
+	assert.NotEqual(t, received.Sign, received.SignWith(secret))
+	received.Party.LootMode = 0
+	received.Party.Members = append(received.Party.Members, protocol.PartyMember{Uid: 3})
+	assert.NotEqual(t, received.Sign, received.SignWith(secret))
+
+	guild := &protocol.HeroGuildChangedRequest{HeroId: 1, Guild: &protocol.HeroGuild{GuildId: 1, Name: "a"}}
+	guild.Sign = guild.SignWith(secret)
+	guild.Guild.Name = "b"
+	assert.NotEqual(t, guild.Sign, guild.SignWith(secret))
+
+	migrate := &protocol.HeroCellMigrateRequest{Uid: 1, HeroId: 1, SceneId: 1, PosX: 10, PosY: 20}
+	migrate.Sign = migrate.SignWith(secret)
+	migrate.PosY = 30
+	assert.NotEqual(t, migrate.Sign, migrate.SignWith(secret))
+}
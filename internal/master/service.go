@@ -11,4 +11,5 @@ var (
 
 func init() {
 	Services.Register(defaultManager)
+	Services.Register(defaultCellManager)
 }
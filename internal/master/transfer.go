@@ -0,0 +1,244 @@
+package master
+
+// hero切换场景的握手, 流程见protocol/transfer.go
+// 旧场景移除hero之后才会进入新场景, 任何一步超时都回到原来的场景, 保证hero不会同时在两个场景或者不在任何场景
+import (
+	"errors"
+	"time"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/pkg/coord"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	TRANSFER_LEAVING  = iota //等待旧场景移除hero
+	TRANSFER_ENTERING        //等待新场景加入hero
+
+	// 每一步的超时时间(毫秒)
+	TRANSFER_TIMEOUT = 5000
+)
+
+var errTransferring = errors.New("切换场景中")
+
+type heroTransfer struct {
+	id          int64
+	phase       int
+	fromSceneId int
+	fromPos     coord.Vector3 //离开时的位置, 失败时回到这里
+	toSceneId   int
+	destPos     *coord.Vector3
+	state       *protocol.HeroState
+	deadline    int64
+	cellMigrate bool   //同一个场景内迁移cell
+	fromCell    string //离开时所在的cell节点, 不是大地图时为空
+	// 离开和进入的副本, 不是副本时为0
+	fromInstance int64
+	toInstance   int64
+	// 离开和进入的分线, toLine为0时进入前选择人数最少的线
+	fromLine int
+	toLine   int
+}
+
+// destPos为空时使用目标场景的出生点
+func (m *Manager) changeScene(s *session.Session, user *User, sceneId int, destPos *coord.Vector3) error {
+	oldSceneId := user.heroData.SceneId
+	if sceneId == oldSceneId && user.instanceId == 0 {
+		return errors.New("已在当前场景")
+	}
+	if isInstanceScene(sceneId) {
+		// 副本模板每次进入都创建新的副本
+		return m.enterInstance(s, user, sceneId)
+	}
+	if m.isSceneDraining(sceneId) {
+		return errSceneDraining
+	}
+	return m.startTransfer(s, user, sceneId, 0, 0, destPos, false)
+}
+
+// instanceId不为0时进入副本
+func (m *Manager) startTransfer(s *session.Session, user *User, sceneId int, lineId int, instanceId int64, destPos *coord.Vector3, cellMigrate bool) error {
+	if _, ok := m.transfers[user.Uid]; ok {
+		return errTransferring
+	}
+	oldSceneId := user.heroData.SceneId
+	m.transferSeq++
+	t := &heroTransfer{
+		id:          m.transferSeq,
+		phase:       TRANSFER_LEAVING,
+		fromSceneId: oldSceneId,
+		toSceneId:   sceneId,
+		destPos:     destPos,
+		deadline:    time.Now().UnixMilli() + TRANSFER_TIMEOUT,
+		cellMigrate: cellMigrate,
+		fromCell:    s.String("cellAddr"),
+
+		fromInstance: user.instanceId,
+		toInstance:   instanceId,
+		fromLine:     user.lineId,
+		toLine:       lineId,
+	}
+	m.transfers[user.Uid] = t
+	// 离开上一个场景
+	out := &protocol.HeroTransferOutRequest{
+		SceneId:     oldSceneId,
+		HeroId:      user.heroData.Id,
+		TransferId:  t.id,
+		CellMigrate: cellMigrate,
+		InstanceId:  t.fromInstance,
+		LineId:      t.fromLine,
+	}
+	out.Sign = out.SignWith(viper.GetString("cluster.secret"))
+	if err := s.RPC("SceneManager.HeroTransferOut", out); err != nil {
+		delete(m.transfers, user.Uid)
+		return err
+	}
+	return nil
+}
+
+// 旧场景已经移除了hero
+func (m *Manager) HeroTransferReady(s *session.Session, req *protocol.HeroTransferReadyRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) || req.HeroData == nil {
+		logger.Warningf("玩家: %d切换场景签名错误: %+v", req.Uid, req)
+		return errutil.ErrPermissionDenied
+	}
+	user, ok := m.player(req.Uid)
+	if !ok {
+		return errors.New("玩家不在线")
+	}
+	t, ok := m.transfers[req.Uid]
+	if !ok || t.id != req.TransferId || t.phase != TRANSFER_LEAVING {
+		// 已经超时回到了原来的场景
+		logger.Warningf("玩家: %d切换场景:%d已失效", req.Uid, req.TransferId)
+		return errors.New("transfer not found")
+	}
+	// 以game节点保存的数据为准
+	user.heroData = req.HeroData
+	t.phase = TRANSFER_ENTERING
+	t.state = req.State
+	t.fromPos = coord.Vector3{X: coord.Coord(req.HeroData.InitPosx), Y: coord.Coord(req.HeroData.InitPosy), Z: coord.Coord(req.HeroData.InitPosz)}
+	t.deadline = time.Now().UnixMilli() + TRANSFER_TIMEOUT
+	if t.toLine == 0 && t.toInstance == 0 {
+		t.toLine = m.chooseLine(t.toSceneId)
+	}
+	if t.cellMigrate || (t.toSceneId == t.fromSceneId && t.toInstance == 0 && t.fromInstance == 0) {
+		// 迁移cell和切换分线时保持离开时的位置
+		pos := t.fromPos
+		t.destPos = &pos
+	}
+	if t.toInstance > 0 && t.fromInstance == 0 {
+		// 记录进入副本前的位置
+		pos := t.fromPos
+		user.returnSceneId, user.returnPos = t.fromSceneId, &pos
+	}
+	return m.enterScene(s, user, t.toSceneId, t.toLine, t.toInstance, t.destPos, t.state, t.id, t.cellMigrate, "")
+}
+
+// 新场景已经加入了hero
+func (m *Manager) HeroTransferDone(s *session.Session, req *protocol.HeroTransferDoneRequest) error {
+	if req.Sign != req.SignWith(viper.GetString("cluster.secret")) {
+		logger.Warningf("玩家: %d切换场景签名错误: %+v", req.Uid, req)
+		return errutil.ErrPermissionDenied
+	}
+	t, ok := m.transfers[req.Uid]
+	if !ok || t.id != req.TransferId {
+		return nil
+	}
+	delete(m.transfers, req.Uid)
+	logger.Infof("玩家: %d切换场景完成: %d -> %d", req.Uid, t.fromSceneId, req.SceneId)
+	return nil
+}
+
+// cellAddr为空时按进入的位置选择cell节点
+func (m *Manager) enterScene(s *session.Session, user *User, sceneId int, lineId int, instanceId int64, destPos *coord.Vector3, state *protocol.HeroState, transferId int64, cellMigrate bool, cellAddr string) error {
+	user.heroData.SceneId = sceneId
+	user.lineId = lineId
+	user.instanceId = instanceId
+	cols := []string{"scene_id"}
+	if destPos != nil {
+		user.heroData.InitPosx, user.heroData.InitPosy, user.heroData.InitPosz = int(destPos.X), int(destPos.Y), int(destPos.Z)
+		cols = append(cols, "init_posx", "init_posy", "init_posz")
+	}
+	s.Router().Delete("SceneManager")
+	// todo 切换场景时需要记录这个值
+	s.Set("sceneId", sceneId)
+	bindLine(s, lineId)
+	bindInstance(s, instanceId)
+	if cellAddr != "" {
+		s.Set("cellAddr", cellAddr)
+	} else {
+		bindCell(s, sceneId, user.heroData.InitPosx)
+	}
+	err := s.RPC("GateService.RecordScene", &protocol.UserSceneId{
+		Uid:     user.Uid,
+		SceneId: sceneId,
+	})
+	if err != nil {
+		logger.Errorf("rpc.Call(GateService.RecordScene) err: %v \n", err)
+	}
+
+	err = s.RPC("SceneManager.HeroEnterScene", &protocol.HeroEnterSceneRequest{
+		SceneId:     sceneId,
+		HeroData:    user.heroData,
+		DestPos:     destPos,
+		State:       state,
+		TransferId:  transferId,
+		CellMigrate: cellMigrate,
+		InstanceId:  instanceId,
+		LineId:      lineId,
+		Party:       m.partyInfo(m.partyOf(user.Uid)),
+		Guild:       heroGuild(user),
+	})
+	if err != nil {
+		logger.Errorf("rpc.Call(SceneManager.HeroEnterScene) err: %v \n", err)
+	}
+	if err := db.UpdateHeroCols(user.heroData, cols...); err != nil {
+		logger.Errorf("玩家: %d保存场景失败: %v", user.Uid, err)
+	}
+	// 队员看到的所在场景
+	if p := m.partyOf(user.Uid); p != nil {
+		m.pushParty(p)
+	}
+	m.notifyPresence(user)
+	return err
+}
+
+// 在timer内执行, 超时的回到原来的场景
+func (m *Manager) checkTransfers() {
+	now := time.Now().UnixMilli()
+	for uid, t := range m.transfers {
+		if now < t.deadline {
+			continue
+		}
+		delete(m.transfers, uid)
+		user, ok := m.player(uid)
+		if !ok || user.session == nil {
+			continue
+		}
+		logger.Warningf("玩家: %d切换场景超时: %d -> %d, 阶段:%d", uid, t.fromSceneId, t.toSceneId, t.phase)
+		pos := t.fromPos
+		cellAddr := ""
+		if t.phase == TRANSFER_LEAVING {
+			// hero可能还在原来的节点上, 需要回到原来的节点
+			cellAddr = t.fromCell
+			// 不确定旧场景是否已经移除了hero, 使用数据库的数据重新进入, hero还在场景内时不会重复加入
+			heroData, err := db.QueryHero(user.heroData.Id)
+			if err != nil {
+				logger.Errorf("玩家: %d切换场景超时, 查询hero失败: %v", uid, err)
+				continue
+			}
+			user.heroData = heroData
+			pos = coord.Vector3{X: coord.Coord(heroData.InitPosx), Y: coord.Coord(heroData.InitPosy), Z: coord.Coord(heroData.InitPosz)}
+		}
+		sceneId, lineId, instanceId, destPos := t.fromSceneId, t.fromLine, t.fromInstance, &pos
+		if _, ok := m.instances[instanceId]; instanceId > 0 && !ok {
+			// 原来的副本已经销毁了
+			sceneId, destPos = m.returnScene(user)
+			lineId, instanceId = m.chooseLine(sceneId), 0
+		}
+		m.enterScene(user.session, user, sceneId, lineId, instanceId, destPos, t.state, 0, false, cellAddr)
+	}
+}
@@ -0,0 +1,38 @@
+package master
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeroTransferReadySign(t *testing.T) {
+	viper.Set("cluster.secret", "test-secret")
+	defer viper.Set("cluster.secret", "")
+
+	req := &protocol.HeroTransferReadyRequest{
+		Uid:        1,
+		HeroId:     2,
+		TransferId: 3,
+		HeroData:   &model.Hero{Id: 2, Uid: 1, Level: 10, Experience: 500},
+		State:      &protocol.HeroState{Life: 100, SpellCdTimes: map[int]int{1: 500, 2: 0}},
+	}
+	req.Sign = req.SignWith(viper.GetString("cluster.secret"))
+
+	// 经过序列化传给master后签名不变
+	data, err := json.Marshal(req)
+	assert.Nil(t, err)
+	received := &protocol.HeroTransferReadyRequest{}
+	assert.Nil(t, json.Unmarshal(data, received))
+	assert.Equal(t, received.Sign, received.SignWith(viper.GetString("cluster.secret")))
+
+	// 篡改了hero数据
+	received.HeroData.Level = 99
+	assert.NotEqual(t, received.Sign, received.SignWith(viper.GetString("cluster.secret")))
+	assert.Equal(t, errutil.ErrPermissionDenied, NewManager().HeroTransferReady(nil, received))
+}
@@ -3,6 +3,7 @@ package master
 import (
 	"github.com/lonng/nano/session"
 	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/coord"
 )
 
 type User struct {
@@ -10,4 +11,18 @@ type User struct {
 	data     *model.User
 	Uid      int64
 	heroData *model.Hero
+
+	// 所在的分线, 0和1都是第1条线
+	lineId int
+	// 所在的副本, 不在副本内时为0
+	instanceId int64
+	// 进入副本前的场景, 离开副本时回到这里
+	returnSceneId int
+	returnPos     *coord.Vector3
+	// 当前hero所在的公会, 选择英雄时从数据库加载, 没有加入时为空
+	guild     *model.GuildMember
+	guildName string
+	// 好友和屏蔽的玩家, 进入游戏时从数据库加载
+	friends map[int64]bool
+	blocks  map[int64]bool
 }
@@ -0,0 +1,83 @@
+package master
+
+// 语音消息, 流程见protocol/voice.go
+// 语音文件由web服务保存, master只校验文件并按聊天频道转发, 不保存历史
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lonng/nano/session"
+	"github.com/nano/gameserver/constants"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/pkg/voicestore"
+	"github.com/nano/gameserver/protocol"
+)
+
+const (
+	// 上传token的有效时间(毫秒)
+	VOICE_TOKEN_TIMEOUT = 5 * 60 * 1000
+	// 上传token的发放记录和聊天频道放在一起, 频道从1开始
+	VOICE_TOKEN_LIMITER = 0
+)
+
+// 每个token只能上传一次, 限制获取的频率就限制了上传的频率
+var voiceTokenRule = chatRule{cooldown: 1000, limit: 10}
+
+func (m *Manager) VoiceUploadToken(s *session.Session, req *protocol.EmptyRequest) error {
+	if _, ok := m.onlineUser(s.UID()); !ok {
+		return fmt.Errorf("玩家: %d不在线", s.UID())
+	}
+	if err := m.chatLimiterOf(s.UID(), VOICE_TOKEN_LIMITER).allow(voiceTokenRule, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	token := &protocol.VoiceUploadToken{
+		Uid:      s.UID(),
+		ExpireAt: time.Now().UnixMilli() + VOICE_TOKEN_TIMEOUT,
+	}
+	token.Token = token.SignWith(voicestore.TokenKey())
+	return s.Response(token)
+}
+
+func (m *Manager) VoiceSend(s *session.Session, req *protocol.RecordingVoice) error {
+	user, rule, err := m.chatUser(s, req.Channel)
+	if err != nil {
+		return err
+	}
+	if !voicestore.ValidFileId(req.FileId) {
+		return errors.New("语音文件不存在")
+	}
+	clip, err := db.QueryVoiceClip(req.FileId)
+	if err != nil || clip.Uid != user.Uid {
+		return errors.New("语音文件不存在")
+	}
+	if clip.Duration > voicestore.MaxDuration() {
+		return errors.New("语音时长超过限制")
+	}
+	targetId, err := m.chatTargetId(user, req.Channel, req.TargetUid)
+	if err != nil {
+		return err
+	}
+	if req.Channel == constants.CHAT_CHANNEL_PRIVATE {
+		if _, ok := m.onlineUser(targetId); !ok {
+			return fmt.Errorf("玩家: %d不在线", targetId)
+		}
+	}
+	now := time.Now().UnixMilli()
+	if err := m.chatLimiterOf(user.Uid, req.Channel).allow(rule, now); err != nil {
+		return err
+	}
+	voice := &protocol.PlayRecordingVoice{
+		Uid:      user.Uid,
+		FileId:   clip.FileId,
+		HeroId:   user.heroData.Id,
+		Name:     user.heroData.Name,
+		Channel:  req.Channel,
+		TargetId: targetId,
+		Duration: clip.Duration,
+		SendAt:   now,
+	}
+	m.deliverChannel(user, req.Channel, targetId, protocol.OnRecordingVoice,
+		&protocol.ChatBroadcastRequest{HeroId: user.heroData.Id, Voice: voice}, voice)
+	return nil
+}
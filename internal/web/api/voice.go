@@ -0,0 +1,159 @@
+package api
+
+import (
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lonng/nex"
+	"github.com/nano/gameserver/db"
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/errutil"
+	"github.com/nano/gameserver/pkg/voicestore"
+	"github.com/nano/gameserver/protocol"
+	"github.com/spf13/viper"
+)
+
+const (
+	// 清理过期语音的间隔
+	VOICE_CLEAN_INTERVAL = time.Hour
+	// 每次清理的最大数量
+	VOICE_CLEAN_BATCH = 500
+)
+
+var voiceStorage voicestore.Storage
+
+// 已经使用过的上传token -> 截止时间, 每个token只能上传一次
+var usedVoiceTokens = struct {
+	sync.Mutex
+	tokens map[string]int64
+}{tokens: map[string]int64{}}
+
+// 标记token已使用, 已经使用过时返回false
+func useVoiceToken(token string, expireAt int64) bool {
+	usedVoiceTokens.Lock()
+	defer usedVoiceTokens.Unlock()
+	if _, ok := usedVoiceTokens.tokens[token]; ok {
+		return false
+	}
+	usedVoiceTokens.tokens[token] = expireAt
+	return true
+}
+
+// 删除已经过期的token, 过期后签名校验就不会通过
+func cleanVoiceTokens(now int64) {
+	usedVoiceTokens.Lock()
+	defer usedVoiceTokens.Unlock()
+	for token, expireAt := range usedVoiceTokens.tokens {
+		if now > expireAt {
+			delete(usedVoiceTokens.tokens, token)
+		}
+	}
+}
+
+func MakeVoiceService() http.Handler {
+	dir := viper.GetString("voice.dir")
+	if dir == "" {
+		dir = "./voice"
+	}
+	storage, err := voicestore.NewLocalStorage(dir)
+	if err != nil {
+		logger.Fatalf("初始化语音存储目录: %s失败: %v", dir, err)
+	}
+	voiceStorage = storage
+	go cleanVoiceClips()
+
+	router := mux.NewRouter()
+	router.Handle("/v1/voice/upload", nex.Handler(uploadVoice)).Methods("POST") //上传语音
+	router.HandleFunc("/v1/voice/{id}", downloadVoice).Methods("GET")           //下载语音
+	return router
+}
+
+// 表单: uid, expire_at, token(master签发), duration(毫秒), file
+func uploadVoice(r *http.Request) (*protocol.VoiceUploadResponse, error) {
+	maxSize := voicestore.MaxSize()
+	r.Body = http.MaxBytesReader(nil, r.Body, int64(maxSize)+64*1024)
+	if err := r.ParseMultipartForm(int64(maxSize)); err != nil {
+		return nil, errutil.ErrInvalidParameter
+	}
+	token := &protocol.VoiceUploadToken{Token: r.FormValue("token")}
+	token.Uid, _ = strconv.ParseInt(strings.TrimSpace(r.FormValue("uid")), 10, 64)
+	token.ExpireAt, _ = strconv.ParseInt(strings.TrimSpace(r.FormValue("expire_at")), 10, 64)
+	sign := token.SignWith(voicestore.TokenKey())
+	if !hmac.Equal([]byte(token.Token), []byte(sign)) || time.Now().UnixMilli() > token.ExpireAt {
+		return nil, errutil.ErrInvalidToken
+	}
+	duration, err := strconv.Atoi(strings.TrimSpace(r.FormValue("duration")))
+	if err != nil {
+		return nil, errutil.ErrInvalidParameter
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, errutil.ErrInvalidParameter
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, int64(maxSize)+1))
+	if err != nil || len(data) == 0 || len(data) > maxSize || !voicestore.ValidDuration(len(data), duration) {
+		return nil, errutil.ErrInvalidParameter
+	}
+	if !useVoiceToken(token.Token, token.ExpireAt) {
+		return nil, errutil.ErrInvalidToken
+	}
+
+	fileId, err := voiceStorage.Save(data)
+	if err != nil {
+		logger.Errorf("玩家: %d保存语音失败: %v", token.Uid, err)
+		return nil, errutil.ErrServerInternal
+	}
+	clip := &model.VoiceClip{
+		FileId:   fileId,
+		Uid:      token.Uid,
+		Size:     len(data),
+		Duration: duration,
+		UploadAt: time.Now().UnixMilli(),
+	}
+	if err := db.InsertVoiceClip(clip); err != nil {
+		voiceStorage.Remove(fileId)
+		return nil, err
+	}
+	return &protocol.VoiceUploadResponse{FileId: fileId}, nil
+}
+
+func downloadVoice(w http.ResponseWriter, r *http.Request) {
+	data, err := voiceStorage.Load(mux.Vars(r)["id"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(data)
+}
+
+// 定时删除超过保留时间的语音
+func cleanVoiceClips() {
+	for range time.Tick(VOICE_CLEAN_INTERVAL) {
+		cleanVoiceTokens(time.Now().UnixMilli())
+		before := time.Now().Add(-voicestore.KeepTime()).UnixMilli()
+		clips, err := db.ExpiredVoiceClips(before, VOICE_CLEAN_BATCH)
+		if err != nil {
+			logger.Errorf("查询过期语音失败: %v", err)
+			continue
+		}
+		for _, clip := range clips {
+			if err := voiceStorage.Remove(clip.FileId); err != nil {
+				logger.Errorf("删除语音文件: %s失败: %v", clip.FileId, err)
+				continue
+			}
+			db.DeleteVoiceClip(clip.Id)
+		}
+		if len(clips) > 0 {
+			logger.Infof("清理过期语音: %d个", len(clips))
+		}
+	}
+}
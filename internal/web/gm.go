@@ -62,6 +62,25 @@ func kickHandler(query *nex.Form) (*protocol.StringMessage, error) {
 	return protocol.SuccessMessage, nil
 }
 
+// 禁言, seconds为0时解除, master最多10秒后生效
+func muteHandler(query *nex.Form) (*protocol.StringMessage, error) {
+	uid := query.Int64OrDefault("uid", -1)
+	seconds := query.Int64OrDefault("seconds", -1)
+	if uid <= 0 || seconds < 0 {
+		return nil, errutil.ErrIllegalParameter
+	}
+	var until int64
+	if seconds > 0 {
+		until = time.Now().Add(time.Duration(seconds) * time.Second).UnixMilli()
+	}
+	reason := strings.TrimSpace(query.Get("reason"))
+	log.Infof("玩家禁言: Uid=%d, seconds=%d, reason=%s", uid, seconds, reason)
+	if err := db.SaveChatMute(uid, until, reason); err != nil {
+		return nil, err
+	}
+	return protocol.SuccessMessage, nil
+}
+
 func onlineHandler(query *nex.Form) (interface{}, error) {
 	begin := query.Int64OrDefault("begin", 0)
 	end := query.Int64OrDefault("end", -1)
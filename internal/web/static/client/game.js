@@ -154,6 +154,9 @@ class Game {
             nano.on("OnMonsterCommonAttack", function(data){
                 that.OnMonsterCommonAttack(data)
             })
+            nano.on("OnHeroCommonAttack", function(data){
+                that.OnHeroCommonAttack(data)
+            })
             nano.on("OnLifeChanged", function(data){
                 that.OnLifeChanged(data)
             })
@@ -163,6 +166,24 @@ class Game {
             nano.on("OnEntityDie", function(data){
                 that.OnEntityDie(data)
             })
+            nano.on("OnHeroRevive", function(data){
+                that.OnHeroRevive(data)
+            })
+            nano.on("OnHeroExpChanged", function(data){
+                that.OnHeroExpChanged(data)
+            })
+            nano.on("OnHeroLevelUp", function(data){
+                that.OnHeroLevelUp(data)
+            })
+            nano.on("OnItemPickup", function(data){
+                that.OnItemPickup(data)
+            })
+            nano.on("OnHeroBag", function(data){
+                that.OnHeroBag(data)
+            })
+            nano.on("OnHeroAttrChanged", function(data){
+                that.OnHeroAttrChanged(data)
+            })
             nano.on("OnReleaseSpell", function(data){
                 that.OnReleaseSpell(data)
             })
@@ -265,6 +286,10 @@ class Game {
         console.log("OnMonsterCommonAttack:::", data)
     }
 
+    OnHeroCommonAttack(data){
+        console.log("OnHeroCommonAttack:::", data)
+    }
+
     OnLifeChanged(data){
         console.log("OnLifeChanged:::", data)
         this.scene.lifeChanged(data)
@@ -280,6 +305,31 @@ class Game {
         this.scene.entityDie(data)
     }
 
+    OnHeroRevive(data){
+        console.log("OnHeroRevive:::", data)
+        this.scene.heroMoveStop(data.id, data.pos_x, data.pos_y, data.pos_z)
+    }
+
+    OnHeroExpChanged(data){
+        console.log("OnHeroExpChanged:::", data)
+    }
+
+    OnHeroLevelUp(data){
+        console.log("OnHeroLevelUp:::", data)
+    }
+
+    OnItemPickup(data){
+        console.log("OnItemPickup:::", data)
+    }
+
+    OnHeroBag(data){
+        console.log("OnHeroBag:::", data)
+    }
+
+    OnHeroAttrChanged(data){
+        console.log("OnHeroAttrChanged:::", data)
+    }
+
     OnReleaseSpell(data){
         console.log("OnReleaseSpell:::", data)
         this.scene.OnReleaseSpell(data);
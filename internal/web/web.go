@@ -11,6 +11,7 @@ import (
 
 	"github.com/nano/gameserver/internal/web/api"
 	"github.com/nano/gameserver/pkg/algoutil"
+	"github.com/nano/gameserver/pkg/voicestore"
 	"github.com/nano/gameserver/pkg/whitelist"
 	"github.com/nano/gameserver/protocol"
 	log "github.com/sirupsen/logrus"
@@ -61,12 +62,14 @@ func startupService() http.Handler {
 	mux.Handle("/v1/user/", api.MakeLoginService())
 	mux.Handle("/v1/order/", api.MakeOrderService())
 	mux.Handle("/v1/desk/", api.MakeSceneService())
+	mux.Handle("/v1/voice/", api.MakeVoiceService())
 	mux.Handle("/v1/version", nex.Handler(version))
 
 	// GM系统命令
 	mux.Handle("/v1/gm/reset", nex.Handler(resetPlayerHandler).Before(authFilter)) // 重置玩家状态
 	mux.Handle("/v1/gm/broadcast", nex.Handler(broadcast).Before(authFilter))      // 消息广播
 	mux.Handle("/v1/gm/kick", nex.Handler(kickHandler).Before(authFilter))         // 踢人
+	mux.Handle("/v1/gm/mute", nex.Handler(muteHandler).Before(authFilter))         // 禁言
 	mux.Handle("/v1/gm/online", nex.Handler(onlineHandler).Before(authFilter))     // 在线信息
 	mux.Handle("/v1/gm/recharge", nex.Handler(rechargeHandler).Before(authFilter)) // 玩家充值
 	mux.Handle("/v1/gm/query/user/", nex.Handler(userInfoHandler))                 // 玩家信息查询
@@ -81,6 +84,13 @@ func startupService() http.Handler {
 }
 
 func Startup() {
+	if err := algoutil.CheckClusterSecret(viper.GetString("cluster.secret")); err != nil {
+		panic(err)
+	}
+	// 语音上传的token用单独的密钥校验
+	if err := voicestore.CheckTokenKey(); err != nil {
+		panic(err)
+	}
 	// enable white list
 	enableWhiteList()
 
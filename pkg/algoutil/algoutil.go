@@ -4,9 +4,11 @@ package algoutil
 import (
 	"bufio"
 	"bytes"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
@@ -46,6 +48,41 @@ func MD5(plain []byte) []byte {
 	return cipher[:]
 }
 
+// ClusterSecretPlaceholder the secret shipped in the sample config, nodes refuse to start with it
+const ClusterSecretPlaceholder = "CHANGE_ME"
+
+// CheckClusterSecret returns an error if the shared secret is empty or still the placeholder
+func CheckClusterSecret(secret string) error {
+	return CheckSecret("cluster.secret", secret)
+}
+
+// CheckSecret returns an error if the secret named by name is empty or still the placeholder
+func CheckSecret(name, secret string) error {
+	if secret == "" || secret == ClusterSecretPlaceholder {
+		return fmt.Errorf("%s is not configured", name)
+	}
+	return nil
+}
+
+// SignFields sign fields with a shared secret, used between cluster nodes
+func SignFields(secret string, fields ...interface{}) string {
+	buf := &bytes.Buffer{}
+	for _, f := range fields {
+		fmt.Fprintf(buf, "%v|", f)
+	}
+	buf.WriteString(secret)
+	return MD5String(buf.String())
+}
+
+// HMACFields hmac-sha256 of fields in hex, used for tokens handed to clients
+func HMACFields(key string, fields ...interface{}) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	for _, f := range fields {
+		fmt.Fprintf(mac, "%v|", f)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // CallSite the caller's file & line
 func CallSite() interface{} {
 	_, file, line, ok := runtime.Caller(3)
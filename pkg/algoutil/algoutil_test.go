@@ -23,6 +23,25 @@ func TestGenRSAKey(t *testing.T) {
 	}
 }
 
+func TestCheckClusterSecret(t *testing.T) {
+	if CheckClusterSecret("") == nil || CheckClusterSecret(ClusterSecretPlaceholder) == nil {
+		t.Fatal("empty or placeholder secret should be rejected")
+	}
+	if err := CheckClusterSecret("3f9c1a7e"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHMACFields(t *testing.T) {
+	sign := HMACFields("key", "voice", 1, 1000)
+	if len(sign) != 64 || sign != HMACFields("key", "voice", 1, 1000) {
+		t.Fatalf("unexpected sign: %s", sign)
+	}
+	if sign == HMACFields("key2", "voice", 1, 1000) || sign == HMACFields("key", "voice", 2, 1000) {
+		t.Fatal("sign should depend on key and fields")
+	}
+}
+
 func BenchmarkGenRSAKey(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		GenRSAKey()
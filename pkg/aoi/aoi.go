@@ -8,6 +8,8 @@ type AOI interface {
 	Delete(x, y float64, name string)                // Delete an entity from the AOI
 	Search(x, y float64) (result []interface{})      // Search for entities within a specified range
 	Moved(x, y, oldx, oldy float64, key string, data interface{})
+	SearchRect(minX, minY, maxX, maxY float64) []interface{} // Search for entities inside the rectangle, borders included
+	SearchRadius(x, y, r float64) []interface{}              // Search for entities within the radius of (x, y)
 }
 
 // Entity represents an object with coordinates and a key.
@@ -17,6 +19,17 @@ type Entity struct {
 	Data interface{} //引用的数据指针要记录下来
 }
 
+// inRect checks whether the entity lies inside the rectangle, borders included.
+func (e *Entity) inRect(minX, minY, maxX, maxY float64) bool {
+	return e.X >= minX && e.X <= maxX && e.Y >= minY && e.Y <= maxY
+}
+
+// inRadius checks whether the entity lies within the radius of (x, y).
+func (e *Entity) inRadius(x, y, r float64) bool {
+	offsetX, offsetY := e.X-x, e.Y-y
+	return offsetX*offsetX+offsetY*offsetY <= r*r
+}
+
 var (
 	resultPool sync.Pool // Pool for recycling result slices
 	entityPool sync.Pool // Pool for recycling Entity objects
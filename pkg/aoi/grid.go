@@ -64,11 +64,28 @@ func (g *GridManager) gridWidth() int {
 	return g.AreaWidth / g.GridCount
 }
 
-// getGIDByPos calculates the grid ID based on the given coordinates.
-func (g *GridManager) getGIDByPos(x, y float64) int {
+// clampGrid limits a grid coordinate to [0, GridCount), positions on the edge that can't
+// be divided evenly belong to the last grid.
+func (g *GridManager) clampGrid(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v >= g.GridCount {
+		return g.GridCount - 1
+	}
+	return v
+}
+
+// getGridXY calculates the grid coordinates based on the given position.
+func (g *GridManager) getGridXY(x, y float64) (int, int) {
 	gx := (int(x) - g.StartX) / g.gridWidth()
 	gy := (int(y) - g.StartY) / g.gridWidth()
+	return g.clampGrid(gx), g.clampGrid(gy)
+}
 
+// getGIDByPos calculates the grid ID based on the given coordinates.
+func (g *GridManager) getGIDByPos(x, y float64) int {
+	gx, gy := g.getGridXY(x, y)
 	return gy*g.GridCount + gx
 }
 
@@ -140,6 +157,11 @@ func (g *GridManager) Moved(x, y, oldx, oldy float64, key string, data interface
 			g.Delete(oldx, oldy, key)
 		}
 		g.Add(x, y, key, data)
+		return
+	}
+	// 同一个格子内也要更新坐标, 范围查询按坐标过滤; 旧对象可能正在被查询, 不放回池子
+	if _, ok := g.grids[newgid].Entities.Load(key); ok {
+		g.Add(x, y, key, data)
 	}
 }
 
@@ -164,3 +186,39 @@ func (g *GridManager) Search(x, y float64) []interface{} {
 
 	return result
 }
+
+// SearchRect retrieves entities inside the rectangle, it may cover any number of grids.
+func (g *GridManager) SearchRect(minX, minY, maxX, maxY float64) []interface{} {
+	result := make([]interface{}, 0)
+	x0, y0 := g.getGridXY(minX, minY)
+	x1, y1 := g.getGridXY(maxX, maxY)
+	for gy := y0; gy <= y1; gy++ {
+		for gx := x0; gx <= x1; gx++ {
+			g.grids[gy*g.GridCount+gx].Entities.Range(func(_, value interface{}) bool {
+				if entity := value.(*Entity); entity.inRect(minX, minY, maxX, maxY) {
+					result = append(result, entity.Data)
+				}
+				return true
+			})
+		}
+	}
+	return result
+}
+
+// SearchRadius retrieves entities within the radius of (x, y).
+func (g *GridManager) SearchRadius(x, y, r float64) []interface{} {
+	result := make([]interface{}, 0)
+	x0, y0 := g.getGridXY(x-r, y-r)
+	x1, y1 := g.getGridXY(x+r, y+r)
+	for gy := y0; gy <= y1; gy++ {
+		for gx := x0; gx <= x1; gx++ {
+			g.grids[gy*g.GridCount+gx].Entities.Range(func(_, value interface{}) bool {
+				if entity := value.(*Entity); entity.inRadius(x, y, r) {
+					result = append(result, entity.Data)
+				}
+				return true
+			})
+		}
+	}
+	return result
+}
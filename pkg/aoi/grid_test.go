@@ -153,3 +153,21 @@ func TestGridManger_GetSurroundGrids(t *testing.T) {
 // 		wg.Wait()
 // 	}
 // }
+
+func TestGridManager_SearchRange(t *testing.T) {
+	manager := NewGridManager(0, 0, 250, 5)
+	manager.Add(10, 10, "a", "a")
+	manager.Add(120, 10, "b", "b")
+	manager.Add(240, 240, "c", "c")
+	manager.Add(249, 0, "d", "d")
+
+	// 范围超过九宫格
+	result := manager.SearchRect(0, 0, 249, 20)
+	assert.ElementsMatch(t, []interface{}{"a", "b", "d"}, result)
+	assert.ElementsMatch(t, []interface{}{"a", "b"}, manager.SearchRadius(10, 10, 110))
+
+	// 格子内移动后按新坐标查询
+	manager.Moved(30, 30, 10, 10, "a", "a")
+	assert.Empty(t, manager.SearchRadius(10, 10, 5))
+	assert.ElementsMatch(t, []interface{}{"a"}, manager.SearchRect(25, 25, 35, 35))
+}
@@ -1,7 +1,6 @@
 package aoi
 
 import (
-	"errors"
 	"sync"
 )
 
@@ -33,6 +32,7 @@ type QuadTree struct {
 	maxCap, maxDeep int
 	radius          float64
 	mPool           sync.Pool
+	mu              sync.RWMutex // Splitting nodes isn't safe for concurrent searches
 	*Node
 }
 
@@ -190,8 +190,51 @@ func (n *Node) Search(x, y float64) []interface{} {
 	return result
 }
 
+// Moved moves an entity from the old coordinates to the new ones.
 func (n *Node) Moved(x, y, oldx, oldy float64, key string, data interface{}) {
-	panic(errors.New("功能未实现"))
+	n.Delete(oldx, oldy, key)
+	n.Add(x, y, key, data)
+}
+
+// overlaps checks if the rectangle overlaps the node's range.
+func (n *Node) overlaps(minX, minY, maxX, maxY float64) bool {
+	return minX < n.XStart+n.AreaWidth && maxX >= n.XStart && minY < n.YStart+n.AreaWidth && maxY >= n.YStart
+}
+
+// searchRect recursively collects entities inside the rectangle, filter is applied to each of them.
+func (n *Node) searchRect(minX, minY, maxX, maxY float64, filter func(*Entity) bool, result *[]interface{}) {
+	if !n.Leaf {
+		for _, son := range n.Child {
+			if son.overlaps(minX, minY, maxX, maxY) {
+				son.searchRect(minX, minY, maxX, maxY, filter, result)
+			}
+		}
+		return
+	}
+	n.Entities.Range(func(_, value interface{}) bool {
+		if entity := value.(*Entity); filter(entity) {
+			*result = append(*result, entity.Data)
+		}
+		return true
+	})
+}
+
+// SearchRect retrieves entities inside the rectangle.
+func (n *Node) SearchRect(minX, minY, maxX, maxY float64) []interface{} {
+	result := make([]interface{}, 0)
+	n.searchRect(minX, minY, maxX, maxY, func(e *Entity) bool {
+		return e.inRect(minX, minY, maxX, maxY)
+	}, &result)
+	return result
+}
+
+// SearchRadius retrieves entities within the radius of (x, y).
+func (n *Node) SearchRadius(x, y, r float64) []interface{} {
+	result := make([]interface{}, 0)
+	n.searchRect(x-r, y-r, x+r, y+r, func(e *Entity) bool {
+		return e.inRadius(x, y, r)
+	}, &result)
+	return result
 }
 
 // search recursively searches for entities within the specified coordinates' range.
@@ -216,3 +259,45 @@ func (n *Node) search(x, y float64, result *[]interface{}) {
 	})
 	return
 }
+
+// Add adds an entity to the quadtree, the tree is locked while nodes may be split.
+func (t *QuadTree) Add(x, y float64, name string, data interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Node.Add(x, y, name, data)
+}
+
+// Delete removes an entity from the quadtree.
+func (t *QuadTree) Delete(x, y float64, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Node.Delete(x, y, name)
+}
+
+// Moved moves an entity from the old coordinates to the new ones.
+func (t *QuadTree) Moved(x, y, oldx, oldy float64, key string, data interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Node.Moved(x, y, oldx, oldy, key, data)
+}
+
+// Search retrieves entities around the coordinates.
+func (t *QuadTree) Search(x, y float64) []interface{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.Node.Search(x, y)
+}
+
+// SearchRect retrieves entities inside the rectangle.
+func (t *QuadTree) SearchRect(minX, minY, maxX, maxY float64) []interface{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.Node.SearchRect(minX, minY, maxX, maxY)
+}
+
+// SearchRadius retrieves entities within the radius of (x, y).
+func (t *QuadTree) SearchRadius(x, y, r float64) []interface{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.Node.SearchRadius(x, y, r)
+}
@@ -156,3 +156,19 @@ func Test_FindQuadrant(t *testing.T) {
 // 		}
 // 	}
 // }
+
+func TestQuadTree_SearchRange(t *testing.T) {
+	tree := NewQuadTree(0, 0, 100).(*QuadTree)
+	tree.maxCap = 2 // 超过两个对象节点分裂
+	tree.Add(10, 10, "a", "a")
+	tree.Add(60, 10, "b", "b")
+	tree.Add(90, 90, "c", "c")
+	tree.Add(40, 40, "d", "d")
+
+	assert.ElementsMatch(t, []interface{}{"a", "b"}, tree.SearchRect(0, 0, 70, 20))
+	assert.ElementsMatch(t, []interface{}{"a", "d"}, tree.SearchRadius(25, 25, 22))
+
+	tree.Moved(80, 80, 10, 10, "a", "a")
+	assert.ElementsMatch(t, []interface{}{"a", "c"}, tree.SearchRadius(85, 85, 10))
+	assert.Empty(t, tree.SearchRect(0, 0, 20, 20))
+}
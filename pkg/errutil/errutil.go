@@ -54,6 +54,7 @@ var (
 	ErrProductionNotFound    = errors.New("production not found")
 	ErrRequestPrePayIDFailed = errors.New("request prepay id failed")
 	ErrAccountExists         = errors.New("account exists")
+	ErrServerDraining        = errors.New("server is draining")
 )
 
 // Code code for the error
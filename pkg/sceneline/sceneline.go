@@ -0,0 +1,57 @@
+// Package sceneline 场景分线的配置, master分配分线和game节点创建分线时共用
+package sceneline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// 没有配置时每条线的hero上限
+	DEFAULT_CAPACITY = 300
+)
+
+// Parse 解析"场景id:分线数量"的配置, 多个场景用逗号分隔, 例如"1:3,2:2"
+func Parse(str string) (map[int]int, error) {
+	result := make(map[int]int)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		arr := strings.Split(item, ":")
+		if len(arr) != 2 {
+			return nil, fmt.Errorf("分线配置格式错误: %s", item)
+		}
+		sceneId, err := strconv.Atoi(strings.TrimSpace(arr[0]))
+		if err != nil {
+			return nil, fmt.Errorf("分线配置格式错误: %s", item)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(arr[1]))
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("分线配置格式错误: %s", item)
+		}
+		result[sceneId] = count
+	}
+	return result, nil
+}
+
+// Count 场景的分线数量, 没有配置时只有1条线
+func Count(sceneId int) int {
+	lines, err := Parse(viper.GetString("scene-line.lines"))
+	if err != nil || lines[sceneId] < 1 {
+		return 1
+	}
+	return lines[sceneId]
+}
+
+// Capacity 每条线的hero上限, 超过后master不再分配新的hero
+func Capacity() int {
+	if c := viper.GetInt("scene-line.capacity"); c > 0 {
+		return c
+	}
+	return DEFAULT_CAPACITY
+}
@@ -0,0 +1,22 @@
+package sceneline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	lines, err := Parse("1:3, 2:2")
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]int{1: 3, 2: 2}, lines)
+
+	lines, err = Parse("")
+	assert.Nil(t, err)
+	assert.Empty(t, lines)
+
+	_, err = Parse("1")
+	assert.NotNil(t, err)
+	_, err = Parse("1:0")
+	assert.NotNil(t, err)
+}
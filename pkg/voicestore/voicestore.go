@@ -0,0 +1,151 @@
+// Package voicestore 语音文件的存储, web服务上传和下载时使用
+package voicestore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nano/gameserver/pkg/algoutil"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidFileId = errors.New("invalid file id")
+
+// Storage 语音文件的存储, 目前只有本地磁盘, 以后可以换成对象存储
+type Storage interface {
+	// Save 保存后返回文件id
+	Save(data []byte) (string, error)
+	Load(fileId string) ([]byte, error)
+	Remove(fileId string) error
+}
+
+// NewFileId 32位的16进制随机字符串
+func NewFileId() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ValidFileId 只允许NewFileId生成的格式, 避免访问存储目录以外的文件
+func ValidFileId(fileId string) bool {
+	if len(fileId) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(fileId)
+	return err == nil
+}
+
+// LocalStorage 按文件id的前2位分目录保存在本地磁盘
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+func (s *LocalStorage) path(fileId string) string {
+	return filepath.Join(s.dir, fileId[:2], fileId)
+}
+
+func (s *LocalStorage) Save(data []byte) (string, error) {
+	fileId, err := NewFileId()
+	if err != nil {
+		return "", err
+	}
+	p := s.path(fileId)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		return "", err
+	}
+	return fileId, nil
+}
+
+func (s *LocalStorage) Load(fileId string) ([]byte, error) {
+	if !ValidFileId(fileId) {
+		return nil, ErrInvalidFileId
+	}
+	return os.ReadFile(s.path(fileId))
+}
+
+func (s *LocalStorage) Remove(fileId string) error {
+	if !ValidFileId(fileId) {
+		return ErrInvalidFileId
+	}
+	err := os.Remove(s.path(fileId))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+const (
+	// 没有配置时的限制
+	DEFAULT_MAX_SIZE     = 256 * 1024
+	DEFAULT_MAX_DURATION = 60
+	DEFAULT_KEEP_HOURS   = 72
+	// 低于这个码率时认为客户端上报的时长是假的
+	MIN_BYTES_PER_SECOND = 400
+)
+
+// MaxSize 单个语音文件的最大字节数, 配置voice.max_size
+func MaxSize() int {
+	if n := viper.GetInt("voice.max_size"); n > 0 {
+		return n
+	}
+	return DEFAULT_MAX_SIZE
+}
+
+// MaxDuration 单个语音的最大时长(毫秒), 配置voice.max_duration(秒)
+func MaxDuration() int {
+	n := viper.GetInt("voice.max_duration")
+	if n <= 0 {
+		n = DEFAULT_MAX_DURATION
+	}
+	return n * 1000
+}
+
+// ValidDuration 时长(毫秒)不能超过MaxDuration, 并且要和文件大小相符
+func ValidDuration(size, duration int) bool {
+	if duration <= 0 || duration > MaxDuration() {
+		return false
+	}
+	return int64(size)*1000 >= int64(duration)*MIN_BYTES_PER_SECOND
+}
+
+// KeepTime 语音文件的保留时间, 配置voice.keep_hours
+func KeepTime() time.Duration {
+	n := viper.GetInt("voice.keep_hours")
+	if n <= 0 {
+		n = DEFAULT_KEEP_HOURS
+	}
+	return time.Duration(n) * time.Hour
+}
+
+// TokenKey 上传token的签名密钥, 配置voice.token_key
+func TokenKey() string {
+	return viper.GetString("voice.token_key")
+}
+
+// CheckTokenKey 没有配置或者和cluster.secret相同时返回错误, token会交给客户端
+func CheckTokenKey() error {
+	key := TokenKey()
+	if err := algoutil.CheckSecret("voice.token_key", key); err != nil {
+		return err
+	}
+	if key == viper.GetString("cluster.secret") {
+		return errors.New("voice.token_key must differ from cluster.secret")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package voicestore
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStorage(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	assert.Nil(t, err)
+
+	fileId, err := s.Save([]byte("voice"))
+	assert.Nil(t, err)
+	assert.True(t, ValidFileId(fileId))
+
+	data, err := s.Load(fileId)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("voice"), data)
+
+	assert.Nil(t, s.Remove(fileId))
+	_, err = s.Load(fileId)
+	assert.NotNil(t, err)
+	// 已经删除的再删除不报错
+	assert.Nil(t, s.Remove(fileId))
+
+	_, err = s.Load("../../etc/passwd")
+	assert.Equal(t, ErrInvalidFileId, err)
+}
+
+func TestLimits(t *testing.T) {
+	assert.Equal(t, DEFAULT_MAX_SIZE, MaxSize())
+	assert.Equal(t, DEFAULT_MAX_DURATION*1000, MaxDuration())
+
+	viper.Set("voice.max_duration", 30)
+	defer viper.Set("voice.max_duration", 0)
+	assert.Equal(t, 30*1000, MaxDuration())
+
+	assert.True(t, ValidDuration(MIN_BYTES_PER_SECOND*10, 10*1000))
+	assert.False(t, ValidDuration(MIN_BYTES_PER_SECOND*10, 11*1000))
+	assert.False(t, ValidDuration(DEFAULT_MAX_SIZE, 31*1000))
+	assert.False(t, ValidDuration(100, 0))
+}
+
+func TestCheckTokenKey(t *testing.T) {
+	defer viper.Set("voice.token_key", "")
+	defer viper.Set("cluster.secret", "")
+	assert.NotNil(t, CheckTokenKey())
+	viper.Set("cluster.secret", "3f9c1a7e")
+	viper.Set("voice.token_key", "3f9c1a7e")
+	assert.NotNil(t, CheckTokenKey())
+	viper.Set("voice.token_key", "b72e5d01")
+	assert.Nil(t, CheckTokenKey())
+}
@@ -0,0 +1,90 @@
+// Package wordfilter 聊天内容的屏蔽词过滤, 屏蔽词替换为*
+package wordfilter
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type Filter struct {
+	mu sync.RWMutex
+	// 屏蔽词按第一个字分组, 同一组内长的在前
+	words map[rune][][]rune
+}
+
+func New(words []string) *Filter {
+	f := &Filter{}
+	f.Reset(words)
+	return f
+}
+
+// Reset 重新加载屏蔽词, 英文不区分大小写
+func (f *Filter) Reset(words []string) {
+	m := make(map[rune][][]rune)
+	for _, w := range words {
+		w = strings.TrimSpace(strings.ToLower(w))
+		if w == "" {
+			continue
+		}
+		rs := []rune(w)
+		m[rs[0]] = append(m[rs[0]], rs)
+	}
+	for _, list := range m {
+		for i := 1; i < len(list); i++ {
+			for j := i; j > 0 && len(list[j]) > len(list[j-1]); j-- {
+				list[j], list[j-1] = list[j-1], list[j]
+			}
+		}
+	}
+	f.mu.Lock()
+	f.words = m
+	f.mu.Unlock()
+}
+
+// Replace 返回替换后的内容和是否包含屏蔽词
+func (f *Filter) Replace(text string) (string, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.words) == 0 {
+		return text, false
+	}
+	src := []rune(text)
+	lower := make([]rune, len(src))
+	for i, r := range src {
+		lower[i] = unicode.ToLower(r)
+	}
+	found := false
+	for i := 0; i < len(lower); {
+		n := f.match(lower[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+		for j := i; j < i+n; j++ {
+			src[j] = '*'
+		}
+		found = true
+		i += n
+	}
+	return string(src), found
+}
+
+func (f *Filter) match(text []rune) int {
+	for _, w := range f.words[text[0]] {
+		if len(w) > len(text) {
+			continue
+		}
+		ok := true
+		for i := range w {
+			if w[i] != text[i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return len(w)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package wordfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplace(t *testing.T) {
+	f := New([]string{"外挂", "外挂软件", "Bad", " "})
+
+	text, found := f.Replace("出售外挂软件")
+	assert.True(t, found)
+	assert.Equal(t, "出售****", text)
+
+	text, found = f.Replace("用外挂的是BAD guy")
+	assert.True(t, found)
+	assert.Equal(t, "用**的是*** guy", text)
+
+	text, found = f.Replace("正常聊天")
+	assert.False(t, found)
+	assert.Equal(t, "正常聊天", text)
+
+	f.Reset(nil)
+	text, found = f.Replace("外挂")
+	assert.False(t, found)
+	assert.Equal(t, "外挂", text)
+}
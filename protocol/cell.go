@@ -0,0 +1,38 @@
+package protocol
+
+// 大地图按x坐标切分成多个cell, 每个cell由一个game节点负责, 划分由master的CellManager维护
+// 靠近边界的实体以ghost的形式同步给相邻的cell, 只用于显示
+// hero走出自己的cell时, game节点通知master迁移到相邻的cell, 迁移复用切换场景的握手流程
+import (
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/algoutil"
+	"github.com/nano/gameserver/pkg/coord"
+)
+
+type HeroCellMigrateRequest struct {
+	Uid     int64       `json:"uid"`
+	HeroId  int64       `json:"hero_id"`
+	SceneId int         `json:"scene_id"`
+	PosX    coord.Coord `json:"pos_x"`
+	PosY    coord.Coord `json:"pos_y"`
+	Sign    string      `json:"sign"`
+}
+
+func (r *HeroCellMigrateRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.Uid, r.HeroId, r.SceneId, r.PosX, r.PosY)
+}
+
+// 每次同步边界范围内的全部实体, 不在列表内的ghost由接收方删除
+type CellGhostSyncRequest struct {
+	SceneId   int                     `json:"scene_id"`
+	FromAddr  string                  `json:"from_addr"` //发送方的service_addr
+	Version   int                     `json:"version"`   //发送方的划分版本, 接收方版本不一致时忽略
+	Timestamp int64                   `json:"timestamp"`
+	Heros     []*object.HeroObject    `json:"heros"`
+	Monsters  []*object.MonsterObject `json:"monsters"`
+	Sign      string                  `json:"sign"`
+}
+
+func (r *CellGhostSyncRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.SceneId, r.FromAddr, r.Version, r.Timestamp, payloadDigest(r.Heros, r.Monsters))
+}
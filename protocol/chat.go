@@ -0,0 +1,43 @@
+package protocol
+
+// 聊天由master处理, 检查禁言、频率和屏蔽词后按频道发送(OnChat)
+// 附近和场景频道通过发送人的session转给所在的game节点 SceneManager.ChatBroadcast, 由场景广播
+// 语音消息(OnRecordingVoice)使用同样的频道和限制, 不保存历史
+// 除了附近频道, 最近的消息保存在数据库, 重新进入游戏时推送(OnChatHistory)
+import (
+	"github.com/nano/gameserver/pkg/algoutil"
+)
+
+type ChatSendRequest struct {
+	Channel   int    `json:"channel"`
+	Msg       string `json:"msg"`
+	TargetUid int64  `json:"target_uid,omitempty"` //私聊的接收人
+}
+
+type ChatMessage struct {
+	Channel    int    `json:"channel"`
+	TargetId   int64  `json:"target_id"` //场景id、队伍id、公会id、私聊的接收人uid
+	FromUid    int64  `json:"from_uid"`
+	FromHeroId int64  `json:"from_hero_id"`
+	FromName   string `json:"from_name"`
+	Msg        string `json:"msg"`
+	SendAt     int64  `json:"send_at"`           //发送时间(毫秒)
+	Offline    bool   `json:"offline,omitempty"` //私聊的接收人不在线, 只在发送人自己收到的消息内, 对方上线后可以在历史消息内看到
+}
+
+type ChatHistoryResponse struct {
+	Messages []ChatMessage `json:"messages"`
+}
+
+// 文字消息和语音消息只有一个不为空
+type ChatBroadcastRequest struct {
+	HeroId  int64               `json:"hero_id"`
+	Message *ChatMessage        `json:"message,omitempty"`
+	Voice   *PlayRecordingVoice `json:"voice,omitempty"`
+	Sign    string              `json:"sign"`
+}
+
+// 发送者、频道、内容都会推送给客户端, 整个消息一起签名
+func (r *ChatBroadcastRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.HeroId, payloadDigest(r.Message, r.Voice))
+}
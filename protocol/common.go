@@ -1,5 +1,20 @@
 package protocol
 
+import (
+	"encoding/json"
+
+	"github.com/nano/gameserver/pkg/algoutil"
+)
+
+// 签名时代替数据本身的摘要, 接收方按反序列化后的数据重新计算, 数据被篡改时签名对不上
+func payloadDigest(v ...interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return algoutil.MD5String(string(data))
+}
+
 type AppInfo struct {
 	Name            string                       `json:"name"`             //应用名
 	AppID           string                       `json:"appid"`            //应用id
@@ -0,0 +1,36 @@
+package protocol
+
+// 好友按玩家(uid)保存在数据库, 由master处理
+// 好友上线、下线和切换场景时推送给在线的好友(OnFriendPresence)
+// 屏蔽的玩家不能发送好友申请和私聊
+
+type FriendUidRequest struct {
+	Uid int64 `json:"uid"`
+}
+
+type FriendInfo struct {
+	Uid     int64  `json:"uid"`
+	HeroId  int64  `json:"hero_id"` //在线时是当前的hero, 不在线时是最近使用的hero
+	Name    string `json:"name"`
+	Level   int    `json:"level"`
+	Online  bool   `json:"online"`
+	SceneId int    `json:"scene_id"` //只在在线时有值
+}
+
+type FriendRequestInfo struct {
+	Uid       int64  `json:"uid"`
+	Name      string `json:"name"`
+	RequestAt int64  `json:"request_at"` //申请时间(毫秒)
+}
+
+type FriendListResponse struct {
+	Friends  []FriendInfo        `json:"friends"`
+	Requests []FriendRequestInfo `json:"requests"`
+	Blocks   []int64             `json:"blocks"`
+}
+
+// 添加或删除好友, 删除时只有Uid
+type FriendChangedResponse struct {
+	Removed bool       `json:"removed"`
+	Friend  FriendInfo `json:"friend"`
+}
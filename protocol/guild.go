@@ -0,0 +1,91 @@
+package protocol
+
+// 公会保存在数据库, 由master处理, 公会变化时推送给在线的成员(OnGuildChanged)
+// 同时通过成员的session通知所在的game节点 SceneManager.HeroGuildChanged, 更新HeroObject内的公会并广播给看得见的hero
+// 切换场景时公会信息放在HeroEnterSceneRequest内带到新的场景
+import (
+	"github.com/nano/gameserver/pkg/algoutil"
+)
+
+type GuildCreateRequest struct {
+	Name string `json:"name"`
+}
+
+type GuildApplyRequest struct {
+	GuildId int64 `json:"guild_id"`
+}
+
+type GuildApproveRequest struct {
+	HeroId int64 `json:"hero_id"` //申请的hero
+	Accept bool  `json:"accept"`  //false为拒绝
+}
+
+type GuildKickRequest struct {
+	HeroId int64 `json:"hero_id"`
+}
+
+// Rank为会长时转让会长
+type GuildPromoteRequest struct {
+	HeroId int64 `json:"hero_id"`
+	Rank   int   `json:"rank"`
+}
+
+type GuildNoticeRequest struct {
+	Notice string `json:"notice"`
+}
+
+type GuildMemberItem struct {
+	HeroId int64  `json:"hero_id"`
+	Name   string `json:"name"`
+	Rank   int    `json:"rank"`
+	Online bool   `json:"online"`
+}
+
+type GuildApplyItem struct {
+	HeroId int64  `json:"hero_id"`
+	Name   string `json:"name"`
+}
+
+type GuildInfoResponse struct {
+	GuildId      int64             `json:"guild_id"`
+	Name         string            `json:"name"`
+	LeaderHeroId int64             `json:"leader_hero_id"`
+	Notice       string            `json:"notice"`
+	Members      []GuildMemberItem `json:"members"`
+	Applies      []GuildApplyItem  `json:"applies"` //只有官员和会长可以看到
+}
+
+// 自己的公会或者职位变化, 离开公会时GuildId为0
+type GuildChangedResponse struct {
+	GuildId int64  `json:"guild_id"`
+	Name    string `json:"name"`
+	Rank    int    `json:"rank"`
+}
+
+// 有新的申请时推送给在线的官员和会长
+type GuildApplyResponse struct {
+	HeroId int64  `json:"hero_id"`
+	Name   string `json:"name"`
+}
+
+// 其他hero的公会变化, 广播给看得见的hero
+type HeroGuildResponse struct {
+	ID        int64  `json:"id"`
+	GuildId   int64  `json:"guild_id"`
+	GuildName string `json:"guild_name"`
+}
+
+type HeroGuild struct {
+	GuildId int64  `json:"guild_id"`
+	Name    string `json:"name"`
+}
+
+type HeroGuildChangedRequest struct {
+	HeroId int64      `json:"hero_id"`
+	Guild  *HeroGuild `json:"guild"`
+	Sign   string     `json:"sign"`
+}
+
+func (r *HeroGuildChangedRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.HeroId, payloadDigest(r.Guild))
+}
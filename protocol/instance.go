@@ -0,0 +1,48 @@
+package protocol
+
+// 副本流程:
+// 传送门的目标场景是副本模板, 或者hero在npc旁边请求 SceneManager.NpcEnterInstance -> master Manager.HeroEnterInstance
+// master分配副本id, 按切换场景的流程进入, HeroEnterSceneRequest.InstanceId不为空时game节点按模板创建副本
+// 副本结束(怪物全部消灭/超时/所有人离开)后game节点通知master Manager.InstanceFinished, master记录结果并把还在副本内的hero送回原来的场景
+// 副本内的hero可以请求 Manager.LeaveInstance 提前离开
+import (
+	"github.com/nano/gameserver/pkg/algoutil"
+)
+
+type NpcEnterInstanceRequest struct {
+	NpcId int64 `json:"npc_id"`
+}
+
+type HeroEnterInstanceRequest struct {
+	Uid             int64  `json:"uid"`
+	HeroId          int64  `json:"hero_id"`
+	SceneId         int    `json:"scene_id"`          //hero当前所在的场景
+	InstanceSceneId int    `json:"instance_scene_id"` //副本模板场景
+	Sign            string `json:"sign"`
+}
+
+func (r *HeroEnterInstanceRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.Uid, r.HeroId, r.SceneId, r.InstanceSceneId)
+}
+
+type InstanceFinishedRequest struct {
+	InstanceId int64   `json:"instance_id"`
+	SceneId    int     `json:"scene_id"`
+	Result     int     `json:"result"`    //0 还没有结果
+	Uids       []int64 `json:"uids"`      //进入过副本的玩家
+	UsedTime   int     `json:"used_time"` //秒
+	Expired    bool    `json:"expired"`   //超过了时间限制, master需要把hero送出副本
+	Closed     bool    `json:"closed"`    //副本已经销毁
+	Sign       string  `json:"sign"`
+}
+
+func (r *InstanceFinishedRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.InstanceId, r.SceneId, r.Result, r.UsedTime, r.Expired, r.Closed, payloadDigest(r.Uids))
+}
+
+type InstanceResultResponse struct {
+	InstanceId int64 `json:"instance_id"`
+	SceneId    int   `json:"scene_id"`
+	Result     int   `json:"result"`
+	UsedTime   int   `json:"used_time"`
+}
@@ -0,0 +1,43 @@
+package protocol
+
+import (
+	"github.com/nano/gameserver/internal/game/object"
+)
+
+type MoveItemRequest struct {
+	From int `json:"from"` //背包格子
+	To   int `json:"to"`
+}
+
+type UseItemRequest struct {
+	Slot int `json:"slot"`
+}
+
+type DropItemRequest struct {
+	Slot  int `json:"slot"`
+	Count int `json:"count"`
+}
+
+type EquipItemRequest struct {
+	Slot int `json:"slot"`
+}
+
+type UnequipItemRequest struct {
+	Pos int `json:"pos"` //装备位置: 1 武器, 2 衣服, 3 头盔, 4 鞋子, 5 戒指
+}
+
+// 背包内的物品和身上的装备, equip_pos大于0的是身上的装备
+type HeroBagResponse struct {
+	Size  int               `json:"size"`
+	Items []*object.BagItem `json:"items"`
+}
+
+type HeroAttrChangedResponse struct {
+	ID      int64 `json:"id"`
+	Attack  int64 `json:"attack"`
+	Defense int64 `json:"defense"`
+	Life    int64 `json:"life"`
+	MaxLife int64 `json:"max_life"`
+	Mana    int64 `json:"mana"`
+	MaxMana int64 `json:"max_mana"`
+}
@@ -0,0 +1,21 @@
+package protocol
+
+// 场景分线, 同一个场景id可以同时运行多条线, 每条线是独立的场景
+// master按人数把hero分配到人数最少的线, 客户端可以请求 Manager.SceneLines 查看各条线的人数, Manager.SwitchLine 切换到指定的线
+// game节点启动参数的场景列表内"1"表示运行场景1的所有线, "1#2"表示只运行场景1的第2条线
+
+type SwitchLineRequest struct {
+	LineId int `json:"line_id"`
+}
+
+type SceneLineItem struct {
+	LineId  int  `json:"line_id"`
+	HeroCnt int  `json:"hero_cnt"`
+	Full    bool `json:"full"`
+}
+
+type SceneLinesResponse struct {
+	SceneId int             `json:"scene_id"`
+	LineId  int             `json:"line_id"` //当前所在的线
+	Lines   []SceneLineItem `json:"lines"`
+}
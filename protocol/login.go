@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/pkg/algoutil"
 )
 
 type ThirdUserLoginRequest struct {
@@ -99,6 +100,19 @@ type HeroChangeSceneRequest struct {
 	SceneId int   `json:"scene_id"`
 }
 
+// game节点检测到hero走进传送门后发给master, sign由game节点用cluster.secret签名
+type HeroEnterDoorRequest struct {
+	Uid     int64  `json:"uid"`
+	HeroId  int64  `json:"hero_id"`
+	SceneId int    `json:"scene_id"`
+	DoorId  int    `json:"door_id"`
+	Sign    string `json:"sign"`
+}
+
+func (r *HeroEnterDoorRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.Uid, r.HeroId, r.SceneId, r.DoorId)
+}
+
 type EncryptTest struct {
 	Payload string `json:"payload"`
 	Key     string `json:"key"`
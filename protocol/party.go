@@ -0,0 +1,66 @@
+package protocol
+
+// 组队由master管理, 队伍信息变化时推送给所有在线的队员(OnPartyChanged)
+// 同时通过队员的session通知所在的game节点 SceneManager.HeroPartyChanged, game节点按队伍分配经验和掉落
+// 切换场景时队伍信息放在HeroEnterSceneRequest内带到新的场景
+import (
+	"github.com/nano/gameserver/pkg/algoutil"
+)
+
+type PartyInviteRequest struct {
+	Uid int64 `json:"uid"` //被邀请的玩家
+}
+
+type PartyAcceptRequest struct {
+	InviterUid int64 `json:"inviter_uid"`
+}
+
+type PartyKickRequest struct {
+	Uid int64 `json:"uid"`
+}
+
+type PartyTransferLeaderRequest struct {
+	Uid int64 `json:"uid"`
+}
+
+type PartySetLootModeRequest struct {
+	LootMode int `json:"loot_mode"`
+}
+
+type PartyInviteResponse struct {
+	InviterUid  int64  `json:"inviter_uid"`
+	InviterName string `json:"inviter_name"`
+	ExpireAt    int64  `json:"expire_at"` //邀请的截止时间(毫秒)
+}
+
+type PartyMember struct {
+	Uid     int64  `json:"uid"`
+	HeroId  int64  `json:"hero_id"`
+	Name    string `json:"name"`
+	Level   int    `json:"level"`
+	SceneId int    `json:"scene_id"`
+	Online  bool   `json:"online"`
+}
+
+type PartyInfo struct {
+	PartyId   int64         `json:"party_id"`
+	LeaderUid int64         `json:"leader_uid"`
+	LootMode  int           `json:"loot_mode"`
+	Members   []PartyMember `json:"members"`
+}
+
+// 队伍解散或者离开队伍时Party为空
+type PartyChangedResponse struct {
+	Party *PartyInfo `json:"party"`
+}
+
+type HeroPartyChangedRequest struct {
+	HeroId int64      `json:"hero_id"`
+	Party  *PartyInfo `json:"party"`
+	Sign   string     `json:"sign"`
+}
+
+// 队员和分配方式都会被game节点使用, 整个队伍信息一起签名
+func (r *HeroPartyChangedRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.HeroId, payloadDigest(r.Party))
+}
@@ -8,17 +8,49 @@ const (
 	OnEnterScene          = "OnEnterScene"
 	OnEnterView           = "OnEnterView"
 	OnExitView            = "OnExitView"
+	OnViewHidden          = "OnViewHidden"
 	OnHeroMoveTrace       = "OnHeroMoveTrace"
 	OnHeroMoveStopped     = "OnHeroMoveStopped"
 	OnMonsterMoveTrace    = "OnMonsterMoveTrace"
 	OnMonsterMoveStopped  = "OnMonsterMoveStopped"
 	OnMonsterCommonAttack = "OnMonsterCommonAttack"
+	OnHeroCommonAttack    = "OnHeroCommonAttack"
 	OnReleaseSpell        = "OnReleaseSpell"
 	OnLifeChanged         = "OnLifeChanged"
 	OnManaChanged         = "OnManaChanged"
 	OnEntityDie           = "OnEntityDie"
+	OnHeroRevive          = "OnHeroRevive"
+	OnHeroExpChanged      = "OnHeroExpChanged"
+	OnHeroLevelUp         = "OnHeroLevelUp"
+	OnItemPickup          = "OnItemPickup"
+	OnHeroBag             = "OnHeroBag"
+	OnHeroAttrChanged     = "OnHeroAttrChanged"
 	OnBufferAdd           = "OnBufferAdd"
 	OnBufferRemove        = "OnBufferRemove"
 
 	OnTextMessage = "OnTextMessage"
+	OnChat        = "OnChat"
+	OnChatHistory = "OnChatHistory"
+
+	OnRecordingVoice = "OnRecordingVoice"
+
+	// 服务器下线倒计时
+	OnServerDrain = "OnServerDrain"
+
+	// 副本结束
+	OnInstanceResult = "OnInstanceResult"
+
+	// 组队
+	OnPartyInvite  = "OnPartyInvite"
+	OnPartyChanged = "OnPartyChanged"
+
+	// 公会
+	OnGuildChanged     = "OnGuildChanged"
+	OnGuildApply       = "OnGuildApply"
+	OnHeroGuildChanged = "OnHeroGuildChanged"
+
+	// 好友
+	OnFriendRequest  = "OnFriendRequest"
+	OnFriendChanged  = "OnFriendChanged"
+	OnFriendPresence = "OnFriendPresence"
 )
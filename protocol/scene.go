@@ -3,6 +3,7 @@ package protocol
 import (
 	"github.com/nano/gameserver/db/model"
 	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/algoutil"
 	"github.com/nano/gameserver/pkg/coord"
 )
 
@@ -12,13 +13,16 @@ type UserSceneId struct {
 }
 
 type HeroEnterSceneRequest struct {
-	SceneId  int `json:"scene_id"`
-	HeroData *model.Hero
-}
-
-type HeroLeaveSceneRequest struct {
-	SceneId int   `json:"scene_id"`
-	HeroId  int64 `json:"hero_id"`
+	SceneId     int `json:"scene_id"`
+	HeroData    *model.Hero
+	DestPos     *coord.Vector3 `json:"dest_pos,omitempty"`     //通过传送门进入时的位置, 为空时使用场景的出生点
+	State       *HeroState     `json:"state,omitempty"`        //切换场景时从上一个场景带过来的运行时数据
+	TransferId  int64          `json:"transfer_id,omitempty"`  //切换场景的流水号, 进入后需要回复master
+	CellMigrate bool           `json:"cell_migrate,omitempty"` //同一个场景内迁移到相邻的cell, 客户端不需要重新加载场景
+	InstanceId  int64          `json:"instance_id,omitempty"`  //进入副本, 不存在时按SceneId的模板创建
+	LineId      int            `json:"line_id,omitempty"`      //分线, 0和1都是第1条线
+	Party       *PartyInfo     `json:"party,omitempty"`        //所在的队伍
+	Guild       *HeroGuild     `json:"guild,omitempty"`        //所在的公会
 }
 
 type SceneInfoRequest struct {
@@ -35,9 +39,15 @@ type SceneInfoItem struct {
 }
 
 type EnterSceneResponse struct {
-	Scene    model.Scene       `json:"scene"`
-	Doors    []model.SceneDoor `json:"doors"`
-	HeroData object.HeroObject `json:"hero_data"`
+	Scene    model.Scene           `json:"scene"`
+	Doors    []model.SceneDoor     `json:"doors"`
+	HeroData object.HeroObject     `json:"hero_data"`
+	Spells   []*object.SpellObject `json:"spells"` //hero拥有的技能
+
+	InstanceId int64 `json:"instance_id,omitempty"` //副本id, 不是副本时为空
+	Deadline   int64 `json:"deadline,omitempty"`    //副本的截止时间(毫秒)
+	LineId     int   `json:"line_id"`               //所在的分线
+	LineCount  int   `json:"line_count"`            //场景的分线数量
 }
 
 type HeroSetViewRangeRequest struct {
@@ -57,6 +67,11 @@ type TargetExitViewResponse struct {
 	ID         int64 `json:"id"`
 }
 
+// 视野内超过数量上限没有推送的对象数量, 客户端用来显示人群密度
+type ViewHiddenResponse struct {
+	Count int `json:"count"`
+}
+
 type HeroMoveRequest struct {
 	Uid        int64     `json:"uid"`
 	TracePaths [][]int32 `json:"trace_paths"` //前端需要定时同步一小段路
@@ -104,8 +119,14 @@ type MonsterMoveStopResponse struct {
 type AttackRequest struct {
 	AttackerId int64  `json:"attacker_id"`
 	TargetId   int64  `json:"target_id"`
-	TargetType int    `json:"target_type"` // 0 monster , 1 hero
-	Action     string `json:"action"`      // 0 monster , 1 hero
+	TargetType int    `json:"target_type"` // 与entity_type一致, 0 hero, 1 monster
+	Action     string `json:"action"`
+}
+
+type HeroReleaseSpellRequest struct {
+	SpellId    int   `json:"spell_id"`
+	TargetId   int64 `json:"target_id"`   //对自己释放的技能可以不填
+	TargetType int   `json:"target_type"` // 与entity_type一致, 0 hero, 1 monster
 }
 
 type ReleaseSpellResponse struct {
@@ -120,6 +141,56 @@ type LifeChangedResponse struct {
 	MaxLife    int64 `json:"max_life"`
 }
 
+type HeroReviveRequest struct {
+	Mode int `json:"mode"` //0 出生点复活, 1 原地复活(消耗金币)
+}
+
+type HeroReviveResponse struct {
+	ID             int64       `json:"id"`
+	EntityType     int         `json:"entity_type"`
+	Life           int64       `json:"life"`
+	MaxLife        int64       `json:"max_life"`
+	Mana           int64       `json:"mana"`
+	MaxMana        int64       `json:"max_mana"`
+	PosX           coord.Coord `json:"pos_x"`
+	PosY           coord.Coord `json:"pos_y"`
+	PosZ           coord.Coord `json:"pos_z"`
+	InvincibleTime int64       `json:"invincible_time"` //无敌时间(毫秒)
+}
+
+type PickupItemRequest struct {
+	ID int64 `json:"id"` //地上物品的id
+}
+
+type ItemPickupResponse struct {
+	ID     int64  `json:"id"`
+	ItemId int    `json:"item_id"` //0为金币
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+}
+
+type HeroExpChangedResponse struct {
+	ID         int64 `json:"id"`
+	Add        int64 `json:"add"`
+	Experience int64 `json:"experience"`
+	Level      int   `json:"level"`
+}
+
+type HeroLevelUpResponse struct {
+	ID           int64 `json:"id"`
+	Level        int   `json:"level"`
+	Experience   int64 `json:"experience"`
+	Strength     int64 `json:"strength"`
+	Agility      int64 `json:"agility"`
+	Intelligence int64 `json:"intelligence"`
+	Attack       int64 `json:"attack"`
+	Defense      int64 `json:"defense"`
+	Life         int64 `json:"life"`
+	MaxLife      int64 `json:"max_life"`
+	Mana         int64 `json:"mana"`
+	MaxMana      int64 `json:"max_mana"`
+}
+
 type ManaChangedResponse struct {
 	ID         int64 `json:"id"`
 	EntityType int   `json:"entity_type"`
@@ -157,6 +228,17 @@ type MonsterAttackResponse struct {
 	PosZ       coord.Coord `json:"pos_z"`
 }
 
+type HeroAttackResponse struct {
+	ID         int64       `json:"id"`
+	Action     string      `json:"action"`
+	Damage     int64       `json:"damage"`
+	TargetId   int64       `json:"target_id"`
+	EntityType int         `json:"entity_type"`
+	PosX       coord.Coord `json:"pos_x"`
+	PosY       coord.Coord `json:"pos_y"`
+	PosZ       coord.Coord `json:"pos_z"`
+}
+
 type TextMessageRequest struct {
 	HeroId int64  `json:"hero_id"`
 	Msg    string `json:"msg"`
@@ -167,13 +249,22 @@ type TextMessageResponse struct {
 	Msg    string `json:"msg"`
 }
 
+// 通过web服务上传后发送到聊天频道, 频道同ChatSendRequest
 type RecordingVoice struct {
-	FileId string `json:"fileId"`
+	FileId    string `json:"fileId"`
+	Channel   int    `json:"channel"`
+	TargetUid int64  `json:"target_uid,omitempty"` //私聊的接收人
 }
 
 type PlayRecordingVoice struct {
-	Uid    int64  `json:"uid"`
-	FileId string `json:"fileId"`
+	Uid      int64  `json:"uid"`
+	FileId   string `json:"fileId"`
+	HeroId   int64  `json:"hero_id"`
+	Name     string `json:"name"`
+	Channel  int    `json:"channel"`
+	TargetId int64  `json:"target_id"`
+	Duration int    `json:"duration"` //时长(毫秒)
+	SendAt   int64  `json:"send_at"`  //发送时间(毫秒)
 }
 
 type DynamicResetMonstersRequest struct {
@@ -183,3 +274,23 @@ type DynamicResetMonstersRequest struct {
 type ClientInitCompletedRequest struct {
 	IsReEnter bool `json:"isReenter"`
 }
+
+type DrainRequest struct {
+	Token   string `json:"token"`
+	Seconds int    `json:"seconds"` //倒计时秒数, <=0使用配置的时间
+}
+
+// game节点下线前通知master, sign由game节点用cluster.secret签名
+type GameNodeDrainingRequest struct {
+	SceneIds []int  `json:"scene_ids"`
+	Seconds  int    `json:"seconds"`
+	Sign     string `json:"sign"`
+}
+
+func (r *GameNodeDrainingRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.SceneIds, r.Seconds)
+}
+
+type ServerDrainResponse struct {
+	Seconds int `json:"seconds"` //剩余秒数
+}
@@ -0,0 +1,64 @@
+package protocol
+
+// hero切换场景的握手流程:
+// master -> 旧场景 SceneManager.HeroTransferOut, 旧场景保存数据并移除hero
+// 旧场景 -> master Manager.HeroTransferReady, 带上最新的hero数据和运行时数据
+// master -> 新场景 SceneManager.HeroEnterScene, 新场景恢复运行时数据
+// 新场景 -> master Manager.HeroTransferDone
+// game节点发给master的请求用cluster.secret签名
+import (
+	"github.com/nano/gameserver/db/model"
+	"github.com/nano/gameserver/internal/game/object"
+	"github.com/nano/gameserver/pkg/algoutil"
+)
+
+// 跨场景/跨节点传递的hero运行时数据
+type HeroState struct {
+	Life            int64                  `json:"life"`
+	Mana            int64                  `json:"mana"`
+	Buffers         []*object.BufferObject `json:"buffers"`
+	SpellCdTimes    map[int]int            `json:"spell_cd_times"`   //spellId -> 剩余cd
+	InvincibleUntil int64                  `json:"invincible_until"` //无敌的截止时间(毫秒)
+	ViewRange       [2]int                 `json:"view_range"`       //客户端设置的视野范围
+}
+
+type HeroTransferOutRequest struct {
+	SceneId     int    `json:"scene_id"`
+	HeroId      int64  `json:"hero_id"`
+	TransferId  int64  `json:"transfer_id"`
+	CellMigrate bool   `json:"cell_migrate,omitempty"` //同一个场景内迁移到相邻的cell
+	InstanceId  int64  `json:"instance_id,omitempty"`  //离开的副本
+	LineId      int    `json:"line_id,omitempty"`      //离开的分线
+	Sign        string `json:"sign"`
+}
+
+// 客户端也能调用game节点的handler, 只接受master签名的请求
+func (r *HeroTransferOutRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.SceneId, r.HeroId, r.TransferId, r.CellMigrate, r.InstanceId, r.LineId)
+}
+
+type HeroTransferReadyRequest struct {
+	Uid        int64       `json:"uid"`
+	HeroId     int64       `json:"hero_id"`
+	TransferId int64       `json:"transfer_id"`
+	HeroData   *model.Hero `json:"hero_data"`
+	State      *HeroState  `json:"state"`
+	Sign       string      `json:"sign"`
+}
+
+// master以HeroData和State为准, 需要一起签名
+func (r *HeroTransferReadyRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.Uid, r.HeroId, r.TransferId, payloadDigest(r.HeroData, r.State))
+}
+
+type HeroTransferDoneRequest struct {
+	Uid        int64  `json:"uid"`
+	HeroId     int64  `json:"hero_id"`
+	SceneId    int    `json:"scene_id"`
+	TransferId int64  `json:"transfer_id"`
+	Sign       string `json:"sign"`
+}
+
+func (r *HeroTransferDoneRequest) SignWith(secret string) string {
+	return algoutil.SignFields(secret, r.Uid, r.HeroId, r.SceneId, r.TransferId)
+}
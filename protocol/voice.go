@@ -0,0 +1,22 @@
+package protocol
+
+// 语音先通过web服务上传(/v1/voice/upload), 上传需要master签发的token
+// 上传后拿到文件id调用SceneManager.RecordingVoice, 按聊天频道推送OnRecordingVoice, 客户端通过/v1/voice/{fileId}下载播放
+import (
+	"github.com/nano/gameserver/pkg/algoutil"
+)
+
+type VoiceUploadToken struct {
+	Uid      int64  `json:"uid"`
+	ExpireAt int64  `json:"expire_at"` //截止时间(毫秒)
+	Token    string `json:"token"`
+}
+
+// token会交给客户端, 使用单独的voice.token_key, 不能用节点之间的签名密钥
+func (t *VoiceUploadToken) SignWith(key string) string {
+	return algoutil.HMACFields(key, "voice", t.Uid, t.ExpireAt)
+}
+
+type VoiceUploadResponse struct {
+	FileId string `json:"fileId"`
+}